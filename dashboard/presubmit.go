@@ -509,7 +509,7 @@ func aggregateTestParts(jirix *jiri.X, jobDir string, aggregateOutput bool) (*ag
 			r = curResult
 			break
 		}
-		if r.Status != test.Passed {
+		if !r.Status.Succeeded() {
 			data.result = false
 		}
 
@@ -0,0 +1,208 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// baselineEntry identifies a violation recorded in a baseline file.  It
+// deliberately omits the location of the rule, so that editing .godepcop files
+// doesn't invalidate the baseline.
+type baselineEntry struct {
+	Src   string `json:"src"`
+	Dst   string `json:"dst"`
+	Mode  string `json:"mode"`
+	Rule  string `json:"rule,omitempty"`
+	Layer string `json:"layer,omitempty"`
+}
+
+func newBaselineEntry(v violation) baselineEntry {
+	e := baselineEntry{Src: v.Src.ImportPath, Dst: v.Dst.ImportPath, Mode: v.Mode.String()}
+	switch {
+	case v.Rule != nil:
+		e.Rule = v.Rule.Pattern()
+	case v.Layer != nil:
+		e.Layer = v.Layer.Name
+	}
+	return e
+}
+
+func (e baselineEntry) String() string {
+	switch {
+	case e.Rule != "":
+		return fmt.Sprintf("%q importing %q (%s deny rule %q)", e.Src, e.Dst, e.Mode, e.Rule)
+	case e.Layer != "":
+		return fmt.Sprintf("%q importing %q (%s layer %q)", e.Src, e.Dst, e.Mode, e.Layer)
+	}
+	return fmt.Sprintf("%q importing %q (%s)", e.Src, e.Dst, e.Mode)
+}
+
+// baseline holds the violations that are tolerated by check, which allows
+// stricter rules to be adopted incrementally.
+type baseline struct {
+	Violations []baselineEntry `json:"violations"`
+}
+
+// loadBaseline loads the baseline file at the given path.
+func loadBaseline(path string) (*baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := new(baseline)
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
+
+// save writes the baseline to the given path, with the entries sorted so that
+// changes to the file are easy to review.
+func (b *baseline) save(path string) error {
+	uniq := make(map[baselineEntry]bool)
+	entries := []baselineEntry{}
+	for _, e := range b.Violations {
+		if !uniq[e] {
+			uniq[e] = true
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case a.Src != b.Src:
+			return a.Src < b.Src
+		case a.Dst != b.Dst:
+			return a.Dst < b.Dst
+		case a.Mode != b.Mode:
+			return a.Mode < b.Mode
+		case a.Rule != b.Rule:
+			return a.Rule < b.Rule
+		}
+		return a.Layer < b.Layer
+	})
+	data, err := json.MarshalIndent(baseline{entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), os.FileMode(0644))
+}
+
+// baselineReport classifies violations against a baseline.
+type baselineReport struct {
+	New      []violation     // Violations that aren't in the baseline.
+	Existing []violation     // Violations that are in the baseline.
+	Resolved []baselineEntry // Baseline entries that are no longer violations.
+}
+
+// compareBaseline classifies violations against b.  Baseline entries are only
+// considered resolved if their source package is in checked, since the
+// baseline may cover more packages than were checked.
+func compareBaseline(b *baseline, checked map[string]bool, violations []violation) baselineReport {
+	var report baselineReport
+	present := make(map[baselineEntry]bool)
+	for _, e := range b.Violations {
+		present[e] = false
+	}
+	for _, v := range violations {
+		e := newBaselineEntry(v)
+		if _, ok := present[e]; ok {
+			present[e] = true
+			report.Existing = append(report.Existing, v)
+		} else {
+			report.New = append(report.New, v)
+		}
+	}
+	for _, e := range b.Violations {
+		if !present[e] && checked[e.Src] {
+			report.Resolved = append(report.Resolved, e)
+			// Only report duplicate entries once.
+			present[e] = true
+		}
+	}
+	return report
+}
+
+// update removes the resolved entries in report from b.  New violations are
+// never added, so the baseline can only shrink.
+func (b *baseline) update(report baselineReport) {
+	resolved := make(map[baselineEntry]bool)
+	for _, e := range report.Resolved {
+		resolved[e] = true
+	}
+	var entries []baselineEntry
+	for _, e := range b.Violations {
+		if !resolved[e] {
+			entries = append(entries, e)
+		}
+	}
+	b.Violations = entries
+}
+
+// printBaselineReport prints report to w in the given format.
+func printBaselineReport(w io.Writer, format string, report baselineReport) error {
+	switch format {
+	case formatText:
+		for _, v := range report.New {
+			fmt.Fprintln(w, v)
+		}
+		for _, e := range report.Resolved {
+			fmt.Fprintf(w, "resolved baseline violation: %v\n", e)
+		}
+		fmt.Fprintf(w, "%d new, %d baseline and %d resolved baseline violations\n", len(report.New), len(report.Existing), len(report.Resolved))
+		return nil
+	case formatJSON:
+		jr := struct {
+			New      []jsonViolation `json:"new"`
+			Existing []jsonViolation `json:"existing"`
+			Resolved []baselineEntry `json:"resolved"`
+		}{[]jsonViolation{}, []jsonViolation{}, []baselineEntry{}}
+		for _, v := range report.New {
+			jr.New = append(jr.New, newJSONViolation(v))
+		}
+		for _, v := range report.Existing {
+			jr.Existing = append(jr.Existing, newJSONViolation(v))
+		}
+		jr.Resolved = append(jr.Resolved, report.Resolved...)
+		return writeJSON(w, jr)
+	case formatSARIF:
+		log := newSARIFLog(append(append([]violation(nil), report.New...), report.Existing...))
+		results := log.Runs[0].Results
+		for i := range results {
+			results[i].BaselineState = "new"
+			if i >= len(report.New) {
+				results[i].BaselineState = "unchanged"
+			}
+		}
+		for _, e := range report.Resolved {
+			ruleID := sarifRuleInternal
+			switch {
+			case e.Rule != "":
+				ruleID = sarifRuleDeny
+			case e.Layer != "":
+				ruleID = sarifRuleLayer
+			}
+			log.Runs[0].Results = append(log.Runs[0].Results, sarifResult{
+				RuleID:        ruleID,
+				Level:         "none",
+				Message:       sarifMessage{"resolved baseline violation: " + e.String()},
+				BaselineState: "absent",
+			})
+		}
+		return writeJSON(w, log)
+	case formatDot:
+		// Only the new violations are graphed, since they are the ones
+		// that fail the check.
+		printViolationsDot(w, report.New)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	denyFoo := rule{Deny: &dots}
+	viol := func(src, dst string, mode checkMode, r *rule) violation {
+		return violation{Src: pkg(src), Dst: pkg(dst), Mode: mode, Rule: r}
+	}
+	entry := func(src, dst string, mode checkMode, r string) baselineEntry {
+		return baselineEntry{Src: src, Dst: dst, Mode: mode.String(), Rule: r}
+	}
+	b := &baseline{Violations: []baselineEntry{
+		entry("a", "x", modePkg, "..."),
+		entry("a", "y", modeTest, "..."),
+		entry("b", "a/internal", modePkg, ""),
+		entry("c", "x", modePkg, "..."),
+	}}
+	violations := []violation{
+		viol("a", "x", modePkg, &denyFoo),
+		viol("a", "x", modeTest, &denyFoo),
+		viol("b", "z", modePkg, &denyFoo),
+	}
+	// Package c isn't checked, so its entry isn't resolved.
+	checked := map[string]bool{"a": true, "b": true}
+	report := compareBaseline(b, checked, violations)
+	if got, want := report.New, []violation{violations[1], violations[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got new %v, want %v", got, want)
+	}
+	if got, want := report.Existing, []violation{violations[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got existing %v, want %v", got, want)
+	}
+	wantResolved := []baselineEntry{entry("a", "y", modeTest, "..."), entry("b", "a/internal", modePkg, "")}
+	if got, want := report.Resolved, wantResolved; !reflect.DeepEqual(got, want) {
+		t.Errorf("got resolved %v, want %v", got, want)
+	}
+	// Updating the baseline only removes the resolved entries.
+	b.update(report)
+	wantBaseline := &baseline{Violations: []baselineEntry{entry("a", "x", modePkg, "..."), entry("c", "x", modePkg, "...")}}
+	if got, want := b, wantBaseline; !reflect.DeepEqual(got, want) {
+		t.Errorf("got baseline %v, want %v", got, want)
+	}
+}
+
+func TestBaselineSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godepcop")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+	b := &baseline{Violations: []baselineEntry{
+		{Src: "b", Dst: "x", Mode: "pkg"},
+		{Src: "a", Dst: "y", Mode: "test", Rule: "y"},
+		{Src: "a", Dst: "x", Mode: "pkg", Rule: "..."},
+		{Src: "b", Dst: "x", Mode: "pkg"},
+	}}
+	if err := b.save(path); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	got, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline failed: %v", err)
+	}
+	// Entries are sorted and deduplicated.
+	want := &baseline{Violations: []baselineEntry{
+		{Src: "a", Dst: "x", Mode: "pkg", Rule: "..."},
+		{Src: "a", Dst: "y", Mode: "test", Rule: "y"},
+		{Src: "b", Dst: "x", Mode: "pkg"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"v.io/jiri/runutil"
+)
+
+// cacheVersion is mixed into every cache key; bump it whenever the checks or
+// the format of cache entries change, to invalidate existing caches.
+const cacheVersion = "godepcop-cache-3"
+
+// diskCache persists the violations of checked packages in a directory, so that
+// reruns only recheck packages whose imports or rules have changed.  Each
+// package is stored in its own file, so packages may be checked concurrently.
+type diskCache struct {
+	dir string
+}
+
+// cacheEntry is the on-disk representation of the violations of a package.
+type cacheEntry struct {
+	Key        string           `json:"key"`
+	Violations []cacheViolation `json:"violations"`
+}
+
+type cacheViolation struct {
+	Dst    string    `json:"dst"`
+	Err    string    `json:"err"`
+	Mode   checkMode `json:"mode"`
+	Rule   *rule     `json:"rule,omitempty"`
+	Layer  *layer    `json:"layer,omitempty"`
+	Config string    `json:"config,omitempty"`
+	Chain  []string  `json:"chain,omitempty"`
+}
+
+// newDiskCache returns a cache that stores entries in dir, creating dir if it
+// doesn't exist.
+func newDiskCache(dir string) (*diskCache, error) {
+	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir}, nil
+}
+
+func (c *diskCache) path(pkg *build.Package) string {
+	sum := sha256.Sum256([]byte(pkg.ImportPath))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cacheKey returns a key that changes whenever the violations of pkg may
+// change.  The key covers the contents of the files of pkg, including the
+// files excluded from the current build context, which determine the files
+// that scoped rules apply to, the contents of the .godepcop files that apply
+// to pkg, and the imports of every transitive dependency of pkg, including the
+// dependencies pulled in by files in scope of rules with tags.
+func cacheKey(pkg *build.Package) (string, error) {
+	h := sha256.New()
+	writeKey := func(fields ...string) {
+		for _, field := range fields {
+			fmt.Fprintf(h, "%d:%s", len(field), field)
+		}
+	}
+	writeKey(cacheVersion, strings.Join(build.Default.BuildTags, ","), pkg.ImportPath, pkg.Dir)
+	files := append(append(append(append([]string(nil), pkg.GoFiles...), pkg.CgoFiles...), pkg.TestGoFiles...), pkg.XTestGoFiles...)
+	files = append(files, pkg.IgnoredGoFiles...)
+	sort.Strings(files)
+	for _, file := range files {
+		writeKey(file)
+		if err := hashFile(h, filepath.Join(pkg.Dir, file)); err != nil {
+			return "", err
+		}
+	}
+	it := newConfigIter(pkg)
+	for it.Advance() {
+		path := it.Value().Path
+		writeKey(path)
+		switch err := hashFile(h, path); {
+		case runutil.IsNotExist(err):
+			writeKey("absent")
+		case err != nil:
+			return "", err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return "", err
+	}
+	writeKey(strings.Join(pkg.Imports, " "), strings.Join(pkg.TestImports, " "), strings.Join(pkg.XTestImports, " "))
+	deps := make(map[string]*build.Package)
+	if err := (depOpts{IncludeGoroot: true, IncludeXTest: true}).Deps(pkg, deps); err != nil {
+		return "", err
+	}
+	tagged, err := taggedDeps(pkg, modeXTest)
+	if err != nil {
+		return "", err
+	}
+	for path, dep := range tagged {
+		if deps[path] == nil {
+			deps[path] = dep
+		}
+	}
+	for _, dep := range sortPackages(deps) {
+		writeKey(dep.ImportPath, fmt.Sprint(dep.Goroot), strings.Join(dep.Imports, " "))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(h hash.Hash, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(h, file)
+	return err
+}
+
+// Get returns the cached violations of pkg, and true iff the cache holds an
+// entry for pkg with the given key.
+func (c *diskCache) Get(pkg *build.Package, key string) ([]violation, bool) {
+	data, err := ioutil.ReadFile(c.path(pkg))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+	var violations []violation
+	for _, cv := range entry.Violations {
+		dst, err := importPackage(cv.Dst)
+		if err != nil {
+			return nil, false
+		}
+		violations = append(violations, violation{
+			Src:    pkg,
+			Dst:    dst,
+			Err:    errors.New(cv.Err),
+			Mode:   cv.Mode,
+			Rule:   cv.Rule,
+			Layer:  cv.Layer,
+			Config: cv.Config,
+			Chain:  cv.Chain,
+		})
+	}
+	return violations, true
+}
+
+// Put stores the violations of pkg with the given key.  The entry is written to
+// a temporary file and renamed, so that concurrent readers never see a partial
+// entry.
+func (c *diskCache) Put(pkg *build.Package, key string, violations []violation) error {
+	entry := cacheEntry{Key: key, Violations: []cacheViolation{}}
+	for _, v := range violations {
+		entry.Violations = append(entry.Violations, cacheViolation{
+			Dst:    v.Dst.ImportPath,
+			Err:    v.Err.Error(),
+			Mode:   v.Mode,
+			Rule:   v.Rule,
+			Layer:  v.Layer,
+			Config: v.Config,
+			Chain:  v.Chain,
+		})
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.dir, "tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(pkg))
+}
+
+// checkDepsCached is like checkDeps, but returns the violations from cache if
+// pkg hasn't changed since it was last checked.  If cache is nil, pkg is
+// always checked.
+func checkDepsCached(pkg *build.Package, cache *diskCache) ([]violation, error) {
+	if cache == nil {
+		return checkDeps(pkg)
+	}
+	key, err := cacheKey(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if violations, ok := cache.Get(pkg, key); ok {
+		return violations, nil
+	}
+	violations, err := checkDeps(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Put(pkg, key, violations); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var checkAllPackages = []string{
+	"v.io/x/devtools/godepcop/testdata/test-a",
+	"v.io/x/devtools/godepcop/testdata/test-b",
+	"v.io/x/devtools/godepcop/testdata/test-c",
+	"v.io/x/devtools/godepcop/testdata/test-d",
+	"v.io/x/devtools/godepcop/testdata/test-scoped",
+	"v.io/x/devtools/godepcop/testdata/test-internal-fail",
+	"v.io/x/devtools/godepcop/testdata/test-layers/low",
+}
+
+func importPackages(t *testing.T, paths []string) []*build.Package {
+	var pkgs []*build.Package
+	for _, path := range paths {
+		p, err := importPackage(path)
+		if err != nil {
+			t.Fatalf("importPackage(%q) failed: %v", path, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs
+}
+
+// violationStrings returns a comparable description of each violation, since
+// cached violations don't share pointers with the config cache.
+func violationStrings(violations []violation) []string {
+	var result []string
+	for _, v := range violations {
+		desc := fmt.Sprintf("%v %v %v", v, v.Mode, v.Chain)
+		if v.Rule != nil {
+			desc += fmt.Sprintf(" rule %s:%d", v.Config, v.Rule.Line)
+		}
+		if v.Layer != nil {
+			desc += fmt.Sprintf(" layer %s:%s", v.Config, v.Layer.Name)
+		}
+		result = append(result, desc)
+	}
+	return result
+}
+
+func TestCheckAll(t *testing.T) {
+	pkgs := importPackages(t, checkAllPackages)
+	serial, err := checkAll(pkgs, 1, nil)
+	if err != nil {
+		t.Fatalf("checkAll failed: %v", err)
+	}
+	if len(serial) == 0 {
+		t.Fatalf("got no violations")
+	}
+	parallel, err := checkAll(pkgs, 4, nil)
+	if err != nil {
+		t.Fatalf("checkAll failed: %v", err)
+	}
+	if got, want := violationStrings(parallel), violationStrings(serial); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godepcop")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cache, err := newDiskCache(dir)
+	if err != nil {
+		t.Fatalf("newDiskCache failed: %v", err)
+	}
+	pkgs := importPackages(t, checkAllPackages)
+	want, err := checkAll(pkgs, 1, nil)
+	if err != nil {
+		t.Fatalf("checkAll failed: %v", err)
+	}
+	// The first run populates the cache, and the second run reads from it.
+	for i := 0; i < 2; i++ {
+		got, err := checkAll(pkgs, 4, cache)
+		if err != nil {
+			t.Fatalf("checkAll failed: %v", err)
+		}
+		if !reflect.DeepEqual(violationStrings(got), violationStrings(want)) {
+			t.Errorf("run %d got %v, want %v", i, violationStrings(got), violationStrings(want))
+		}
+	}
+	for _, pkg := range pkgs {
+		key, err := cacheKey(pkg)
+		if err != nil {
+			t.Fatalf("cacheKey failed: %v", err)
+		}
+		if _, ok := cache.Get(pkg, key); !ok {
+			t.Errorf("%s not cached", pkg.ImportPath)
+		}
+		// Entries with stale keys are ignored.
+		if _, ok := cache.Get(pkg, key+"x"); ok {
+			t.Errorf("%s cached with stale key", pkg.ImportPath)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	// Keys must be stable.
+	a := importPackages(t, []string{"v.io/x/devtools/godepcop/testdata/test-layers/low"})[0]
+	keyA, err := cacheKey(a)
+	if err != nil {
+		t.Fatalf("cacheKey failed: %v", err)
+	}
+	keyA2, err := cacheKey(a)
+	if err != nil {
+		t.Fatalf("cacheKey failed: %v", err)
+	}
+	if keyA != keyA2 {
+		t.Errorf("unstable keys %v and %v", keyA, keyA2)
+	}
+	// Changing the imports of a dependency changes the key.
+	mid := importPackages(t, []string{"v.io/x/devtools/godepcop/testdata/test-layers/mid"})[0]
+	saved := mid.Imports
+	mid.Imports = nil
+	defer func() { mid.Imports = saved }()
+	keyB, err := cacheKey(a)
+	if err != nil {
+		t.Fatalf("cacheKey failed: %v", err)
+	}
+	if keyA == keyB {
+		t.Errorf("got the same key %v after changing dependency imports", keyA)
+	}
+}
@@ -10,9 +10,12 @@ package main
 import (
 	"fmt"
 	"go/build"
+	"runtime"
+	"strings"
 
 	"v.io/jiri/profiles/profilescmdline"
 	"v.io/jiri/profiles/profilesreader"
+	"v.io/jiri/runutil"
 	"v.io/x/lib/cmdline"
 )
 
@@ -22,6 +25,14 @@ var (
 	flagGoroot        bool
 	flagTest          bool
 	flagXTest         bool
+	flagTags          string
+	flagFormat        string
+	flagAll           bool
+	flagBaseline      string
+	flagCache         string
+	flagNumWorkers    int
+	flagDiff          bool
+	flagWrite         bool
 	mergePoliciesFlag profilesreader.MergePolicies
 )
 
@@ -30,10 +41,14 @@ const (
 	styleIndent = "indent"
 	styleDot    = "dot"
 
-	descDirect = "Only show direct dependencies, rather than showing transitive dependencies."
-	descGoroot = "Show $GOROOT packages."
-	descTest   = "Show imports from test files in the same package."
-	descXTest  = "Show imports from test files in the same package or in the *_test package."
+	descDirect   = "Only show direct dependencies, rather than showing transitive dependencies."
+	descGoroot   = "Show $GOROOT packages."
+	descTest     = "Show imports from test files in the same package."
+	descXTest    = "Show imports from test files in the same package or in the *_test package."
+	descTags     = "Comma-separated list of build tags to consider satisfied when loading packages."
+	descBaseline = "Path of the baseline file, which records violations that are tolerated."
+	descCache    = "Directory in which to cache the results of checking each package; if empty, no cache is used."
+	descWorkers  = "Number of packages to check concurrently."
 )
 
 func init() {
@@ -51,10 +66,47 @@ List dependencies with the given style:
 	cmdListImporters.Flags.BoolVar(&flagGoroot, "goroot", false, descGoroot)
 	cmdListImporters.Flags.BoolVar(&flagTest, "test", false, descTest)
 	cmdListImporters.Flags.BoolVar(&flagXTest, "xtest", false, descXTest)
+	cmdCheck.Flags.StringVar(&flagFormat, "format", formatText, `
+Print violations in the given format:
+   text  - As human-readable lines.
+   json  - As a JSON array of violations.
+   sarif - As a SARIF 2.1.0 log (https://sarifweb.azurewebsites.net)
+   dot   - As a DOT graph of the chains of imports of the violations, with
+           the imports of disallowed packages, e.g. upward imports between
+           layers, highlighted.
+`)
+	cmdCheck.Flags.StringVar(&flagTags, "tags", "", descTags)
+	cmdCheck.Flags.StringVar(&flagBaseline, "baseline", "", descBaseline)
+	cmdBaselineUpdate.Flags.StringVar(&flagBaseline, "baseline", "", descBaseline)
+	cmdBaselineUpdate.Flags.StringVar(&flagTags, "tags", "", descTags)
+	for _, cmd := range []*cmdline.Command{cmdCheck, cmdBaselineUpdate} {
+		cmd.Flags.StringVar(&flagCache, "cache", "", descCache)
+		cmd.Flags.IntVar(&flagNumWorkers, "num-workers", runtime.NumCPU(), descWorkers)
+		cmd.Flags.Lookup("num-workers").DefValue = "<runtime.NumCPU()>"
+	}
+	cmdList.Flags.StringVar(&flagTags, "tags", "", descTags)
+	cmdListImporters.Flags.StringVar(&flagTags, "tags", "", descTags)
+	cmdWhy.Flags.BoolVar(&flagAll, "all", false, "Show all chains of imports, rather than only the shortest chain.")
+	cmdWhy.Flags.BoolVar(&flagTest, "test", false, "Follow imports from test files in the same package.")
+	cmdWhy.Flags.BoolVar(&flagXTest, "xtest", false, "Follow imports from test files in the same package or in the *_test package.")
+	cmdWhy.Flags.StringVar(&flagTags, "tags", "", descTags)
+	cmdCycles.Flags.StringVar(&flagStyle, "style", styleSet, `
+List cycles with the given style:
+   set - As a list of the packages in each cycle, with their imports.
+   dot - As a DOT graph (http://www.graphviz.org)
+`)
+	cmdCycles.Flags.BoolVar(&flagTest, "test", false, "Include imports from test files in the same package.")
+	cmdCycles.Flags.StringVar(&flagTags, "tags", "", descTags)
+	cmdSuggest.Flags.BoolVar(&flagDiff, "diff", false, "Print the differences between the suggested and existing .godepcop files, as unified diffs.")
+	cmdSuggest.Flags.BoolVar(&flagWrite, "write", false, "Write the suggested .godepcop files, replacing existing files.")
+	cmdSuggest.Flags.StringVar(&flagTags, "tags", "", descTags)
 
 	mergePoliciesFlag = profilesreader.JiriMergePolicies()
 	profilescmdline.RegisterMergePoliciesFlag(&cmdList.Flags, &mergePoliciesFlag)
 	profilescmdline.RegisterMergePoliciesFlag(&cmdListImporters.Flags, &mergePoliciesFlag)
+	profilescmdline.RegisterMergePoliciesFlag(&cmdWhy.Flags, &mergePoliciesFlag)
+	profilescmdline.RegisterMergePoliciesFlag(&cmdCycles.Flags, &mergePoliciesFlag)
+	profilescmdline.RegisterMergePoliciesFlag(&cmdSuggest.Flags, &mergePoliciesFlag)
 }
 
 var readerFlags profilescmdline.ReaderFlagValues
@@ -63,6 +115,17 @@ func main() {
 	cmdline.Main(cmdRoot)
 }
 
+// splitTags splits a comma-separated list of build tags, ignoring empty tags.
+func splitTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
 func depOptsFromFlags() depOpts {
 	return depOpts{
 		DirectOnly:    flagDirect,
@@ -80,7 +143,7 @@ Command godepcop checks Go package dependencies against constraints described in
 .godepcop files.  In addition to user-defined constraints, the Go 1.5 internal
 package rules are also enforced.
 `,
-	Children: []*cmdline.Command{cmdCheck, cmdList, cmdListImporters},
+	Children: []*cmdline.Command{cmdCheck, cmdBaseline, cmdList, cmdListImporters, cmdWhy, cmdCycles, cmdSuggest},
 }
 
 var cmdCheck = &cmdline.Command{
@@ -99,6 +162,11 @@ the source tree, until a matching rule is found.  If no matching rule is found,
 the default behavior is to allow the dependency, to support packages that do not
 have any dependency rules.
 
+Inside a Go module the files are traversed up to the module root, i.e. the
+directory containing go.mod, and packages are resolved according to the go.mod
+and go.work files, including replace directives and vendor directories.
+Outside of a module the files are traversed up to the root of the import path.
+
 The .godepcop file is encoded in XML:
 
   <godepcop>
@@ -114,6 +182,28 @@ on the given pattern.  Patterns that end with "/..." are special: "foo/..."
 means that foo and all its subpackages match the rule.  The special-case pattern
 "..."  means that all packages in GOPATH, but not GOROOT, match the rule.
 
+Patterns that start with "regexp:" are regular expressions, which are matched
+against the full import path.  Each rule may also specify optional attributes
+that restrict the rule:
+  except - Whitespace-separated patterns; packages matching any of these are
+           excluded from the rule, and are left to subsequent rules.
+  files  - Whitespace-separated filename globs; the rule only applies to
+           dependencies pulled in by imports from files matching a glob.
+  tags   - Whitespace-separated build tags; the rule only applies to
+           dependencies pulled in by imports from files whose build constraints
+           or GOOS/GOARCH file name suffixes mention a tag and are satisfied
+           when it is set, even if the files are excluded from the current
+           build, e.g. *_windows.go files on Linux.  The "cgo" tag also matches
+           files that import "C".
+A rule with both files and tags applies to files matching either of them.  For
+example:
+
+  <godepcop>
+    <pkg deny="regexp:^v\.io/x/ref/services/[^/]+/internal$"/>
+    <pkg allow="golang.org/x/..." except="golang.org/x/net/..."/>
+    <pkg deny="..." files="*_cgo.go" tags="cgo"/>
+  </godepcop>
+
 There are three groups of rules:
   pkg   - Rules applied to all imports from the package.
   test  - Extra rules for imports from all test files.
@@ -125,33 +215,65 @@ package P:
   P.Imports                              - check pkg rules
   P.Imports+P.TestImports                - check test and pkg rules
   P.Imports+P.TestImports+P.XTestImports - check xtest, test and pkg rules
+
+Each violation is reported with the source and destination packages, the mode
+(pkg, test or xtest) and, for deny rules, the rule pattern along with the path
+and line of the .godepcop file containing it.  The -format=json and
+-format=sarif flags additionally report the chain of imports from the source to
+the destination package, for consumption by other tools, and the -format=dot
+flag graphs these chains, e.g. to show the upward imports between layers.
+
+Set the -baseline flag to only fail on violations that aren't recorded in the
+given baseline file; if the file doesn't exist, it is created with the current
+violations.  Violations are reported as new, baseline (still present) or
+resolved.  Use "godepcop baseline update" to remove resolved violations.
+
+Packages are checked concurrently; see the -num-workers flag.  Set the -cache
+flag to persist the results of checking each package in the given directory.
+Subsequent runs only recheck packages whose files, .godepcop files or
+transitive imports have changed.
 `}
 
-func runCheck(env *cmdline.Env, args []string) error {
+// checkPackages checks the packages specified in args, and returns the checked
+// packages along with their violations.
+func checkPackages(env *cmdline.Env, args []string) ([]*build.Package, []violation, error) {
 	// Gather packages specified in args.
 	paths, err := listPackagePaths(env, args...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	var pkgs []*build.Package
 	for _, path := range paths {
 		pkg, err := importPackage(path)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		pkgs = append(pkgs, pkg)
 	}
-	// Check each package.
-	var violations []violation
-	for _, pkg := range pkgs {
-		v, err := checkDeps(pkg)
-		if err != nil {
-			return err
+	// Check the packages concurrently, reusing cached results if possible.
+	var cache *diskCache
+	if flagCache != "" {
+		if cache, err = newDiskCache(flagCache); err != nil {
+			return nil, nil, err
 		}
-		violations = append(violations, v...)
 	}
-	for _, v := range violations {
-		fmt.Fprintf(env.Stdout, "%q not allowed to import %q (%v)\n", v.Src.ImportPath, v.Dst.ImportPath, v.Err)
+	violations, err := checkAll(pkgs, flagNumWorkers, cache)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkgs, violations, nil
+}
+
+func runCheck(env *cmdline.Env, args []string) error {
+	pkgs, violations, err := checkPackages(env, args)
+	if err != nil {
+		return err
+	}
+	if flagBaseline != "" {
+		return checkBaseline(env, pkgs, violations)
+	}
+	if err := printViolations(env.Stdout, flagFormat, violations); err != nil {
+		return err
 	}
 	if len(violations) > 0 {
 		return fmt.Errorf("dependency violation")
@@ -159,6 +281,88 @@ func runCheck(env *cmdline.Env, args []string) error {
 	return nil
 }
 
+// checkBaseline compares violations against the baseline file, and only fails
+// on new violations.  If the baseline file doesn't exist, it is created with
+// the current violations.
+func checkBaseline(env *cmdline.Env, pkgs []*build.Package, violations []violation) error {
+	b, err := loadBaseline(flagBaseline)
+	switch {
+	case runutil.IsNotExist(err):
+		b = new(baseline)
+		for _, v := range violations {
+			b.Violations = append(b.Violations, newBaselineEntry(v))
+		}
+		if err := b.save(flagBaseline); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.Stderr, "recorded %d violations in baseline %s\n", len(violations), flagBaseline)
+		return nil
+	case err != nil:
+		return err
+	}
+	report := compareBaseline(b, packageSet(pkgs), violations)
+	if err := printBaselineReport(env.Stdout, flagFormat, report); err != nil {
+		return err
+	}
+	if len(report.New) > 0 {
+		return fmt.Errorf("dependency violation")
+	}
+	return nil
+}
+
+func packageSet(pkgs []*build.Package) map[string]bool {
+	set := make(map[string]bool)
+	for _, pkg := range pkgs {
+		set[pkg.ImportPath] = true
+	}
+	return set
+}
+
+var cmdBaseline = &cmdline.Command{
+	Name:  "baseline",
+	Short: "Manage baseline files of tolerated violations",
+	Long: `
+Manage baseline files of tolerated violations.
+
+A baseline file records violations that are tolerated by "check -baseline",
+which allows stricter rules to be adopted in large source trees without first
+fixing every existing violation.
+`,
+	Children: []*cmdline.Command{cmdBaselineUpdate},
+}
+
+var cmdBaselineUpdate = &cmdline.Command{
+	Runner:   cmdline.RunnerFunc(runBaselineUpdate),
+	Name:     "update",
+	ArgsName: "<packages>",
+	ArgsLong: "<packages> is a list of packages to check",
+	Short:    "Remove resolved violations from a baseline file",
+	Long: `
+Check the given <packages>, and remove violations of these packages that have
+been resolved from the baseline file given by the -baseline flag.  New
+violations are never added, so the baseline can only shrink over time.
+`}
+
+func runBaselineUpdate(env *cmdline.Env, args []string) error {
+	if flagBaseline == "" {
+		return env.UsageErrorf("the -baseline flag must be specified")
+	}
+	b, err := loadBaseline(flagBaseline)
+	if err != nil {
+		return err
+	}
+	pkgs, violations, err := checkPackages(env, args)
+	if err != nil {
+		return err
+	}
+	report := compareBaseline(b, packageSet(pkgs), violations)
+	for _, e := range report.Resolved {
+		fmt.Fprintf(env.Stdout, "removed resolved violation: %v\n", e)
+	}
+	b.update(report)
+	return b.save(flagBaseline)
+}
+
 var cmdList = &cmdline.Command{
 	Runner:   cmdline.RunnerFunc(runList),
 	Name:     "list",
@@ -289,3 +493,151 @@ func runListImporters(env *cmdline.Env, args []string) error {
 	}
 	return nil
 }
+
+var cmdWhy = &cmdline.Command{
+	Runner:   cmdline.RunnerFunc(runWhy),
+	Name:     "why",
+	ArgsName: "<src> <dst>",
+	ArgsLong: `
+<src> is the importing package, and <dst> is the import path of the imported
+package
+`,
+	Short: "Explain why a package depends on another package",
+	Long: `
+Explain why package <src> depends on package <dst>, by printing the shortest
+chain of imports from <src> to <dst>.  Set the -all flag to print all chains.
+
+Each import in a chain is annotated with the .godepcop rule of the importing
+package that decided the import, along with the path and line of the .godepcop
+file containing the rule.  The first import is checked with the pkg, test or
+xtest rules, depending on where it is imported; all other imports are checked
+with the pkg rules.
+
+Only follows imports from non-test files by default; set the -test or -xtest
+flags to also follow imports from test files of <src>.
+`}
+
+func runWhy(env *cmdline.Env, args []string) error {
+	if len(args) != 2 {
+		return env.UsageErrorf("expected <src> and <dst> arguments, got %v", args)
+	}
+	paths, err := listPackagePaths(env, args[0])
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		return env.UsageErrorf("<src> must match exactly one package, got %v", paths)
+	}
+	src, err := importPackage(paths[0])
+	if err != nil {
+		return err
+	}
+	opts := depOptsFromFlags()
+	opts.DirectOnly = false
+	opts.IncludeGoroot = true
+	found, err := why(env.Stdout, src, args[1], opts, flagAll)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s does not depend on %s", src.ImportPath, args[1])
+	}
+	return nil
+}
+
+var cmdCycles = &cmdline.Command{
+	Runner:   cmdline.RunnerFunc(runCycles),
+	Name:     "cycles",
+	ArgsName: "<packages>",
+	ArgsLong: "<packages> is a list of packages",
+	Short:    "List import cycles among the given packages",
+	Long: `
+List import cycles among the given <packages> and their transitive dependencies.
+Each cycle is a set of packages that all depend on each other.  Fails if any
+cycles are found.
+
+The go tool rejects cycles of ordinary imports, so cycles are typically only
+found with the -test flag, which includes imports from test files in the same
+package.  Such cycles prevent the tests from being built.  $GOROOT packages are
+always elided.
+`}
+
+func runCycles(env *cmdline.Env, args []string) error {
+	paths, err := listPackagePaths(env, args...)
+	if err != nil {
+		return err
+	}
+	var pkgs []*build.Package
+	for _, path := range paths {
+		pkg, err := importPackage(path)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	graph, err := newDepGraph(pkgs, depOpts{IncludeTest: flagTest})
+	if err != nil {
+		return err
+	}
+	cycles := findCycles(graph)
+	switch flagStyle {
+	case styleDot:
+		printCyclesDot(env.Stdout, graph, cycles)
+	default:
+		printCycles(env.Stdout, graph, cycles)
+	}
+	if len(cycles) > 0 {
+		return fmt.Errorf("found %d import cycles", len(cycles))
+	}
+	return nil
+}
+
+var cmdSuggest = &cmdline.Command{
+	Runner:   cmdline.RunnerFunc(runSuggest),
+	Name:     "suggest",
+	ArgsName: "<packages>",
+	ArgsLong: "<packages> is a list of packages",
+	Short:    "Suggest .godepcop rules based on current dependencies",
+	Long: `
+Suggest a .godepcop file for each of the given <packages>, which allows the
+current dependencies of the package and denies all other dependencies.
+
+The pkg rules allow the transitive dependencies of the package, the test rules
+allow the extra dependencies of test files in the same package, and the xtest
+rules allow the extra dependencies of test files in the *_test package.
+Dependencies are collapsed into "/..." patterns where possible, i.e. when the
+pattern matches at least two dependencies, and every other package matching the
+pattern that is known to godepcop is also a dependency.  The known packages are
+the given <packages> and their transitive dependencies.  $GOROOT packages are
+always allowed, and are never mentioned in the rules.
+
+Prints the suggested files by default.  Set the -diff flag to print the
+differences against existing files, or the -write flag to write the files.
+Suggestions don't take the .godepcop files of parent directories into account.
+`}
+
+func runSuggest(env *cmdline.Env, args []string) error {
+	if flagDiff && flagWrite {
+		return env.UsageErrorf("the -diff and -write flags are mutually exclusive")
+	}
+	paths, err := listPackagePaths(env, args...)
+	if err != nil {
+		return err
+	}
+	var pkgs []*build.Package
+	for _, path := range paths {
+		pkg, err := importPackage(path)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	suggestions, err := suggestRules(pkgs)
+	if err != nil {
+		return err
+	}
+	if flagWrite {
+		return writeSuggestions(env.Stdout, suggestions)
+	}
+	return printSuggestions(env.Stdout, suggestions, flagDiff)
+}
@@ -5,13 +5,17 @@
 package main
 
 import (
+	"bytes"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"go/build"
+	"io"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 
 	"v.io/jiri/runutil"
 )
@@ -21,15 +25,46 @@ type config struct {
 	PkgRules   []rule   `xml:"pkg"`
 	TestRules  []rule   `xml:"test"`
 	XTestRules []rule   `xml:"xtest"`
+	Layers     []layer  `xml:"layer"`
 	Path       string   `xml:"-"`
 }
 
+// rules returns the ordered rules of c that apply in the given mode.
+func (c *config) rules(mode checkMode) []rule {
+	switch mode {
+	case modeTest:
+		return append(append([]rule(nil), c.TestRules...), c.PkgRules...)
+	case modeXTest:
+		rules := append(append([]rule(nil), c.XTestRules...), c.TestRules...)
+		return append(rules, c.PkgRules...)
+	}
+	return c.PkgRules
+}
+
 type rule struct {
 	// The fields are pointers so that we can distinguish empty from unset values.
 	Allow *string `xml:"allow,attr,omitempty"`
 	Deny  *string `xml:"deny,attr,omitempty"`
+	// Except is a whitespace-separated list of patterns; packages matching any
+	// of these patterns are excluded from the rule.
+	Except string `xml:"except,attr,omitempty"`
+	// Files and Tags restrict the rule to dependencies pulled in by imports from
+	// particular files of the package.  Files is a whitespace-separated list of
+	// filename globs, and Tags is a whitespace-separated list of build tags; a
+	// file is in scope if its name matches any of the globs, or if its build
+	// constraints mention any of the tags and are satisfied when it is set,
+	// even if the file is excluded from the current build context.  The "cgo"
+	// tag also matches files that import "C".
+	Files string `xml:"files,attr,omitempty"`
+	Tags  string `xml:"tags,attr,omitempty"`
+	// Line is the line number of the rule in its config file.
+	Line int `xml:"-"`
 }
 
+// regexpPrefix is the prefix of patterns that are regular expressions, which
+// are matched against the full import path.
+const regexpPrefix = "regexp:"
+
 func (r rule) IsDeny() bool {
 	return r.Deny != nil
 }
@@ -44,26 +79,120 @@ func (r rule) Pattern() string {
 	return ""
 }
 
+func (r rule) Excepts() []string {
+	return strings.Fields(r.Except)
+}
+
+// IsScoped returns true iff the rule only applies to imports from some of the
+// files in a package.
+func (r rule) IsScoped() bool {
+	return r.Files != "" || r.Tags != ""
+}
+
 func (r rule) Validate() error {
 	switch {
 	case r.Allow == nil && r.Deny == nil:
 		return errNeitherAllowDeny
 	case r.Allow != nil && r.Deny != nil:
 		return errBothAllowDeny
-	case r.Allow != nil && *r.Allow != "":
-		return nil
-	case r.Deny != nil && *r.Deny != "":
-		return nil
+	case r.Pattern() == "":
+		return errEmptyRule
+	}
+	for _, pattern := range append([]string{r.Pattern()}, r.Excepts()...) {
+		if err := validatePattern(pattern); err != nil {
+			return err
+		}
 	}
-	return errEmptyRule
+	for _, glob := range strings.Fields(r.Files) {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid files glob %q: %v", glob, err)
+		}
+	}
+	return nil
+}
+
+// layer is an architectural layer.  Layers are declared from the lowest to the
+// highest, and packages may not depend on packages in higher layers.
+type layer struct {
+	Name string `xml:"name,attr"`
+	// Pkgs is a whitespace-separated list of patterns, matching the packages in
+	// the layer.
+	Pkgs string `xml:"pkgs,attr"`
+	// Line is the line number of the layer in its config file.
+	Line int `xml:"-"`
 }
 
-var configCache = map[string]*config{}
+func (l layer) Patterns() []string {
+	return strings.Fields(l.Pkgs)
+}
+
+func (l layer) Validate() error {
+	switch {
+	case l.Name == "":
+		return errEmptyLayerName
+	case len(l.Patterns()) == 0:
+		return errEmptyRule
+	}
+	for _, pattern := range l.Patterns() {
+		if err := validatePattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePattern returns an error if pattern is an invalid regexp pattern.
+// Valid patterns are compiled, so that they aren't recompiled when matching.
+func validatePattern(pattern string) error {
+	if _, err := compilePattern(pattern); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	return nil
+}
+
+var (
+	patternMu    sync.Mutex
+	patternCache = map[string]*regexp.Regexp{}
+)
+
+// compilePattern returns the regular expression matching the import paths of
+// the packages that match pattern.  The result is cached, so that each pattern
+// is only compiled once.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternMu.Lock()
+	defer patternMu.Unlock()
+	if re, ok := patternCache[pattern]; ok {
+		return re, nil
+	}
+	var expr string
+	if strings.HasPrefix(pattern, regexpPrefix) {
+		expr = strings.TrimPrefix(pattern, regexpPrefix)
+	} else {
+		expr = regexp.QuoteMeta(pattern)
+		if strings.HasSuffix(expr, `/\.\.\.`) {
+			expr = expr[:len(expr)-len(`/\.\.\.`)] + `(/.*)?`
+		}
+		expr = "^" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patternCache[pattern] = re
+	return re, nil
+}
+
+var (
+	configMu    sync.Mutex
+	configCache = map[string]*config{}
+)
 
 // loadConfig loads a .godepcop configuration file located at the specified
 // filesystem path.  If the call is successful, the output will be cached and
 // the same instance will be returned in subsequent calls.
 func loadConfig(path string) (*config, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	if p, ok := configCache[path]; ok {
 		return p, nil
 	}
@@ -85,6 +214,7 @@ var (
 	errNeitherAllowDeny = errors.New("neither allow nor deny is specified")
 	errEmptyRule        = errors.New("empty rule")
 	errNoRules          = errors.New("at least one rule must be specified")
+	errEmptyLayerName   = errors.New("empty layer name")
 )
 
 func parseConfig(data []byte) (*config, error) {
@@ -92,9 +222,12 @@ func parseConfig(data []byte) (*config, error) {
 	if err := xml.Unmarshal(data, c); err != nil {
 		return nil, err
 	}
-	if len(c.PkgRules) == 0 && len(c.TestRules) == 0 && len(c.XTestRules) == 0 {
+	if len(c.PkgRules) == 0 && len(c.TestRules) == 0 && len(c.XTestRules) == 0 && len(c.Layers) == 0 {
 		return nil, errNoRules
 	}
+	if err := setLines(c, data); err != nil {
+		return nil, err
+	}
 	for _, r := range c.PkgRules {
 		if err := r.Validate(); err != nil {
 			return nil, fmt.Errorf("pkg: %v", err)
@@ -110,9 +243,53 @@ func parseConfig(data []byte) (*config, error) {
 			return nil, fmt.Errorf("xtest: %v", err)
 		}
 	}
+	names := make(map[string]bool)
+	for _, l := range c.Layers {
+		if err := l.Validate(); err != nil {
+			return nil, fmt.Errorf("layer: %v", err)
+		}
+		if names[l.Name] {
+			return nil, fmt.Errorf("layer: duplicate name %q", l.Name)
+		}
+		names[l.Name] = true
+	}
 	return c, nil
 }
 
+// setLines sets the line number of each rule and layer in c, based on the
+// position of the corresponding element in data.
+func setLines(c *config, data []byte) error {
+	lines := make(map[string][]int)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		offset := dec.InputOffset()
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth++; depth == 2 {
+				lines[t.Name.Local] = append(lines[t.Name.Local], 1+bytes.Count(data[:offset], []byte("\n")))
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	for name, rules := range map[string][]rule{"pkg": c.PkgRules, "test": c.TestRules, "xtest": c.XTestRules} {
+		for i := 0; i < len(rules) && i < len(lines[name]); i++ {
+			rules[i].Line = lines[name][i]
+		}
+	}
+	for i := 0; i < len(c.Layers) && i < len(lines["layer"]); i++ {
+		c.Layers[i].Line = lines["layer"][i]
+	}
+	return nil
+}
+
 type configIter struct {
 	cfg   *config
 	err   error
@@ -147,13 +324,23 @@ func (c *configIter) Err() error     { return c.err }
 
 // newConfigIter returns an iterator over the .godepcop configuration files for
 // package p.  It starts at the config file in package p, and then travels up
-// successive directories until it reaches the root of the import path.
+// successive directories until it reaches the root of the module containing p,
+// or the root of the import path if p isn't in a module.
 func newConfigIter(p *build.Package) *configIter {
 	if isPseudoPackage(p) {
 		return &configIter{depth: -1}
 	}
+	depth := strings.Count(p.ImportPath, "/")
+	if root, ok := moduleRoot(p.ImportPath); ok {
+		if rel, err := filepath.Rel(root, p.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+			depth = 0
+			if rel != "." {
+				depth = strings.Count(filepath.ToSlash(rel), "/") + 1
+			}
+		}
+	}
 	return &configIter{
 		dir:   p.Dir,
-		depth: strings.Count(p.ImportPath, "/"),
+		depth: depth,
 	}
 }
@@ -16,6 +16,7 @@ import (
 
 var (
 	abc, xyz, dots = "abc", "xyz", "..."
+	reABC          = "regexp:^a.*$"
 
 	testConfigXML = `
 <godepcop>
@@ -28,9 +29,9 @@ var (
 `
 
 	testConfig = &config{
-		PkgRules:   []rule{{Allow: &abc}, {Allow: &xyz}, {Deny: &dots}},
-		TestRules:  []rule{{Allow: &dots}},
-		XTestRules: []rule{{Deny: &dots}},
+		PkgRules:   []rule{{Allow: &abc, Line: 3}, {Allow: &xyz, Line: 4}, {Deny: &dots, Line: 5}},
+		TestRules:  []rule{{Allow: &dots, Line: 6}},
+		XTestRules: []rule{{Deny: &dots, Line: 7}},
 	}
 )
 
@@ -69,15 +70,27 @@ func TestParseConfig(t *testing.T) {
 	}{
 		{
 			`<godepcop><pkg allow="..."/></godepcop>`,
-			&config{PkgRules: []rule{{Allow: &dots}}},
+			&config{PkgRules: []rule{{Allow: &dots, Line: 1}}},
 		},
 		{
 			`<godepcop><pkg deny="..."/></godepcop>`,
-			&config{PkgRules: []rule{{Deny: &dots}}},
+			&config{PkgRules: []rule{{Deny: &dots, Line: 1}}},
 		},
 		{
 			`<godepcop><pkg allow="abc"/><pkg deny="..."/></godepcop>`,
-			&config{PkgRules: []rule{{Allow: &abc}, {Deny: &dots}}},
+			&config{PkgRules: []rule{{Allow: &abc, Line: 1}, {Deny: &dots, Line: 1}}},
+		},
+		{
+			`<godepcop><pkg allow="regexp:^a.*$" except="abc xyz/..."/></godepcop>`,
+			&config{PkgRules: []rule{{Allow: &reABC, Except: "abc xyz/...", Line: 1}}},
+		},
+		{
+			`<godepcop><pkg deny="..." files="*_cgo.go x.go" tags="cgo"/></godepcop>`,
+			&config{PkgRules: []rule{{Deny: &dots, Files: "*_cgo.go x.go", Tags: "cgo", Line: 1}}},
+		},
+		{
+			"<godepcop>\n  <layer name=\"low\" pkgs=\"abc xyz/...\"/>\n  <layer name=\"high\" pkgs=\"...\"/>\n</godepcop>",
+			&config{Layers: []layer{{Name: "low", Pkgs: "abc xyz/...", Line: 2}, {Name: "high", Pkgs: "...", Line: 3}}},
 		},
 		{
 			testConfigXML,
@@ -133,6 +146,18 @@ func TestParseConfigError(t *testing.T) {
 			`<godepcop><pkg allow="x" deny="y"/></godepcop>`,
 			"pkg: both allow and deny are specified",
 		},
+		{
+			`<godepcop><pkg allow="regexp:(x"/></godepcop>`,
+			"pkg: invalid pattern \"regexp:(x\": error parsing regexp: missing closing ): `(x`",
+		},
+		{
+			`<godepcop><pkg allow="x" except="regexp:[x"/></godepcop>`,
+			"pkg: invalid pattern \"regexp:[x\": error parsing regexp: missing closing ]: `[x`",
+		},
+		{
+			`<godepcop><pkg allow="x" files="[x"/></godepcop>`,
+			"pkg: invalid files glob \"[x\": syntax error in pattern",
+		},
 		// Test rules
 		{
 			`<godepcop><test/></godepcop>`,
@@ -175,6 +200,23 @@ func TestParseConfigError(t *testing.T) {
 			`<godepcop><xtest allow="x" deny="y"/></godepcop>`,
 			"xtest: both allow and deny are specified",
 		},
+		// Layers
+		{
+			`<godepcop><layer pkgs="x"/></godepcop>`,
+			"layer: empty layer name",
+		},
+		{
+			`<godepcop><layer name="x"/></godepcop>`,
+			"layer: empty rule",
+		},
+		{
+			`<godepcop><layer name="x" pkgs="regexp:(x"/></godepcop>`,
+			"layer: invalid pattern \"regexp:(x\": error parsing regexp: missing closing ): `(x`",
+		},
+		{
+			`<godepcop><layer name="x" pkgs="a"/><layer name="x" pkgs="b"/></godepcop>`,
+			"layer: duplicate name \"x\"",
+		},
 	}
 	for _, test := range tests {
 		cfg, err := parseConfig([]byte(test.Data))
@@ -15,8 +15,12 @@ Usage:
 
 The godepcop commands are:
    check          Check package dependency constraints
+   baseline       Manage baseline files of tolerated violations
    list           List packages imported by the given packages
    list-importers List packages that import the given packages
+   why            Explain why a package depends on another package
+   cycles         List import cycles among the given packages
+   suggest        Suggest .godepcop rules based on current dependencies
    help           Display help for commands or topics
 
 The global flags are:
@@ -36,6 +40,11 @@ the source tree, until a matching rule is found.  If no matching rule is found,
 the default behavior is to allow the dependency, to support packages that do not
 have any dependency rules.
 
+Inside a Go module the files are traversed up to the module root, i.e. the
+directory containing go.mod, and packages are resolved according to the go.mod
+and go.work files, including replace directives and vendor directories.
+Outside of a module the files are traversed up to the root of the import path.
+
 The .godepcop file is encoded in XML:
 
   <godepcop>
@@ -51,6 +60,28 @@ on the given pattern.  Patterns that end with "/..." are special: "foo/..."
 means that foo and all its subpackages match the rule.  The special-case pattern
 "..."  means that all packages in GOPATH, but not GOROOT, match the rule.
 
+Patterns that start with "regexp:" are regular expressions, which are matched
+against the full import path.  Each rule may also specify optional attributes
+that restrict the rule:
+  except - Whitespace-separated patterns; packages matching any of these are
+           excluded from the rule, and are left to subsequent rules.
+  files  - Whitespace-separated filename globs; the rule only applies to
+           dependencies pulled in by imports from files matching a glob.
+  tags   - Whitespace-separated build tags; the rule only applies to
+           dependencies pulled in by imports from files whose build constraints
+           or GOOS/GOARCH file name suffixes mention a tag and are satisfied
+           when it is set, even if the files are excluded from the current
+           build, e.g. *_windows.go files on Linux.  The "cgo" tag also matches
+           files that import "C".
+A rule with both files and tags applies to files matching either of them.  For
+example:
+
+  <godepcop>
+    <pkg deny="regexp:^v\.io/x/ref/services/[^/]+/internal$"/>
+    <pkg allow="golang.org/x/..." except="golang.org/x/net/..."/>
+    <pkg deny="..." files="*_cgo.go" tags="cgo"/>
+  </godepcop>
+
 There are three groups of rules:
   pkg   - Rules applied to all imports from the package.
   test  - Extra rules for imports from all test files.
@@ -63,11 +94,102 @@ package P:
   P.Imports+P.TestImports                - check test and pkg rules
   P.Imports+P.TestImports+P.XTestImports - check xtest, test and pkg rules
 
+Each violation is reported with the source and destination packages, the mode
+(pkg, test or xtest) and, for deny rules, the rule pattern along with the path
+and line of the .godepcop file containing it.  The -format=json and
+-format=sarif flags additionally report the chain of imports from the source to
+the destination package, for consumption by other tools, and the -format=dot
+flag graphs these chains, e.g. to show the upward imports between layers.
+
+Set the -baseline flag to only fail on violations that aren't recorded in the
+given baseline file; if the file doesn't exist, it is created with the current
+violations.  Violations are reported as new, baseline (still present) or
+resolved.  Use "godepcop baseline update" to remove resolved violations.
+
+Packages are checked concurrently; see the -num-workers flag.  Set the -cache
+flag to persist the results of checking each package in the given directory.
+Subsequent runs only recheck packages whose files, .godepcop files or
+transitive imports have changed.
+
+A .godepcop file may also declare architectural layers, from the lowest layer to
+the highest layer:
+
+  <godepcop>
+    <layer name="api" pkgs="v.io/v23/..."/>
+    <layer name="lib" pkgs="v.io/x/ref/lib/..."/>
+    <layer name="services" pkgs="v.io/x/ref/services/..."/>
+    <layer name="cmd" pkgs="v.io/x/ref/cmd/..."/>
+  </godepcop>
+
+The pkgs attribute is a whitespace-separated list of patterns, and each package
+belongs to the first layer with a matching pattern.  The layers of a package are
+taken from the nearest .godepcop file that declares any layers.  A package in a
+layer may not depend on packages in higher layers, either directly or through
+packages that aren't in any layer.  Only imports from non-test files are checked
+against the layers.
+
 Usage:
    godepcop check [flags] <packages>
 
 <packages> is a list of packages to check
 
+The godepcop check flags are:
+ -baseline=
+   Path of the baseline file, which records violations that are tolerated.
+ -cache=
+   Directory in which to cache the results of checking each package; if empty,
+   no cache is used.
+ -format=text
+   Print violations in the given format:
+      text  - As human-readable lines.
+      json  - As a JSON array of violations.
+      sarif - As a SARIF 2.1.0 log (https://sarifweb.azurewebsites.net)
+      dot   - As a DOT graph of the chains of imports of the violations, with
+              the imports of disallowed packages, e.g. upward imports between
+              layers, highlighted.
+ -num-workers=<runtime.NumCPU()>
+   Number of packages to check concurrently.
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
+
+Godepcop baseline - Manage baseline files of tolerated violations
+
+Manage baseline files of tolerated violations.
+
+A baseline file records violations that are tolerated by "check -baseline",
+which allows stricter rules to be adopted in large source trees without first
+fixing every existing violation.
+
+Usage:
+   godepcop baseline [flags] <command>
+
+The godepcop baseline commands are:
+   update      Remove resolved violations from a baseline file
+
+Godepcop baseline update - Remove resolved violations from a baseline file
+
+Check the given <packages>, and remove violations of these packages that have
+been resolved from the baseline file given by the -baseline flag.  New
+violations are never added, so the baseline can only shrink over time.
+
+Usage:
+   godepcop baseline update [flags] <packages>
+
+<packages> is a list of packages to check
+
+The godepcop baseline update flags are:
+ -baseline=
+   Path of the baseline file, which records violations that are tolerated.
+ -cache=
+   Directory in which to cache the results of checking each package; if empty,
+   no cache is used.
+ -num-workers=<runtime.NumCPU()>
+   Number of packages to check concurrently.
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
+
 Godepcop list - List packages imported by the given packages
 
 List packages imported by the given <packages>.
@@ -99,6 +221,9 @@ The godepcop list flags are:
       set    - As a sorted set of unique packages.
       indent - As a hierarchical list with pretty indentation.
       dot    - As a DOT graph (http://www.graphviz.org)
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
  -test=false
    Show imports from test files in the same package.
  -xtest=false
@@ -130,11 +255,111 @@ The godepcop list-importers flags are:
    Show $GOROOT packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
  -test=false
    Show imports from test files in the same package.
  -xtest=false
    Show imports from test files in the same package or in the *_test package.
 
+Godepcop why - Explain why a package depends on another package
+
+Explain why package <src> depends on package <dst>, by printing the shortest
+chain of imports from <src> to <dst>.  Set the -all flag to print all chains.
+
+Each import in a chain is annotated with the .godepcop rule of the importing
+package that decided the import, along with the path and line of the .godepcop
+file containing the rule.  The first import is checked with the pkg, test or
+xtest rules, depending on where it is imported; all other imports are checked
+with the pkg rules.
+
+Only follows imports from non-test files by default; set the -test or -xtest
+flags to also follow imports from test files of <src>.
+
+Usage:
+   godepcop why [flags] <src> <dst>
+
+<src> is the importing package, and <dst> is the import path of the imported
+package
+
+The godepcop why flags are:
+ -all=false
+   Show all chains of imports, rather than only the shortest chain.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
+ -test=false
+   Follow imports from test files in the same package.
+ -xtest=false
+   Follow imports from test files in the same package or in the *_test package.
+
+Godepcop cycles - List import cycles among the given packages
+
+List import cycles among the given <packages> and their transitive dependencies.
+Each cycle is a set of packages that all depend on each other.  Fails if any
+cycles are found.
+
+The go tool rejects cycles of ordinary imports, so cycles are typically only
+found with the -test flag, which includes imports from test files in the same
+package.  Such cycles prevent the tests from being built.  $GOROOT packages are
+always elided.
+
+Usage:
+   godepcop cycles [flags] <packages>
+
+<packages> is a list of packages
+
+The godepcop cycles flags are:
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -style=set
+   List cycles with the given style:
+      set - As a list of the packages in each cycle, with their imports.
+      dot - As a DOT graph (http://www.graphviz.org)
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
+ -test=false
+   Include imports from test files in the same package.
+
+Godepcop suggest - Suggest .godepcop rules based on current dependencies
+
+Suggest a .godepcop file for each of the given <packages>, which allows the
+current dependencies of the package and denies all other dependencies.
+
+The pkg rules allow the transitive dependencies of the package, the test rules
+allow the extra dependencies of test files in the same package, and the xtest
+rules allow the extra dependencies of test files in the *_test package.
+Dependencies are collapsed into "/..." patterns where possible, i.e. when the
+pattern matches at least two dependencies, and every other package matching the
+pattern that is known to godepcop is also a dependency.  The known packages are
+the given <packages> and their transitive dependencies.  $GOROOT packages are
+always allowed, and are never mentioned in the rules.
+
+Prints the suggested files by default.  Set the -diff flag to print the
+differences against existing files, or the -write flag to write the files.
+Suggestions don't take the .godepcop files of parent directories into account.
+
+Usage:
+   godepcop suggest [flags] <packages>
+
+<packages> is a list of packages
+
+The godepcop suggest flags are:
+ -diff=false
+   Print the differences between the suggested and existing .godepcop files, as
+   unified diffs.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
+ -write=false
+   Write the suggested .godepcop files, replacing existing files.
+
 Godepcop help - Display help for commands or topics
 
 Help with no args displays the usage of the parent command.
@@ -11,12 +11,14 @@ import (
 	"strings"
 )
 
-func printDot(w io.Writer, pkgs []*build.Package, opts depOpts) error {
-	fmt.Fprintf(w, `digraph {
+const dotHeader = `digraph {
   node[shape=record,style=solid]
   edge[arrowhead=vee]
   graph[rankdir=TB,splines=true]
-`)
+`
+
+func printDot(w io.Writer, pkgs []*build.Package, opts depOpts) error {
+	fmt.Fprint(w, dotHeader)
 	// Print edges for each package in pkgs, possibly transitively.
 	printed := make(map[*build.Package]bool)
 	ids := make(map[*build.Package]int)
@@ -78,3 +80,53 @@ func printDotEdges(w io.Writer, opts depOpts, printed map[*build.Package]bool, i
 	}
 	return nil
 }
+
+// printViolationsDot prints the chains of imports of violations to w as a DOT
+// graph, with the same style as printDot.  The last import of each chain, i.e.
+// the import of the disallowed package, such as the upward import of a layer
+// violation, is highlighted.
+func printViolationsDot(w io.Writer, violations []violation) {
+	fmt.Fprint(w, dotHeader)
+	type edge struct{ src, dst int }
+	ids := make(map[string]int)
+	id := func(path string) int {
+		if _, ok := ids[path]; !ok {
+			ids[path] = len(ids)
+		}
+		return ids[path]
+	}
+	var edges []edge
+	seen := make(map[edge]bool)
+	highlighted := make(map[edge]bool)
+	for _, v := range violations {
+		chain := v.Chain
+		if len(chain) < 2 {
+			chain = []string{v.Src.ImportPath, v.Dst.ImportPath}
+		}
+		for i := 1; i < len(chain); i++ {
+			e := edge{id(chain[i-1]), id(chain[i])}
+			if !seen[e] {
+				seen[e] = true
+				edges = append(edges, e)
+			}
+			if i == len(chain)-1 {
+				highlighted[e] = true
+			}
+		}
+	}
+	for _, e := range edges {
+		attrs := ""
+		if highlighted[e] {
+			attrs = "[color=red]"
+		}
+		fmt.Fprintf(w, "  %d->%d%s\n", e.src, e.dst, attrs)
+	}
+	idToPath := make([]string, len(ids))
+	for path, id := range ids {
+		idToPath[id] = path
+	}
+	for id, path := range idToPath {
+		fmt.Fprintf(w, "  %d[label=%q]\n", id, path)
+	}
+	fmt.Fprintf(w, "}\n")
+}
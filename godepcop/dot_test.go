@@ -117,3 +117,29 @@ func TestPrintDot(t *testing.T) {
 		}
 	}
 }
+
+func TestPrintViolationsDot(t *testing.T) {
+	p, err := importPackage(layersPrefix + "low")
+	if err != nil {
+		t.Fatalf("importPackage failed: %v", err)
+	}
+	violations, err := checkLayers(p)
+	if err != nil {
+		t.Fatalf("checkLayers failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printViolations(&buf, formatDot, violations); err != nil {
+		t.Fatalf("printViolations failed: %v", err)
+	}
+	// The upward import of high by mid is highlighted.
+	want := dotHeader + `  0->1
+  1->2[color=red]
+  0[label="` + layersPrefix + `low"]
+  1[label="` + layersPrefix + `mid"]
+  2[label="` + layersPrefix + `high"]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -8,8 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"go/build"
-	"regexp"
 	"strings"
+	"sync"
 )
 
 type result int
@@ -24,33 +24,51 @@ func (r result) String() string {
 	return []string{"undecided", "approved", "rejected"}[int(r)]
 }
 
+// violation describes a dependency from Src to Dst that isn't allowed.
 type violation struct {
 	Src, Dst *build.Package
 	Err      error
+	Mode     checkMode // The mode in which the dependency was checked.
+	Rule     *rule     // The deny rule that rejected Dst, or nil.
+	Layer    *layer    // The layer of Dst if the layering is violated, or nil.
+	Config   string    // The path of the config file containing Rule or Layer.
+	Chain    []string  // The import paths from Src to Dst, inclusive.
 }
 
-func enforceRule(r rule, pkg *build.Package) (result, error) {
-	if r.Pattern() == "..." {
-		switch {
-		case pkg.Goroot:
-			return resultUndecided, nil
-		case r.IsDeny():
-			return resultRejected, nil
-		}
-		return resultApproved, nil
-	}
+func (v violation) String() string {
+	return fmt.Sprintf("%q not allowed to import %q (%v)", v.Src.ImportPath, v.Dst.ImportPath, v.Err)
+}
 
-	re := regexp.QuoteMeta(r.Pattern())
-	if strings.HasSuffix(re, `/\.\.\.`) {
-		re = re[:len(re)-len(`/\.\.\.`)] + `(/.*)?`
+// matchPattern returns true iff pkg matches the given rule pattern.
+func matchPattern(pattern string, pkg *build.Package) (bool, error) {
+	if pattern == "..." {
+		return !pkg.Goroot, nil
+	}
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return false, err
 	}
+	return re.MatchString(pkg.ImportPath), nil
+}
 
-	switch matched, err := regexp.MatchString("^"+re+"$", pkg.ImportPath); {
+func enforceRule(r rule, pkg *build.Package) (result, error) {
+	switch matched, err := matchPattern(r.Pattern(), pkg); {
 	case err != nil:
 		return resultUndecided, err
 	case !matched:
 		return resultUndecided, nil
-	case r.IsDeny():
+	}
+	// Packages matching an exception are left undecided, so that they may be
+	// matched by subsequent rules.
+	for _, except := range r.Excepts() {
+		switch matched, err := matchPattern(except, pkg); {
+		case err != nil:
+			return resultUndecided, err
+		case matched:
+			return resultUndecided, nil
+		}
+	}
+	if r.IsDeny() {
 		return resultRejected, nil
 	}
 	return resultApproved, nil
@@ -82,40 +100,54 @@ func verifyGo15InternalRule(src, dst string) bool {
 
 var errGo15Internal = errors.New("violates Go 1.5 internal package rule")
 
-func checkDep(pkg, dep *build.Package, mode checkMode) (*violation, error) {
+// matchRule returns the first rule in the config files for pkg that approves or
+// rejects dep in the given mode, along with the result and the path of the
+// config file containing the rule.  Returns a nil rule and resultUndecided if no
+// rule matches.
+func matchRule(pkg, dep *build.Package, mode checkMode) (*rule, string, result, error) {
 	it := newConfigIter(pkg)
 	for it.Advance() {
 		// Collect the ordered rules from this config for the given mode.
 		cfg := it.Value()
-		var rules []rule
-		switch mode {
-		case modePkg:
-			rules = cfg.PkgRules
-		case modeTest:
-			rules = append(cfg.TestRules, cfg.PkgRules...)
-		case modeXTest:
-			rules = append(cfg.XTestRules, cfg.TestRules...)
-			rules = append(rules, cfg.PkgRules...)
-		}
+		rules := cfg.rules(mode)
 		// Enforce each rule in order.
-		for _, rule := range rules {
-			switch result, err := enforceRule(rule, dep); {
+		for i := range rules {
+			if rules[i].IsScoped() {
+				// Scoped rules only apply to dependencies pulled in by some files.
+				deps, err := scopedDeps(pkg, rules[i], mode)
+				if err != nil {
+					return nil, "", resultUndecided, err
+				}
+				if deps[dep.ImportPath] == nil {
+					continue
+				}
+			}
+			switch result, err := enforceRule(rules[i], dep); {
 			case err != nil:
-				return nil, err
-			case result == resultApproved:
-				return nil, nil
-			case result == resultRejected:
-				err := fmt.Errorf(`violates %s deny rule %q in %s`, mode, rule.Pattern(), cfg.Path)
-				return &violation{pkg, dep, err}, nil
+				return nil, "", resultUndecided, err
+			case result != resultUndecided:
+				return &rules[i], cfg.Path, result, nil
 			}
 		}
 	}
 	if err := it.Err(); err != nil {
+		return nil, "", resultUndecided, err
+	}
+	return nil, "", resultUndecided, nil
+}
+
+func checkDep(pkg, dep *build.Package, mode checkMode) (*violation, error) {
+	rule, path, result, err := matchRule(pkg, dep, mode)
+	if err != nil {
 		return nil, err
 	}
-	// All config files have been checked without an approved or rejected result;
-	// treat this as an approved result.  This also handles the case where no
-	// config files have been specified.
+	if result == resultRejected {
+		err := fmt.Errorf(`violates %s deny rule %q in %s`, mode, rule.Pattern(), path)
+		return &violation{Src: pkg, Dst: dep, Err: err, Mode: mode, Rule: rule, Config: path}, nil
+	}
+	// Either an approved result, or all config files have been checked without
+	// an approved or rejected result; treat the latter as an approved result.
+	// This also handles the case where no config files have been specified.
 	return nil, nil
 }
 
@@ -129,7 +161,9 @@ func checkDeps(pkg *build.Package) ([]violation, error) {
 	}
 	for _, dep := range sortPackages(depsDirect) {
 		if !verifyGo15InternalRule(pkg.ImportPath, dep.ImportPath) {
-			violations = append(violations, violation{pkg, dep, errGo15Internal})
+			mode := importMode(pkg, dep.ImportPath)
+			chain := []string{pkg.ImportPath, dep.ImportPath}
+			violations = append(violations, violation{Src: pkg, Dst: dep, Err: errGo15Internal, Mode: mode, Chain: chain})
 		}
 	}
 	// Now check transitive dependencies against the rules in .godepcop files.
@@ -148,19 +182,88 @@ func checkDeps(pkg *build.Package) ([]violation, error) {
 		if err := opts.Deps(pkg, deps); err != nil {
 			return nil, err
 		}
+		// Dependencies pulled in by files in scope of rules with tags are
+		// checked even if the files are excluded from the current build
+		// context.
+		tagged, err := taggedDeps(pkg, mode)
+		if err != nil {
+			return nil, err
+		}
+		for path, dep := range tagged {
+			if deps[path] == nil {
+				deps[path] = dep
+			}
+		}
 		for _, dep := range sortPackages(deps) {
 			v, err := checkDep(pkg, dep, mode)
 			if err != nil {
 				return nil, err
 			}
 			if v != nil {
+				if v.Chain, err = opts.Chain(pkg, dep.ImportPath); err != nil {
+					return nil, err
+				}
 				violations = append(violations, *v)
 			}
 		}
 	}
+	// Finally check the architectural layers.
+	layerViolations, err := checkLayers(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return append(violations, layerViolations...), nil
+}
+
+// checkAll checks pkgs concurrently with the given number of workers, using
+// cache if it is non-nil.  The violations are returned in the order of pkgs.
+func checkAll(pkgs []*build.Package, workers int, cache *diskCache) ([]violation, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([][]violation, len(pkgs))
+	errs := make([]error, len(pkgs))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i], errs[i] = checkDepsCached(pkgs[i], cache)
+			}
+		}()
+	}
+	for i := range pkgs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	var violations []violation
+	for i := range pkgs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		violations = append(violations, results[i]...)
+	}
 	return violations, nil
 }
 
+// importMode returns the narrowest mode in which pkg directly imports path.
+func importMode(pkg *build.Package, path string) checkMode {
+	for _, imp := range pkg.Imports {
+		if imp == path {
+			return modePkg
+		}
+	}
+	for _, imp := range pkg.TestImports {
+		if imp == path {
+			return modeTest
+		}
+	}
+	return modeXTest
+}
+
 type checkMode int
 
 const (
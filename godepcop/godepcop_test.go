@@ -12,6 +12,16 @@ import (
 func allow(expr string) rule         { return rule{Allow: &expr} }
 func deny(expr string) rule          { return rule{Deny: &expr} }
 func pkg(path string) *build.Package { return &build.Package{ImportPath: path} }
+func allowExcept(expr, except string) rule {
+	r := allow(expr)
+	r.Except = except
+	return r
+}
+func denyExcept(expr, except string) rule {
+	r := deny(expr)
+	r.Except = except
+	return r
+}
 func pkgGoroot(path string) *build.Package {
 	p := pkg(path)
 	p.Goroot = true
@@ -46,6 +56,22 @@ func TestEnforceRule(t *testing.T) {
 		{allow("foo/..."), pkg("foo/a/b/c"), resultApproved},
 		{allow("foo/..."), pkg("bar"), resultUndecided},
 		{allow("foo/..."), pkg("bar/foo"), resultUndecided},
+
+		{deny(`regexp:^foo/.*/internal$`), pkg("foo/a/internal"), resultRejected},
+		{deny(`regexp:^foo/.*/internal$`), pkg("foo/a/b/internal"), resultRejected},
+		{deny(`regexp:^foo/.*/internal$`), pkg("foo/internal"), resultUndecided},
+		{deny(`regexp:^foo/.*/internal$`), pkg("foo/a/internal/b"), resultUndecided},
+		{allow(`regexp:/a$`), pkg("foo/a"), resultApproved},
+		{allow(`regexp:/a$`), pkg("foo/ab"), resultUndecided},
+
+		{allowExcept("foo/...", "foo/b/..."), pkg("foo/a"), resultApproved},
+		{allowExcept("foo/...", "foo/b/..."), pkg("foo/b"), resultUndecided},
+		{allowExcept("foo/...", "foo/b/..."), pkg("foo/b/c"), resultUndecided},
+		{denyExcept("...", "foo bar/..."), pkg("foo"), resultUndecided},
+		{denyExcept("...", "foo bar/..."), pkg("bar/a"), resultUndecided},
+		{denyExcept("...", "foo bar/..."), pkg("baz"), resultRejected},
+		{denyExcept("...", "regexp:^f"), pkg("foo"), resultUndecided},
+		{denyExcept("...", "regexp:^f"), pkg("goo"), resultRejected},
 	}
 	for _, test := range tests {
 		result, err := enforceRule(test.rule, test.pkg)
@@ -144,6 +170,10 @@ func TestCheckDeps(t *testing.T) {
 		{"v.io/x/devtools/godepcop/testdata/test-d", false},
 		{"v.io/x/devtools/godepcop/testdata/test-e", false},
 		{"v.io/x/devtools/godepcop/testdata/test-f", false},
+		{"v.io/x/devtools/godepcop/testdata/test-scoped", false},
+		{"v.io/x/devtools/godepcop/testdata/test-layers/low", false},
+		{"v.io/x/devtools/godepcop/testdata/test-layers/mid", true},
+		{"v.io/x/devtools/godepcop/testdata/test-layers/high", true},
 		{"v.io/x/devtools/godepcop/testdata/test-internal", true},
 		{"v.io/x/devtools/godepcop/testdata/test-internal/child", true},
 		{"v.io/x/devtools/godepcop/testdata/test-internal/internal/child", true},
@@ -167,3 +197,69 @@ func TestCheckDeps(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchRuleScoped(t *testing.T) {
+	tests := []struct {
+		tags   []string
+		dep    string
+		result result
+		line   int
+	}{
+		// Without the "special" tag, the files with the "special" tag aren't
+		// loaded, but they are still in scope of rules with the "special"
+		// tag, while the files with the "!special" constraint aren't, since
+		// they are excluded by it.  Likewise, *_plan9.go files are in scope of
+		// rules with the "plan9" tag.
+		{nil, "fmt", resultRejected, 4},
+		{nil, "strings", resultRejected, 3},
+		{nil, "text/tabwriter", resultUndecided, 0},
+		{nil, "errors", resultApproved, 5},
+		{nil, "bytes", resultRejected, 6},
+		{nil, "io", resultUndecided, 0},
+		{nil, "encoding/csv", resultRejected, 8},
+		// The first rule doesn't apply to fmt, since fmt isn't imported by any
+		// files with the "special" tag.
+		{[]string{"special"}, "fmt", resultRejected, 4},
+		{[]string{"special"}, "strings", resultRejected, 3},
+		{[]string{"special"}, "text/tabwriter", resultUndecided, 0},
+		{[]string{"special"}, "errors", resultApproved, 5},
+		{[]string{"special"}, "bytes", resultRejected, 6},
+		{[]string{"special"}, "io", resultUndecided, 0},
+		{[]string{"special"}, "encoding/csv", resultRejected, 8},
+	}
+	defer func(tags []string) {
+		build.Default.BuildTags = tags
+		pkgCache = map[string]*build.Package{"C": pseudoPackageC, "unsafe": pseudoPackageUnsafe}
+		scopeCache = map[string]map[string]*build.Package{}
+	}(build.Default.BuildTags)
+	for _, test := range tests {
+		// Packages are cached by import path, so each set of build tags needs a
+		// fresh cache.
+		build.Default.BuildTags = test.tags
+		pkgCache = map[string]*build.Package{"C": pseudoPackageC, "unsafe": pseudoPackageUnsafe}
+		scopeCache = map[string]map[string]*build.Package{}
+		p, err := importPackage("v.io/x/devtools/godepcop/testdata/test-scoped")
+		if err != nil {
+			t.Fatalf("importPackage failed: %v", err)
+		}
+		dep, err := importPackage(test.dep)
+		if err != nil {
+			t.Fatalf("importPackage(%q) failed: %v", test.dep, err)
+		}
+		rule, _, result, err := matchRule(p, dep, modePkg)
+		if err != nil {
+			t.Errorf("%v %s failed: %v", test.tags, test.dep, err)
+			continue
+		}
+		if got, want := result, test.result; got != want {
+			t.Errorf("%v %s got %v, want %v", test.tags, test.dep, got, want)
+		}
+		line := 0
+		if rule != nil {
+			line = rule.Line
+		}
+		if got, want := line, test.line; got != want {
+			t.Errorf("%v %s got rule at line %d, want %d", test.tags, test.dep, got, want)
+		}
+	}
+}
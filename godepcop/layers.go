@@ -0,0 +1,248 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io"
+	"sort"
+	"strings"
+)
+
+// findLayers returns the layers that apply to pkg, along with the path of the
+// config file that declares them.  The layers are taken from the first config
+// file for pkg that declares any layers.
+func findLayers(pkg *build.Package) ([]layer, string, error) {
+	it := newConfigIter(pkg)
+	for it.Advance() {
+		if cfg := it.Value(); len(cfg.Layers) > 0 {
+			return cfg.Layers, cfg.Path, nil
+		}
+	}
+	return nil, "", it.Err()
+}
+
+// layerIndex returns the index of the first layer that contains pkg, or -1 if
+// pkg isn't in any layer.
+func layerIndex(layers []layer, pkg *build.Package) (int, error) {
+	for i, l := range layers {
+		for _, pattern := range l.Patterns() {
+			switch matched, err := matchPattern(pattern, pkg); {
+			case err != nil:
+				return -1, err
+			case matched:
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// checkLayers checks that pkg doesn't depend on packages in layers higher than
+// its own layer.  Packages that aren't in any layer are unconstrained, but may
+// be part of a chain of imports that violates the layering.  Only imports from
+// non-test files are checked.
+func checkLayers(pkg *build.Package) ([]violation, error) {
+	layers, path, err := findLayers(pkg)
+	if err != nil || len(layers) == 0 {
+		return nil, err
+	}
+	src, err := layerIndex(layers, pkg)
+	if err != nil || src == -1 {
+		return nil, err
+	}
+	opts := depOpts{}
+	deps := make(map[string]*build.Package)
+	if err := opts.Deps(pkg, deps); err != nil {
+		return nil, err
+	}
+	var violations []violation
+	for _, dep := range sortPackages(deps) {
+		dst, err := layerIndex(layers, dep)
+		if err != nil {
+			return nil, err
+		}
+		if dst <= src {
+			continue
+		}
+		chain, err := opts.Chain(pkg, dep.ImportPath)
+		if err != nil {
+			return nil, err
+		}
+		err = fmt.Errorf("violates layering: layer %q may not depend on higher layer %q in %s", layers[src].Name, layers[dst].Name, path)
+		violations = append(violations, violation{
+			Src:    pkg,
+			Dst:    dep,
+			Err:    err,
+			Mode:   modePkg,
+			Layer:  &layers[dst],
+			Config: path,
+			Chain:  chain,
+		})
+	}
+	return violations, nil
+}
+
+// depGraph maps from import path to the import paths of direct dependencies.
+type depGraph map[string][]string
+
+// newDepGraph returns the dependency graph of pkgs, according to opts.
+func newDepGraph(pkgs []*build.Package, opts depOpts) (depGraph, error) {
+	graph := make(depGraph)
+	deps := make(map[string]*build.Package)
+	for _, pkg := range pkgs {
+		graph[pkg.ImportPath] = opts.Paths(pkg)
+		if err := opts.Deps(pkg, deps); err != nil {
+			return nil, err
+		}
+	}
+	for path, dep := range deps {
+		if _, ok := graph[path]; ok {
+			continue
+		}
+		graph[path] = dep.Imports
+	}
+	// Remove edges to packages that aren't in the graph, e.g. $GOROOT packages.
+	for path, imports := range graph {
+		var edges []string
+		for _, imp := range imports {
+			if _, ok := graph[imp]; ok {
+				edges = append(edges, imp)
+			}
+		}
+		graph[path] = edges
+	}
+	return graph, nil
+}
+
+// findCycles returns the import cycles in graph.  Each cycle is a sorted list
+// of the packages in a strongly connected component of the graph, and the
+// cycles are sorted by their first package.
+func findCycles(graph depGraph) [][]string {
+	// Tarjan's strongly connected components algorithm.
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]string
+	)
+	var visit func(path string)
+	visit = func(path string) {
+		index[path] = len(index)
+		lowlink[path] = index[path]
+		stack = append(stack, path)
+		onStack[path] = true
+		for _, imp := range graph[path] {
+			if _, ok := index[imp]; !ok {
+				visit(imp)
+				if lowlink[imp] < lowlink[path] {
+					lowlink[path] = lowlink[imp]
+				}
+			} else if onStack[imp] && index[imp] < lowlink[path] {
+				lowlink[path] = index[imp]
+			}
+		}
+		if lowlink[path] != index[path] {
+			return
+		}
+		var scc []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == path {
+				break
+			}
+		}
+		if len(scc) > 1 || hasEdge(graph, path, path) {
+			sort.Strings(scc)
+			cycles = append(cycles, scc)
+		}
+	}
+	var paths []string
+	for path := range graph {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, ok := index[path]; !ok {
+			visit(path)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+func hasEdge(graph depGraph, src, dst string) bool {
+	for _, imp := range graph[src] {
+		if imp == dst {
+			return true
+		}
+	}
+	return false
+}
+
+// cycleEdges returns the edges of graph within the given cycle.
+func cycleEdges(graph depGraph, cycle []string) map[string][]string {
+	members := make(map[string]bool)
+	for _, path := range cycle {
+		members[path] = true
+	}
+	edges := make(map[string][]string)
+	for _, path := range cycle {
+		for _, imp := range graph[path] {
+			if members[imp] {
+				edges[path] = append(edges[path], imp)
+			}
+		}
+	}
+	return edges
+}
+
+// printCycles prints each cycle in cycles to w, along with the imports within
+// the cycle.
+func printCycles(w io.Writer, graph depGraph, cycles [][]string) {
+	for i, cycle := range cycles {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "cycle of %d packages:\n", len(cycle))
+		edges := cycleEdges(graph, cycle)
+		for _, path := range cycle {
+			fmt.Fprintf(w, "  %s -> %s\n", path, strings.Join(edges[path], ", "))
+		}
+	}
+}
+
+// printCyclesDot prints cycles to w as a DOT graph, with the same style as
+// printDot.  The edges within each cycle are highlighted.
+func printCyclesDot(w io.Writer, graph depGraph, cycles [][]string) {
+	fmt.Fprint(w, dotHeader)
+	ids := make(map[string]int)
+	for _, cycle := range cycles {
+		for _, path := range cycle {
+			ids[path] = len(ids)
+		}
+	}
+	for _, cycle := range cycles {
+		edges := cycleEdges(graph, cycle)
+		for _, path := range cycle {
+			var depIDs []string
+			for _, imp := range edges[path] {
+				depIDs = append(depIDs, fmt.Sprintf("%d", ids[imp]))
+			}
+			fmt.Fprintf(w, "  %d->{%s}[color=red]\n", ids[path], strings.Join(depIDs, " "))
+		}
+	}
+	for _, cycle := range cycles {
+		for _, path := range cycle {
+			fmt.Fprintf(w, "  %d[label=%q]\n", ids[path], path)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	layersPrefix = "v.io/x/devtools/godepcop/testdata/test-layers/"
+	cyclePrefix  = "v.io/x/devtools/godepcop/testdata/test-cycle/"
+)
+
+func TestCheckLayers(t *testing.T) {
+	tests := []struct {
+		pkg         string
+		dsts        []string
+		layer, path string
+	}{
+		// low depends on high through mid, which isn't in any layer.
+		{"low", []string{"high"}, "high", "low -> mid -> high"},
+		{"mid", nil, "", ""},
+		{"high", nil, "", ""},
+		{"other", nil, "", ""},
+	}
+	for _, test := range tests {
+		p, err := importPackage(layersPrefix + test.pkg)
+		if err != nil {
+			t.Fatalf("importPackage failed: %v", err)
+		}
+		violations, err := checkLayers(p)
+		if err != nil {
+			t.Errorf("%s checkLayers failed: %v", test.pkg, err)
+			continue
+		}
+		var dsts []string
+		for _, v := range violations {
+			dsts = append(dsts, v.Dst.ImportPath[len(layersPrefix):])
+			if got, want := v.Layer.Name, test.layer; got != want {
+				t.Errorf("%s got layer %q, want %q", test.pkg, got, want)
+			}
+			if got, want := filepath.Base(filepath.Dir(v.Config)), "test-layers"; got != want {
+				t.Errorf("%s got config %q, want it in %q", test.pkg, v.Config, want)
+			}
+			var chain []string
+			for _, path := range v.Chain {
+				chain = append(chain, path[len(layersPrefix):])
+			}
+			if got, want := strings.Join(chain, " -> "), test.path; got != want {
+				t.Errorf("%s got chain %q, want %q", test.pkg, got, want)
+			}
+		}
+		if got, want := dsts, test.dsts; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s got violations %v, want %v", test.pkg, got, want)
+		}
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	a, err := importPackage(cyclePrefix + "a")
+	if err != nil {
+		t.Fatalf("importPackage failed: %v", err)
+	}
+	tests := []struct {
+		opts depOpts
+		want [][]string
+	}{
+		{depOpts{}, nil},
+		// The cycle is only formed by imports from the test files of a.
+		{depOpts{IncludeTest: true}, [][]string{{cyclePrefix + "a", cyclePrefix + "b"}}},
+	}
+	for _, test := range tests {
+		graph, err := newDepGraph([]*build.Package{a}, test.opts)
+		if err != nil {
+			t.Fatalf("newDepGraph failed: %v", err)
+		}
+		if got, want := findCycles(graph), test.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v got %v, want %v", test.opts, got, want)
+		}
+	}
+}
+
+func TestFindCyclesGraph(t *testing.T) {
+	graph := depGraph{
+		"a": {"b"},
+		"b": {"c", "d"},
+		"c": {"a"},
+		"d": {"d", "e"},
+		"e": nil,
+	}
+	want := [][]string{{"a", "b", "c"}, {"d"}}
+	if got := findCycles(graph); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	printCycles(&buf, graph, want)
+	wantText := `cycle of 3 packages:
+  a -> b
+  b -> c
+  c -> a
+
+cycle of 1 packages:
+  d -> d
+`
+	if got := buf.String(); got != wantText {
+		t.Errorf("got %q, want %q", got, wantText)
+	}
+}
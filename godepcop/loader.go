@@ -0,0 +1,345 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// modLoader loads packages via golang.org/x/tools/go/packages, which
+// understands Go modules, replace directives, vendor directories and go.work
+// workspaces.  The loaded packages are converted into *build.Package values so
+// that the rest of godepcop is independent of the loading mechanism.
+//
+// The module root of each loaded package is recorded in moduleRoots, so that
+// .godepcop files are searched up to the directory containing go.mod, rather
+// than up to the root of the import path.
+type modLoader struct {
+	dir  string   // Directory in which to run the underlying go tool.
+	env  []string // Environment for the underlying go tool.
+	tags []string // Build tags.
+}
+
+var (
+	// loader is non-nil iff packages are loaded in module mode.
+	loader *modLoader
+	// moduleRoots maps from import path to module root directory, for each
+	// package loaded by loader.  Guarded by pkgMu.
+	moduleRoots = map[string]string{}
+)
+
+// moduleRoot returns the root directory of the module containing the package
+// with the given import path, if the package was loaded by loader.
+func moduleRoot(path string) (string, bool) {
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+	root, ok := moduleRoots[path]
+	return root, ok
+}
+
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule
+
+// findModuleRoot returns the nearest directory at or above dir that contains
+// either a go.mod or a go.work file, or "" if there is no such directory.
+func findModuleRoot(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		for _, name := range []string{"go.mod", "go.work"} {
+			if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// newModLoader returns a loader rooted at dir, or nil if dir isn't within a Go
+// module or module mode has been disabled via GO111MODULE=off in vars.
+func newModLoader(dir string, vars map[string]string, tags []string) *modLoader {
+	if vars["GO111MODULE"] == "off" || findModuleRoot(dir) == "" {
+		return nil
+	}
+	var env []string
+	for key, value := range vars {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return &modLoader{dir: dir, env: env, tags: tags}
+}
+
+type patternGroup struct {
+	dir      string
+	patterns []string
+}
+
+// groupPatterns groups patterns by the module they belong to.  Filesystem
+// patterns (those starting with "." or "/") are resolved against the module
+// root enclosing their directory, which allows a single invocation to span
+// several modules even without a go.work file.  All other patterns are resolved
+// relative to l.dir.
+func (l *modLoader) groupPatterns(patterns []string) []patternGroup {
+	var groups []patternGroup
+	index := make(map[string]int)
+	for _, pattern := range patterns {
+		dir := l.dir
+		if strings.HasPrefix(pattern, ".") || filepath.IsAbs(pattern) {
+			abs := pattern
+			if !filepath.IsAbs(abs) {
+				abs = filepath.Join(l.dir, abs)
+			}
+			pattern = abs
+			if root := findModuleRoot(strings.TrimSuffix(abs, "...")); root != "" {
+				dir = root
+			}
+		}
+		i, ok := index[dir]
+		if !ok {
+			i = len(groups)
+			index[dir] = i
+			groups = append(groups, patternGroup{dir: dir})
+		}
+		groups[i].patterns = append(groups[i].patterns, pattern)
+	}
+	return groups
+}
+
+// Load loads the packages matching patterns, along with all their transitive
+// dependencies and test variants, and adds them to pkgCache.  Returns the
+// sorted import paths of the packages matching patterns.
+func (l *modLoader) Load(patterns ...string) ([]string, error) {
+	uniq := make(map[string]bool)
+	for _, group := range l.groupPatterns(patterns) {
+		cfg := &packages.Config{
+			Mode:  loadMode,
+			Dir:   group.dir,
+			Env:   append(os.Environ(), l.env...),
+			Tests: true,
+		}
+		if len(l.tags) > 0 {
+			cfg.BuildFlags = []string{"-tags=" + strings.Join(l.tags, ",")}
+		}
+		roots, err := packages.Load(cfg, group.patterns...)
+		if err != nil {
+			return nil, err
+		}
+		if err := loadErrors(roots); err != nil {
+			return nil, err
+		}
+		addLoadedPackages(roots)
+		for _, root := range roots {
+			if variantOf(root) == "" {
+				uniq[root.PkgPath] = true
+			}
+		}
+	}
+	var paths []string
+	for path := range uniq {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Import returns the package with the given import path, loading it if it
+// hasn't already been loaded.
+func (l *modLoader) Import(path string) (*build.Package, error) {
+	if p, ok := cachedPackage(path); ok {
+		return p, nil
+	}
+	if _, err := l.Load(path); err != nil {
+		return nil, err
+	}
+	p, ok := cachedPackage(path)
+	if !ok {
+		return nil, errors.New("cannot find package " + path)
+	}
+	return p, nil
+}
+
+// loadErrors returns an error describing all package errors in the graph
+// reachable from roots, or nil if there were no errors.
+func loadErrors(roots []*packages.Package) error {
+	var msgs []string
+	seen := make(map[string]bool)
+	packages.Visit(roots, nil, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			if msg := err.Error(); !seen[msg] {
+				seen[msg] = true
+				msgs = append(msgs, msg)
+			}
+		}
+	})
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// variantOf returns the import path of the package under test if p is a test
+// variant, i.e. the test main package, the package augmented with its
+// _test.go files, or the *_test package.  Returns "" for ordinary packages,
+// including packages recompiled against a test variant.
+func variantOf(p *packages.Package) string {
+	switch {
+	case strings.HasSuffix(p.ID, ".test") && p.ID == p.PkgPath:
+		return strings.TrimSuffix(p.PkgPath, ".test")
+	case p.ID == p.PkgPath+" ["+p.PkgPath+".test]":
+		return p.PkgPath
+	case strings.HasSuffix(p.PkgPath, "_test"):
+		base := strings.TrimSuffix(p.PkgPath, "_test")
+		if p.ID == p.PkgPath+" ["+base+".test]" {
+			return base
+		}
+	}
+	return ""
+}
+
+// addLoadedPackages converts every package reachable from roots into a
+// *build.Package and adds it to pkgCache.  Imports of test variants become the
+// TestImports and XTestImports of the package under test.
+func addLoadedPackages(roots []*packages.Package) {
+	var all []*packages.Package
+	packages.Visit(roots, nil, func(p *packages.Package) {
+		all = append(all, p)
+	})
+	// Ordinary packages are added first, since they take precedence over the
+	// variants recompiled for tests, which are only used as a fallback.
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].ID == all[i].PkgPath && all[j].ID != all[j].PkgPath
+	})
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+	for _, p := range all {
+		if variantOf(p) != "" || pkgCache[p.PkgPath] != nil {
+			continue
+		}
+		pkgCache[p.PkgPath] = convertPackage(p)
+		if p.Module != nil && p.Module.Dir != "" {
+			moduleRoots[p.PkgPath] = p.Module.Dir
+		}
+	}
+	for _, p := range all {
+		base := variantOf(p)
+		cached := pkgCache[base]
+		if cached == nil || p.PkgPath == base+".test" {
+			continue
+		}
+		// Packages in pkgCache may be read by concurrent check workers without
+		// holding pkgMu, so they are never modified; the package under test is
+		// replaced by a copy that includes its test imports instead.
+		bp := new(build.Package)
+		*bp = *cached
+		imports := testImports(p)
+		if p.PkgPath == base {
+			bp.TestImports = imports
+			bp.TestGoFiles = testFiles(p)
+		} else {
+			bp.XTestImports = imports
+			bp.XTestGoFiles = testFiles(p)
+		}
+		pkgCache[base] = bp
+	}
+}
+
+// convertPackage converts p into the equivalent *build.Package.
+func convertPackage(p *packages.Package) *build.Package {
+	bp := &build.Package{
+		Name:       p.Name,
+		ImportPath: p.PkgPath,
+		Imports:    importPaths(p),
+	}
+	for _, files := range [][]string{p.GoFiles, p.OtherFiles, p.IgnoredFiles} {
+		if len(files) > 0 {
+			bp.Dir = filepath.Dir(files[0])
+			break
+		}
+	}
+	for _, file := range p.GoFiles {
+		bp.GoFiles = append(bp.GoFiles, filepath.Base(file))
+	}
+	for _, file := range p.IgnoredFiles {
+		if strings.HasSuffix(file, ".go") {
+			bp.IgnoredGoFiles = append(bp.IgnoredGoFiles, filepath.Base(file))
+		}
+	}
+	goroot := filepath.Join(build.Default.GOROOT, "src") + string(filepath.Separator)
+	if p.Module == nil && strings.HasPrefix(bp.Dir, goroot) {
+		bp.Goroot = true
+		bp.Root = build.Default.GOROOT
+	} else if p.Module != nil {
+		bp.Root = p.Module.Dir
+	}
+	return bp
+}
+
+// importPaths returns the sorted import paths of p's imports, resolved through
+// vendor directories.
+func importPaths(p *packages.Package) []string {
+	var paths []string
+	for _, imp := range p.Imports {
+		paths = append(paths, imp.PkgPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// testImports returns the sorted import paths of the imports of the _test.go
+// files in p, resolved through vendor directories.  The test variant of a
+// package also contains its non-test files, so the imports of the variant
+// can't be used, as TestImports only lists the imports of _test.go files.
+func testImports(p *packages.Package) []string {
+	uniq := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, file := range p.GoFiles {
+		if !strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			if imp := p.Imports[path]; imp != nil {
+				path = imp.PkgPath
+			}
+			uniq[path] = true
+		}
+	}
+	var paths []string
+	for path := range uniq {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// testFiles returns the base names of the _test.go files in p.
+func testFiles(p *packages.Package) []string {
+	var files []string
+	for _, file := range p.GoFiles {
+		if strings.HasSuffix(file, "_test.go") {
+			files = append(files, filepath.Base(file))
+		}
+	}
+	return files
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModuleCheckDeps(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("testdata", "mods", "mod-a"))
+	if err != nil {
+		t.Fatalf("Abs failed: %v", err)
+	}
+	tests := []struct {
+		tags  []string
+		paths []string
+		pass  []bool
+	}{
+		{
+			nil,
+			[]string{"example.com/mod-a/bad", "example.com/mod-a/ok", "example.com/mod-a/tagged"},
+			[]bool{false, true, true},
+		},
+		{
+			[]string{"special"},
+			[]string{"example.com/mod-a/bad", "example.com/mod-a/ok", "example.com/mod-a/tagged"},
+			[]bool{false, true, false},
+		},
+	}
+	defer func() { loader = nil }()
+	for _, test := range tests {
+		// Packages are cached by import path, so each set of build tags needs a
+		// fresh cache.
+		pkgCache = map[string]*build.Package{"C": pseudoPackageC, "unsafe": pseudoPackageUnsafe}
+		loader = newModLoader(dir, map[string]string{"GO111MODULE": "on"}, test.tags)
+		if loader == nil {
+			t.Fatalf("%s isn't recognized as a module", dir)
+		}
+		paths, err := loader.Load("./...")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if got, want := paths, test.paths; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%v got paths %v, want %v", test.tags, got, want)
+		}
+		for i, path := range paths {
+			p, err := importPackage(path)
+			if err != nil {
+				t.Errorf("%s error loading package: %v", path, err)
+				continue
+			}
+			if got, want := moduleRoots[path], dir; got != want {
+				t.Errorf("%s got module root %q, want %q", path, got, want)
+			}
+			v, err := checkDeps(p)
+			if err != nil {
+				t.Errorf("%s failed: %v", path, err)
+				continue
+			}
+			if got, want := len(v) == 0, test.pass[i]; got != want {
+				t.Errorf("%v %s got violations %v, want pass %v", test.tags, path, v, want)
+			}
+		}
+	}
+}
+
+func TestModuleTestImports(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("testdata", "mods", "mod-a"))
+	if err != nil {
+		t.Fatalf("Abs failed: %v", err)
+	}
+	defer func() { loader = nil }()
+	pkgCache = map[string]*build.Package{"C": pseudoPackageC, "unsafe": pseudoPackageUnsafe}
+	loader = newModLoader(dir, map[string]string{"GO111MODULE": "on"}, nil)
+	if loader == nil {
+		t.Fatalf("%s isn't recognized as a module", dir)
+	}
+	p, err := loader.Import("example.com/mod-a/ok")
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	// TestImports only lists the imports of the _test.go files of the
+	// package, not those of the package itself.
+	if got, want := p.TestImports, []string{"strings"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got TestImports %v, want %v", got, want)
+	}
+	if got, want := p.XTestImports, []string{"example.com/mod-b/forbidden"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got XTestImports %v, want %v", got, want)
+	}
+}
+
+func TestNewConfigIterModule(t *testing.T) {
+	root := filepath.Join("testdata", "mods", "mod-a")
+	const path = "example.com/mod-a/x/y"
+	moduleRoots[path] = root
+	defer delete(moduleRoots, path)
+	it := newConfigIter(&build.Package{ImportPath: path, Dir: filepath.Join(root, "x", "y")})
+	var dirs []string
+	for it.Advance() {
+		dirs = append(dirs, filepath.Dir(it.Value().Path))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	want := []string{filepath.Join(root, "x", "y"), filepath.Join(root, "x"), root}
+	if got := dirs; !reflect.DeepEqual(got, want) {
+		t.Errorf("got dirs %v, want %v", got, want)
+	}
+}
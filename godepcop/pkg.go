@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"go/build"
 	"io"
+	"os"
 	"sort"
 	"strings"
+	"sync"
 
 	"v.io/jiri"
 	"v.io/x/devtools/internal/goutil"
@@ -20,14 +22,41 @@ import (
 var (
 	pseudoPackageC      = &build.Package{ImportPath: "C", Goroot: true}
 	pseudoPackageUnsafe = &build.Package{ImportPath: "unsafe", Goroot: true}
-	pkgCache            = map[string]*build.Package{"C": pseudoPackageC, "unsafe": pseudoPackageUnsafe}
+
+	// pkgMu guards pkgCache and moduleRoots, since packages are checked
+	// concurrently.
+	pkgMu    sync.Mutex
+	pkgCache = map[string]*build.Package{"C": pseudoPackageC, "unsafe": pseudoPackageUnsafe}
 )
 
+// cachedPackage returns the package with the given path from pkgCache.
+func cachedPackage(path string) (*build.Package, bool) {
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+	p, ok := pkgCache[path]
+	return p, ok
+}
+
 func isPseudoPackage(p *build.Package) bool {
 	return p == pseudoPackageUnsafe || p == pseudoPackageC
 }
 
+// listPackagePaths returns the import paths of the packages matching args.  If
+// the working directory is within a Go module, packages are resolved via
+// go/packages and the module loader is installed for subsequent calls to
+// importPackage; otherwise they are resolved via "jiri go list".
 func listPackagePaths(env *cmdline.Env, args ...string) ([]string, error) {
+	build.Default.BuildTags = splitTags(flagTags)
+	if loader == nil {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		loader = newModLoader(wd, env.Vars, build.Default.BuildTags)
+	}
+	if loader != nil {
+		return loader.Load(args...)
+	}
 	jirix, err := jiri.NewX(env)
 	if err != nil {
 		return nil, err
@@ -37,13 +66,23 @@ func listPackagePaths(env *cmdline.Env, args ...string) ([]string, error) {
 
 // importPackage loads and returns the package with the given package path.
 func importPackage(path string) (*build.Package, error) {
-	if p, ok := pkgCache[path]; ok {
+	if p, ok := cachedPackage(path); ok {
 		return p, nil
 	}
+	if loader != nil {
+		return loader.Import(path)
+	}
 	p, err := build.Import(path, "", build.AllowBinary)
 	if err != nil {
 		return nil, err
 	}
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+	// Another goroutine may have imported the package concurrently; keep the
+	// first instance, so that each path maps to a single package.
+	if cached, ok := pkgCache[path]; ok {
+		return cached, nil
+	}
 	pkgCache[path] = p
 	return p, nil
 }
@@ -126,6 +165,123 @@ func (x depOpts) depsHelper(paths []string, deps map[string]*build.Package) erro
 	return nil
 }
 
+// Chain returns the shortest chain of imports from pkg to the package with the
+// given import path.  The chain starts with pkg and ends with path; it is nil if
+// path isn't a dependency of pkg.
+func (x depOpts) Chain(pkg *build.Package, path string) ([]string, error) {
+	parents := map[string]string{pkg.ImportPath: ""}
+	queue := []string{pkg.ImportPath}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == path {
+			var chain []string
+			for p := cur; p != ""; p = parents[p] {
+				chain = append([]string{p}, chain...)
+			}
+			return chain, nil
+		}
+		var imports []string
+		switch {
+		case cur == pkg.ImportPath:
+			imports = x.Paths(pkg)
+		case x.DirectOnly:
+			continue
+		default:
+			p, err := importPackage(cur)
+			if err != nil {
+				return nil, err
+			}
+			imports = p.Imports
+		}
+		for _, imp := range imports {
+			if _, ok := parents[imp]; ok {
+				continue
+			}
+			dep, err := importPackage(imp)
+			if err != nil {
+				return nil, err
+			}
+			if !x.IncludeGoroot && dep.Goroot {
+				continue
+			}
+			parents[imp] = cur
+			queue = append(queue, imp)
+		}
+	}
+	return nil, nil
+}
+
+// AllChains returns every chain of imports from pkg to the package with the
+// given import path, sorted by length and then lexicographically.  Each chain
+// starts with pkg and ends with path.
+func (x depOpts) AllChains(pkg *build.Package, path string) ([][]string, error) {
+	// Only follow imports that may lead to path; this keeps the search
+	// proportional to the number of chains, rather than the size of the graph.
+	reaches := map[string]bool{path: true}
+	var canReach func(cur string, imports []string) (bool, error)
+	canReach = func(cur string, imports []string) (bool, error) {
+		if ok, seen := reaches[cur]; seen {
+			return ok, nil
+		}
+		reaches[cur] = false
+		for _, imp := range imports {
+			dep, err := importPackage(imp)
+			if err != nil {
+				return false, err
+			}
+			if !x.IncludeGoroot && dep.Goroot {
+				continue
+			}
+			ok := imp == path
+			if !ok && !x.DirectOnly {
+				if ok, err = canReach(imp, dep.Imports); err != nil {
+					return false, err
+				}
+			}
+			if ok {
+				reaches[cur] = true
+			}
+		}
+		return reaches[cur], nil
+	}
+	if _, err := canReach(pkg.ImportPath, x.Paths(pkg)); err != nil {
+		return nil, err
+	}
+	var chains [][]string
+	var walk func(chain []string, imports []string) error
+	walk = func(chain []string, imports []string) error {
+		for _, imp := range imports {
+			if !reaches[imp] {
+				continue
+			}
+			next := append(append([]string(nil), chain...), imp)
+			if imp == path {
+				chains = append(chains, next)
+				continue
+			}
+			dep, err := importPackage(imp)
+			if err != nil {
+				return err
+			}
+			if err := walk(next, dep.Imports); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk([]string{pkg.ImportPath}, x.Paths(pkg)); err != nil {
+		return nil, err
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		if len(chains[i]) != len(chains[j]) {
+			return len(chains[i]) < len(chains[j])
+		}
+		return strings.Join(chains[i], " ") < strings.Join(chains[j], " ")
+	})
+	return chains, nil
+}
+
 func hasOverlap(a, b map[string]*build.Package) bool {
 	if len(a) > len(b) {
 		a, b = b, a
@@ -0,0 +1,203 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatSARIF = "sarif"
+	formatDot   = "dot"
+)
+
+// jsonViolation is the JSON representation of a violation.
+type jsonViolation struct {
+	Src     string   `json:"src"`
+	Dst     string   `json:"dst"`
+	Mode    string   `json:"mode"`
+	Rule    string   `json:"rule,omitempty"`
+	Layer   string   `json:"layer,omitempty"`
+	Config  string   `json:"config,omitempty"`
+	Line    int      `json:"line,omitempty"`
+	Chain   []string `json:"chain"`
+	Message string   `json:"message"`
+}
+
+func newJSONViolation(v violation) jsonViolation {
+	jv := jsonViolation{
+		Src:     v.Src.ImportPath,
+		Dst:     v.Dst.ImportPath,
+		Mode:    v.Mode.String(),
+		Chain:   v.Chain,
+		Message: v.Err.Error(),
+	}
+	switch {
+	case v.Rule != nil:
+		jv.Rule = v.Rule.Pattern()
+		jv.Config = v.Config
+		jv.Line = v.Rule.Line
+	case v.Layer != nil:
+		jv.Layer = v.Layer.Name
+		jv.Config = v.Config
+		jv.Line = v.Layer.Line
+	}
+	return jv
+}
+
+// printViolations prints violations to w in the given format.
+func printViolations(w io.Writer, format string, violations []violation) error {
+	switch format {
+	case formatText:
+		for _, v := range violations {
+			fmt.Fprintln(w, v)
+		}
+		return nil
+	case formatJSON:
+		jvs := []jsonViolation{}
+		for _, v := range violations {
+			jvs = append(jvs, newJSONViolation(v))
+		}
+		return writeJSON(w, jvs)
+	case formatSARIF:
+		return writeJSON(w, newSARIFLog(violations))
+	case formatDot:
+		printViolationsDot(w, violations)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func writeJSON(w io.Writer, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// The following types describe the subset of the SARIF 2.1.0 format
+// (https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html) that is
+// produced by godepcop.
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID   string       `json:"id"`
+		Text sarifMessage `json:"shortDescription"`
+	}
+	sarifResult struct {
+		RuleID        string          `json:"ruleId"`
+		Level         string          `json:"level"`
+		Message       sarifMessage    `json:"message"`
+		Locations     []sarifLocation `json:"locations,omitempty"`
+		BaselineState string          `json:"baselineState,omitempty"`
+		Properties    *jsonViolation  `json:"properties,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		Physical sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		Artifact sarifArtifact `json:"artifactLocation"`
+		Region   *sarifRegion  `json:"region,omitempty"`
+	}
+	sarifArtifact struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine int `json:"startLine"`
+	}
+)
+
+const (
+	sarifRuleDeny     = "deny-rule"
+	sarifRuleInternal = "internal-package"
+	sarifRuleLayer    = "layer"
+)
+
+func newSARIFLog(violations []violation) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{sarifDriver{
+			Name: "godepcop",
+			Rules: []sarifRule{
+				{sarifRuleDeny, sarifMessage{"Import rejected by a .godepcop deny rule"}},
+				{sarifRuleInternal, sarifMessage{"Import violates the Go internal package rule"}},
+				{sarifRuleLayer, sarifMessage{"Import violates the layers declared in a .godepcop file"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+	for _, v := range violations {
+		jv := newJSONViolation(v)
+		result := sarifResult{
+			RuleID:     sarifRuleInternal,
+			Level:      "error",
+			Message:    sarifMessage{v.String()},
+			Properties: &jv,
+		}
+		// Deny rule and layer violations are reported in the config file, since
+		// that's where the dependency is rejected.  Other violations are reported
+		// at the importer.
+		loc := sarifPhysicalLocation{Artifact: sarifArtifact{sarifURI(v.Src.Dir)}}
+		switch {
+		case v.Rule != nil:
+			result.RuleID = sarifRuleDeny
+			loc = sarifPhysicalLocation{
+				Artifact: sarifArtifact{sarifURI(v.Config)},
+				Region:   &sarifRegion{v.Rule.Line},
+			}
+		case v.Layer != nil:
+			result.RuleID = sarifRuleLayer
+			loc = sarifPhysicalLocation{
+				Artifact: sarifArtifact{sarifURI(v.Config)},
+				Region:   &sarifRegion{v.Layer.Line},
+			}
+		}
+		result.Locations = []sarifLocation{{loc}}
+		run.Results = append(run.Results, result)
+	}
+	return sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// sarifURI returns the URI for the given filesystem path; paths under the
+// working directory are made relative, so that reports are independent of the
+// location of the checkout.
+func sarifURI(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return "file://" + filepath.ToSlash(path)
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPrintViolationsJSON(t *testing.T) {
+	const v = "v.io/x/devtools/godepcop/testdata/"
+	p, err := importPackage(v + "test-d")
+	if err != nil {
+		t.Fatalf("importPackage failed: %v", err)
+	}
+	violations, err := checkDeps(p)
+	if err != nil {
+		t.Fatalf("checkDeps failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printViolations(&buf, formatJSON, violations); err != nil {
+		t.Fatalf("printViolations failed: %v", err)
+	}
+	var got []jsonViolation
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%s) failed: %v", buf.String(), err)
+	}
+	// The same dependency is rejected in each of the pkg, test and xtest modes.
+	if len(got) != 3 {
+		t.Fatalf("got %d violations, want 3: %s", len(got), buf.String())
+	}
+	for i, mode := range []checkMode{modePkg, modeTest, modeXTest} {
+		want := jsonViolation{
+			Src:     v + "test-d",
+			Dst:     "fmt",
+			Mode:    mode.String(),
+			Rule:    "fmt",
+			Config:  filepath.Join(p.Dir, configFileName),
+			Line:    2,
+			Chain:   []string{v + "test-d", v + "test-a", "fmt"},
+			Message: violations[i].Err.Error(),
+		}
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("got %#v, want %#v", got[i], want)
+		}
+	}
+}
+
+func TestPrintViolationsSARIF(t *testing.T) {
+	internal := violation{
+		Src:   pkg("a/b"),
+		Dst:   pkg("a/c/internal"),
+		Err:   errGo15Internal,
+		Mode:  modePkg,
+		Chain: []string{"a/b", "a/c/internal"},
+	}
+	internal.Src.Dir = "/src/a/b"
+	deny := violation{
+		Src:    pkg("a/b"),
+		Dst:    pkg("x"),
+		Err:    errors.New("violates test deny rule"),
+		Mode:   modeTest,
+		Rule:   &rule{Deny: &dots, Line: 3},
+		Config: "/src/a/.godepcop",
+		Chain:  []string{"a/b", "x"},
+	}
+	var buf bytes.Buffer
+	if err := printViolations(&buf, formatSARIF, []violation{internal, deny}); err != nil {
+		t.Fatalf("printViolations failed: %v", err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%s) failed: %v", buf.String(), err)
+	}
+	if got, want := got.Version, "2.1.0"; got != want {
+		t.Errorf("got version %q, want %q", got, want)
+	}
+	if len(got.Runs) != 1 || len(got.Runs[0].Results) != 2 {
+		t.Fatalf("got %s, want a single run with 2 results", buf.String())
+	}
+	results := got.Runs[0].Results
+	if got, want := results[0].RuleID, sarifRuleInternal; got != want {
+		t.Errorf("got rule %q, want %q", got, want)
+	}
+	if got, want := results[0].Locations[0].Physical, (sarifPhysicalLocation{Artifact: sarifArtifact{"file:///src/a/b"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got location %#v, want %#v", got, want)
+	}
+	if got, want := results[1].RuleID, sarifRuleDeny; got != want {
+		t.Errorf("got rule %q, want %q", got, want)
+	}
+	if got, want := results[1].Locations[0].Physical, (sarifPhysicalLocation{Artifact: sarifArtifact{"file:///src/a/.godepcop"}, Region: &sarifRegion{3}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got location %#v, want %#v", got, want)
+	}
+	if got, want := results[1].Properties.Mode, "test"; got != want {
+		t.Errorf("got mode %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	scopeMu    sync.Mutex
+	scopeCache = map[string]map[string]*build.Package{}
+)
+
+// scopedDeps returns the transitive dependencies of pkg that are pulled in by
+// imports from the files of pkg that are in scope of rule r, in the given mode.
+func scopedDeps(pkg *build.Package, r rule, mode checkMode) (map[string]*build.Package, error) {
+	key := strings.Join([]string{pkg.ImportPath, mode.String(), r.Files, r.Tags}, "\x00")
+	scopeMu.Lock()
+	deps, ok := scopeCache[key]
+	scopeMu.Unlock()
+	if ok {
+		return deps, nil
+	}
+	files := append(append([]string(nil), pkg.GoFiles...), pkg.CgoFiles...)
+	switch mode {
+	case modeTest:
+		files = append(files, pkg.TestGoFiles...)
+	case modeXTest:
+		files = append(files, pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+	}
+	var imports []string
+	for _, file := range files {
+		inScope, fileImports, err := fileInScope(filepath.Join(pkg.Dir, file), r)
+		if err != nil {
+			return nil, err
+		}
+		if inScope {
+			imports = append(imports, fileImports...)
+		}
+	}
+	deps = make(map[string]*build.Package)
+	opts := depOpts{IncludeGoroot: true}
+	if err := opts.depsHelper(imports, deps); err != nil {
+		return nil, err
+	}
+	if r.Tags != "" {
+		// Files that are excluded from the current build context are in scope
+		// of the tags too, e.g. *_windows.go files are in scope of
+		// tags="windows" on Linux.  Only the tags apply to them, since they
+		// aren't built unless some tag is set.
+		ignored, err := ignoredFiles(pkg, mode)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range ignored {
+			inScope, fileImports, err := fileInScope(filepath.Join(pkg.Dir, file), rule{Tags: r.Tags})
+			if err != nil {
+				return nil, err
+			}
+			if !inScope {
+				continue
+			}
+			for _, imp := range fileImports {
+				if deps[imp] != nil {
+					continue
+				}
+				// Packages imported by excluded files may not build in the
+				// current build context either, e.g. golang.org/x/sys/windows
+				// on Linux; they are included without their dependencies.
+				if err := opts.depsHelper([]string{imp}, deps); err != nil {
+					deps[imp] = findPackage(imp, pkg.Dir)
+				}
+			}
+		}
+	}
+	// Don't include the package itself; it may be imported by xtest files.
+	delete(deps, pkg.ImportPath)
+	scopeMu.Lock()
+	scopeCache[key] = deps
+	scopeMu.Unlock()
+	return deps, nil
+}
+
+// taggedDeps returns the dependencies of pkg in the given mode that are pulled
+// in by imports from files in scope of rules with tags, including files that
+// are excluded from the current build context.
+func taggedDeps(pkg *build.Package, mode checkMode) (map[string]*build.Package, error) {
+	deps := make(map[string]*build.Package)
+	it := newConfigIter(pkg)
+	for it.Advance() {
+		for _, r := range it.Value().rules(mode) {
+			if r.Tags == "" {
+				continue
+			}
+			scoped, err := scopedDeps(pkg, r, mode)
+			if err != nil {
+				return nil, err
+			}
+			for path, dep := range scoped {
+				deps[path] = dep
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+// ignoredFiles returns the Go files of pkg that are excluded from the current
+// build context, and that would be part of pkg in the given mode if they were
+// built.
+func ignoredFiles(pkg *build.Package, mode checkMode) ([]string, error) {
+	var files []string
+	for _, file := range pkg.IgnoredGoFiles {
+		if strings.HasSuffix(file, "_test.go") {
+			switch mode {
+			case modePkg:
+				continue
+			case modeTest:
+				// Test files in the *_test package are only part of xtests.
+				f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(pkg.Dir, file), nil, parser.PackageClauseOnly)
+				if err != nil {
+					return nil, err
+				}
+				if strings.HasSuffix(f.Name.Name, "_test") {
+					continue
+				}
+			}
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// findPackage returns the package with the given import path, as imported from
+// dir, without reading its files.  If the package can't be found, it returns a
+// package with just the import path.
+func findPackage(path, dir string) *build.Package {
+	if pkg, err := build.Import(path, dir, build.FindOnly); err == nil {
+		return pkg
+	}
+	return &build.Package{ImportPath: path}
+}
+
+// fileInScope returns true iff the Go source file with the given path is in
+// scope of rule r, along with the import paths of the file.
+func fileInScope(path string, r rule) (bool, []string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return false, nil, err
+	}
+	var imports []string
+	for _, spec := range file.Imports {
+		imp, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return false, nil, err
+		}
+		imports = append(imports, imp)
+	}
+	name := filepath.Base(path)
+	for _, glob := range strings.Fields(r.Files) {
+		if matched, _ := filepath.Match(glob, name); matched {
+			return true, imports, nil
+		}
+	}
+	exprs := fileConstraints(file)
+	if expr := fileNameConstraint(name); expr != nil {
+		exprs = append(exprs, expr)
+	}
+	importsC := false
+	for _, imp := range imports {
+		if imp == "C" {
+			importsC = true
+		}
+	}
+	for _, tag := range strings.Fields(r.Tags) {
+		if tag == "cgo" && importsC {
+			return true, imports, nil
+		}
+		for _, expr := range exprs {
+			if mentionsTag(expr, tag) && expr.Eval(tagMatcher(tag)) {
+				return true, imports, nil
+			}
+		}
+	}
+	return false, imports, nil
+}
+
+// fileConstraints returns the build constraints of file.
+func fileConstraints(file *ast.File) []constraint.Expr {
+	var exprs []constraint.Expr
+	// Build constraints must appear before the package clause.
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if !constraint.IsGoBuild(comment.Text) && !constraint.IsPlusBuild(comment.Text) {
+				continue
+			}
+			if expr, err := constraint.Parse(comment.Text); err == nil {
+				exprs = append(exprs, expr)
+			}
+		}
+	}
+	return exprs
+}
+
+// fileNameConstraint returns the build constraint implied by the GOOS and
+// GOARCH suffixes of the given file name, e.g. "windows && amd64" for
+// "x_windows_amd64.go", or nil if there is none.
+func fileNameConstraint(name string) constraint.Expr {
+	name = strings.TrimSuffix(name, ".go")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return nil
+	}
+	// As with the go tool, everything before the first _ is ignored, so that
+	// e.g. "windows.go" isn't constrained.
+	l := strings.Split(name[i:], "_")
+	if n := len(l); n > 0 && l[n-1] == "test" {
+		l = l[:n-1]
+	}
+	n := len(l)
+	switch {
+	case n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]]:
+		return &constraint.AndExpr{X: &constraint.TagExpr{Tag: l[n-2]}, Y: &constraint.TagExpr{Tag: l[n-1]}}
+	case n >= 1 && (knownOS[l[n-1]] || knownArch[l[n-1]]):
+		return &constraint.TagExpr{Tag: l[n-1]}
+	}
+	return nil
+}
+
+// knownOS and knownArch are the values of GOOS and GOARCH that go/build
+// recognizes in file names and build constraints.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+		"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+		"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+		"windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+		"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true,
+		"mips64le": true, "mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true,
+		"sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// mentionsTag returns true iff the build constraint expr mentions tag.
+func mentionsTag(expr constraint.Expr, tag string) bool {
+	switch x := expr.(type) {
+	case *constraint.TagExpr:
+		return x.Tag == tag
+	case *constraint.NotExpr:
+		return mentionsTag(x.X, tag)
+	case *constraint.AndExpr:
+		return mentionsTag(x.X, tag) || mentionsTag(x.Y, tag)
+	case *constraint.OrExpr:
+		return mentionsTag(x.X, tag) || mentionsTag(x.Y, tag)
+	}
+	return false
+}
+
+// tagMatcher returns a function that reports whether a build tag is satisfied
+// when tag is set, along with the tags satisfied by the default build context.
+// A file whose build constraint is only satisfied when tag isn't set, e.g.
+// "//go:build !tag", is excluded by tag, so it isn't in scope of tag.  If tag
+// is a GOOS or GOARCH value, it replaces the one of the default build context.
+func tagMatcher(tag string) func(string) bool {
+	ctx := build.Default
+	return func(t string) bool {
+		switch t {
+		case tag:
+			return true
+		case ctx.GOOS:
+			return !knownOS[tag]
+		case ctx.GOARCH:
+			return !knownArch[tag]
+		case "cgo":
+			return ctx.CgoEnabled
+		}
+		for _, tags := range [][]string{ctx.BuildTags, ctx.ToolTags, ctx.ReleaseTags} {
+			for _, other := range tags {
+				if t == other {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFileNameConstraint(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"x.go", "<nil>"},
+		{"windows.go", "<nil>"},
+		{"x_special.go", "<nil>"},
+		{"x_windows.go", "windows"},
+		{"x_windows_test.go", "windows"},
+		{"x_amd64.go", "amd64"},
+		{"x_windows_amd64.go", "windows && amd64"},
+		{"x_windows_amd64_test.go", "windows && amd64"},
+		{"x_amd64_windows.go", "windows"},
+		{"x_test.go", "<nil>"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(fileNameConstraint(test.name)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckDepsExcludedFiles(t *testing.T) {
+	p, err := importPackage("v.io/x/devtools/godepcop/testdata/test-scoped")
+	if err != nil {
+		t.Fatalf("importPackage failed: %v", err)
+	}
+	v, err := checkDeps(p)
+	if err != nil {
+		t.Fatalf("checkDeps failed: %v", err)
+	}
+	// encoding/csv is only imported by scoped_plan9.go, which is excluded
+	// from the current build context, but is in scope of tags="plan9".
+	for _, v := range v {
+		if v.Dst.ImportPath == "encoding/csv" {
+			if got, want := v.Rule.Line, 8; got != want {
+				t.Errorf("got rule at line %d, want %d", got, want)
+			}
+			return
+		}
+	}
+	t.Errorf("got violations %v, want one for encoding/csv", v)
+}
@@ -0,0 +1,222 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"v.io/jiri/runutil"
+)
+
+// suggestion holds the rules suggested for the .godepcop file of a package.
+type suggestion struct {
+	Pkg        *build.Package
+	PkgAllow   []string // Patterns allowed by pkg rules.
+	TestAllow  []string // Extra patterns allowed by test rules.
+	XTestAllow []string // Extra patterns allowed by xtest rules.
+}
+
+// Path returns the path of the .godepcop file of the package.
+func (s suggestion) Path() string {
+	return filepath.Join(s.Pkg.Dir, configFileName)
+}
+
+// XML returns the suggested .godepcop file.  The pkg rules allow the current
+// dependencies and deny everything else; the test and xtest rules allow the
+// extra dependencies of the test files.
+func (s suggestion) XML() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<godepcop>\n")
+	writeRules := func(kind string, patterns []string) {
+		for _, pattern := range patterns {
+			fmt.Fprintf(&buf, "  <%s allow=\"%s\"/>\n", kind, escapeAttr(pattern))
+		}
+	}
+	writeRules("pkg", s.PkgAllow)
+	buf.WriteString("  <pkg deny=\"...\"/>\n")
+	writeRules("test", s.TestAllow)
+	writeRules("xtest", s.XTestAllow)
+	buf.WriteString("</godepcop>\n")
+	return buf.Bytes()
+}
+
+func escapeAttr(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// suggestRules returns the suggested rules for each of pkgs, based on their
+// current dependencies.  Dependencies are collapsed into "/..." patterns if
+// every known package matching the pattern is a dependency, where the known
+// packages are pkgs and all their dependencies.
+func suggestRules(pkgs []*build.Package) ([]suggestion, error) {
+	known := make(map[string]bool)
+	modeDeps := make([][3]map[string]bool, len(pkgs))
+	for i, pkg := range pkgs {
+		known[pkg.ImportPath] = true
+		for m, opts := range []depOpts{{}, {IncludeTest: true}, {IncludeXTest: true}} {
+			deps := make(map[string]*build.Package)
+			if err := opts.Deps(pkg, deps); err != nil {
+				return nil, err
+			}
+			// The package may depend on itself through its test files.
+			delete(deps, pkg.ImportPath)
+			modeDeps[i][m] = make(map[string]bool)
+			for path := range deps {
+				known[path] = true
+				modeDeps[i][m][path] = true
+			}
+		}
+	}
+	var suggestions []suggestion
+	for i, pkg := range pkgs {
+		pkgDeps, testDeps, xtestDeps := modeDeps[i][0], modeDeps[i][1], modeDeps[i][2]
+		suggestions = append(suggestions, suggestion{
+			Pkg:        pkg,
+			PkgAllow:   collapsePatterns(pkgDeps, pkgDeps, known),
+			TestAllow:  collapsePatterns(subtract(testDeps, pkgDeps), testDeps, known),
+			XTestAllow: collapsePatterns(subtract(xtestDeps, testDeps), xtestDeps, known),
+		})
+	}
+	return suggestions, nil
+}
+
+// subtract returns the paths in a that aren't in b.
+func subtract(a, b map[string]bool) map[string]bool {
+	result := make(map[string]bool)
+	for path := range a {
+		if !b[path] {
+			result[path] = true
+		}
+	}
+	return result
+}
+
+// collapsePatterns returns a sorted list of patterns matching every path in
+// paths.  Paths are collapsed into the shortest "dir/..." pattern that matches
+// at least two of paths, and only matches known packages that are in allowed.
+func collapsePatterns(paths, allowed, known map[string]bool) []string {
+	// covered returns the number of paths under dir, or -1 if some known package
+	// under dir isn't allowed.
+	covered := func(dir string) int {
+		count := 0
+		for path := range known {
+			if path != dir && !strings.HasPrefix(path, dir+"/") {
+				continue
+			}
+			if !allowed[path] {
+				return -1
+			}
+			if paths[path] {
+				count++
+			}
+		}
+		return count
+	}
+	uniq := make(map[string]bool)
+	for path := range paths {
+		pattern := path
+		for dir := path; dir != ""; dir = parentPath(dir) {
+			count := covered(dir)
+			if count < 0 {
+				break
+			}
+			if count >= 2 {
+				pattern = dir + "/..."
+			}
+		}
+		uniq[pattern] = true
+	}
+	var patterns []string
+	for pattern := range uniq {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// parentPath returns the parent of the given import path, or "" if it has no
+// parent.
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i != -1 {
+		return path[:i]
+	}
+	return ""
+}
+
+// printSuggestions prints the suggested .godepcop files to w.  If diff is true,
+// only the differences against the existing files are printed, as unified
+// diffs.
+func printSuggestions(w io.Writer, suggestions []suggestion, diff bool) error {
+	for i, s := range suggestions {
+		if !diff {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n%s", s.Path(), s.XML())
+			continue
+		}
+		out, err := diffConfig(s.Path(), s.XML())
+		if err != nil {
+			return err
+		}
+		w.Write(out)
+	}
+	return nil
+}
+
+// diffConfig returns the unified diff from the file at path to data.  A file
+// that doesn't exist is treated as empty.
+func diffConfig(path string, data []byte) ([]byte, error) {
+	old := path
+	if _, err := os.Stat(path); runutil.IsNotExist(err) {
+		old = os.DevNull
+	}
+	tmp, err := ioutil.TempFile("", "godepcop")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("diff", "-u", "-L", path, "-L", path, old, tmp.Name())
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// Diff exits with status 1 if the files differ.
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return nil, fmt.Errorf("diff failed: %v\n%s", err, stderr.String())
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// writeSuggestions writes the suggested .godepcop files, replacing any existing
+// files.
+func writeSuggestions(w io.Writer, suggestions []suggestion) error {
+	for _, s := range suggestions {
+		if err := ioutil.WriteFile(s.Path(), s.XML(), os.FileMode(0644)); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "wrote %s\n", s.Path())
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollapsePatterns(t *testing.T) {
+	setOf := func(paths ...string) map[string]bool {
+		set := make(map[string]bool)
+		for _, path := range paths {
+			set[path] = true
+		}
+		return set
+	}
+	known := setOf("a", "a/b", "a/b/c", "a/b/d", "a/e", "x/y", "x/z", "self/p", "self/q")
+	tests := []struct {
+		paths, allowed []string
+		want           []string
+	}{
+		{nil, nil, nil},
+		{[]string{"x/y"}, []string{"x/y"}, []string{"x/y"}},
+		{[]string{"x/y", "x/z"}, []string{"x/y", "x/z"}, []string{"x/..."}},
+		// Siblings are collapsed, but not into a parent with other packages.
+		{[]string{"a/b/c", "a/b/d"}, []string{"a/b/c", "a/b/d"}, []string{"a/b/c", "a/b/d"}},
+		{[]string{"a/b", "a/b/c", "a/b/d"}, []string{"a/b", "a/b/c", "a/b/d"}, []string{"a/b/..."}},
+		{[]string{"a", "a/b", "a/b/c", "a/b/d", "a/e"}, []string{"a", "a/b", "a/b/c", "a/b/d", "a/e"}, []string{"a/..."}},
+		// Paths may be collapsed with other allowed paths.
+		{[]string{"a/b/c", "a/b/d"}, []string{"a/b", "a/b/c", "a/b/d"}, []string{"a/b/..."}},
+		// A single path is never collapsed.
+		{[]string{"self/p"}, []string{"self/p"}, []string{"self/p"}},
+	}
+	for _, test := range tests {
+		if got, want := collapsePatterns(setOf(test.paths...), setOf(test.allowed...), known), test.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v, %v got %v, want %v", test.paths, test.allowed, got, want)
+		}
+	}
+}
+
+func TestSuggestRules(t *testing.T) {
+	pkgs := importPackages(t, []string{layersPrefix + "low", cyclePrefix + "a"})
+	suggestions, err := suggestRules(pkgs)
+	if err != nil {
+		t.Fatalf("suggestRules failed: %v", err)
+	}
+	want := []suggestion{
+		// The low package is known, so its dependencies can't be collapsed into
+		// test-layers/...
+		{Pkg: pkgs[0], PkgAllow: []string{layersPrefix + "high", layersPrefix + "mid", layersPrefix + "other"}},
+		// The test files of a import b.
+		{Pkg: pkgs[1], TestAllow: []string{cyclePrefix + "b"}},
+	}
+	if got := suggestions; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The suggested files must be valid.
+	for _, s := range suggestions {
+		cfg, err := parseConfig(s.XML())
+		if err != nil {
+			t.Errorf("%s: parseConfig failed: %v\n%s", s.Pkg.ImportPath, err, s.XML())
+			continue
+		}
+		if got, want := len(cfg.PkgRules)+len(cfg.TestRules)+len(cfg.XTestRules), len(s.PkgAllow)+len(s.TestAllow)+len(s.XTestAllow)+1; got != want {
+			t.Errorf("%s: got %d rules, want %d", s.Pkg.ImportPath, got, want)
+		}
+	}
+	wantXML := `<godepcop>
+  <pkg deny="..."/>
+  <test allow="` + cyclePrefix + `b"/>
+</godepcop>
+`
+	if got, want := string(suggestions[1].XML()), wantXML; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godepcop")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, configFileName)
+	data := []byte("<godepcop>\n  <pkg deny=\"...\"/>\n</godepcop>\n")
+	// A missing file is treated as empty.
+	out, err := diffConfig(path, data)
+	if err != nil {
+		t.Fatalf("diffConfig failed: %v", err)
+	}
+	if got, want := string(out), "+  <pkg deny=\"...\"/>\n"; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+	// Identical files have no diff.
+	if err := ioutil.WriteFile(path, data, os.FileMode(0644)); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if out, err = diffConfig(path, data); err != nil {
+		t.Fatalf("diffConfig failed: %v", err)
+	}
+	if len(out) > 0 {
+		t.Errorf("got diff %q, want none", out)
+	}
+}
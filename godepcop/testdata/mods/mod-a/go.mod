@@ -0,0 +1,7 @@
+module example.com/mod-a
+
+go 1.16
+
+require example.com/mod-b v0.0.0
+
+replace example.com/mod-b => ../mod-b
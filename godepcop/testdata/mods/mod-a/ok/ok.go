@@ -0,0 +1,10 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ok
+
+import (
+	_ "example.com/mod-b"
+	_ "example.com/mod-b/other"
+)
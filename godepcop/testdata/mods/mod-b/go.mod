@@ -0,0 +1,3 @@
+module example.com/mod-b
+
+go 1.16
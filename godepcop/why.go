@@ -0,0 +1,101 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io"
+)
+
+// hop describes the import of Dst by Src within a chain of imports, along with
+// the rule in the config files of Src that decided the import.
+type hop struct {
+	Src, Dst *build.Package
+	Mode     checkMode
+	Rule     *rule  // The matching rule, or nil if no rule matched.
+	Config   string // The path of the config file containing Rule.
+	Result   result
+}
+
+func (h hop) String() string {
+	if h.Rule == nil {
+		return fmt.Sprintf("%s: no matching rule, allowed by default", h.Mode)
+	}
+	kind := "allow"
+	if h.Rule.IsDeny() {
+		kind = "deny"
+	}
+	return fmt.Sprintf("%s %s %q in %s:%d", h.Mode, kind, h.Rule.Pattern(), h.Config, h.Rule.Line)
+}
+
+// explainChain returns the hops in the given chain of imports.  The first hop
+// is checked in the narrowest mode in which it is imported, since it may be an
+// import from test files, while all subsequent hops are ordinary imports.
+func explainChain(chain []string) ([]hop, error) {
+	var hops []hop
+	for i := 0; i+1 < len(chain); i++ {
+		src, err := importPackage(chain[i])
+		if err != nil {
+			return nil, err
+		}
+		dst, err := importPackage(chain[i+1])
+		if err != nil {
+			return nil, err
+		}
+		mode := modePkg
+		if i == 0 {
+			mode = importMode(src, dst.ImportPath)
+		}
+		rule, config, result, err := matchRule(src, dst, mode)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, hop{src, dst, mode, rule, config, result})
+	}
+	return hops, nil
+}
+
+// printChain prints the chain of imports described by hops to w.
+func printChain(w io.Writer, hops []hop) {
+	if len(hops) == 0 {
+		return
+	}
+	fmt.Fprintln(w, hops[0].Src.ImportPath)
+	for _, h := range hops {
+		fmt.Fprintf(w, "  -> %s (%s)\n", h.Dst.ImportPath, h)
+	}
+}
+
+// why prints the shortest chain of imports from src to dst to w, or all chains
+// if all is true.  Returns false if dst isn't a dependency of src.
+func why(w io.Writer, src *build.Package, dst string, opts depOpts, all bool) (bool, error) {
+	var chains [][]string
+	if all {
+		var err error
+		if chains, err = opts.AllChains(src, dst); err != nil {
+			return false, err
+		}
+	} else {
+		chain, err := opts.Chain(src, dst)
+		if err != nil {
+			return false, err
+		}
+		if chain != nil {
+			chains = append(chains, chain)
+		}
+	}
+	for i, chain := range chains {
+		hops, err := explainChain(chain)
+		if err != nil {
+			return false, err
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		printChain(w, hops)
+	}
+	return len(chains) > 0, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWhy(t *testing.T) {
+	const v = "v.io/x/devtools/godepcop/testdata/"
+	dir := func(name string) string {
+		p, err := importPackage(v + name)
+		if err != nil {
+			t.Fatalf("importPackage(%q) failed: %v", v+name, err)
+		}
+		return filepath.Join(p.Dir, configFileName)
+	}
+	// The root config of the repository allows all packages.
+	root := filepath.Join(filepath.Dir(dir("test-a")), "..", "..", "..", configFileName)
+	tests := []struct {
+		src, dst string
+		all      bool
+		want     string
+	}{
+		{v + "test-b", "fmt", false, `v.io/x/devtools/godepcop/testdata/test-b
+  -> fmt (pkg deny "fmt" in ` + dir("test-b") + `:2)
+`},
+		{v + "test-b", "fmt", true, `v.io/x/devtools/godepcop/testdata/test-b
+  -> fmt (pkg deny "fmt" in ` + dir("test-b") + `:2)
+
+v.io/x/devtools/godepcop/testdata/test-b
+  -> v.io/x/devtools/godepcop/testdata/test-c (pkg allow "..." in ` + root + `:2)
+  -> v.io/x/devtools/godepcop/testdata/test-a (pkg deny "v.io/..." in ` + dir("test-c") + `:2)
+  -> fmt (pkg: no matching rule, allowed by default)
+`},
+		{v + "test-c", "fmt", true, `v.io/x/devtools/godepcop/testdata/test-c
+  -> v.io/x/devtools/godepcop/testdata/test-a (pkg deny "v.io/..." in ` + dir("test-c") + `:2)
+  -> fmt (pkg: no matching rule, allowed by default)
+`},
+		{v + "test-a", v + "test-c", true, ``},
+	}
+	for _, test := range tests {
+		src, err := importPackage(test.src)
+		if err != nil {
+			t.Fatalf("importPackage(%q) failed: %v", test.src, err)
+		}
+		var buf bytes.Buffer
+		opts := depOpts{IncludeGoroot: true}
+		found, err := why(&buf, src, test.dst, opts, test.all)
+		if err != nil {
+			t.Errorf("%s %s failed: %v", test.src, test.dst, err)
+			continue
+		}
+		if got, want := found, test.want != ""; got != want {
+			t.Errorf("%s %s got found %v, want %v", test.src, test.dst, got, want)
+		}
+		if got, want := buf.String(), test.want; got != want {
+			t.Errorf("%s %s got:\n%s\nwant:\n%s", test.src, test.dst, got, want)
+		}
+	}
+}
+
+func TestWhyTestImports(t *testing.T) {
+	const v = "v.io/x/devtools/godepcop/testdata/"
+	src, err := importPackage(v + "test-e")
+	if err != nil {
+		t.Fatalf("importPackage failed: %v", err)
+	}
+	// test-e only imports fmt from its test files.
+	var buf bytes.Buffer
+	if found, err := why(&buf, src, "fmt", depOpts{IncludeGoroot: true}, false); found || err != nil {
+		t.Errorf("got (%v, %v), want (false, nil)", found, err)
+	}
+	found, err := why(&buf, src, "fmt", depOpts{IncludeGoroot: true, IncludeTest: true}, false)
+	if !found || err != nil {
+		t.Fatalf("got (%v, %v), want (true, nil)", found, err)
+	}
+	if got, want := buf.String(), "  -> fmt (test deny \"fmt\" in "; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+}
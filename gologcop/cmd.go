@@ -8,6 +8,7 @@
 package main
 
 import (
+	"go/build"
 	"strings"
 
 	"v.io/jiri"
@@ -30,6 +31,11 @@ var (
 	removeCallFlag       string
 	injectCallFlag       string
 	injectCallImportFlag string
+	includeTestsFlag     bool
+	tagsFlag             string
+	templateFlag         string
+	formatFlag           string
+	fixFlag              bool
 	mergePoliciesFlag    profilesreader.MergePolicies
 )
 
@@ -44,19 +50,27 @@ func init() {
 
 	cmdCheck.Flags.StringVar(&injectCallFlag, "call", apilogCall, "The function call to be checked for as defer <pkg>.<call>()() and defer <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.")
 	cmdCheck.Flags.StringVar(&injectCallImportFlag, "import", apilogImport, "Import path for the injected call.")
+	cmdCheck.Flags.StringVar(&formatFlag, "format", formatText, "Print the methods that fail the check in the given format, text or json. The json format reports the file, line, function, interface method and the statement to be injected for each method.")
+	cmdCheck.Flags.BoolVar(&fixFlag, "fix", false, "Print the fixes for the methods that fail the check as unified diffs, which can be applied from the root of the repository with git apply or patch -p1. The check results are printed to stderr.")
+	cmdCheck.Flags.StringVar(&templateFlag, "template", "", "Template file for the statements to be checked for, overriding --call and --import. See the TEMPLATES section of the gologcop help.")
 
 	cmdInject.Flags.StringVar(&interfacesFlag, "interface", "", "Comma-separated list of interface packages (required).")
 	cmdInject.Flags.BoolVar(&gofmtFlag, "gofmt", true, "Automatically run gofmt on the modified files.")
 	cmdInject.Flags.BoolVar(&diffOnlyFlag, "diff-only", false, "Show changes that would be made without actually making them.")
 	cmdInject.Flags.StringVar(&injectCallFlag, "call", apilogCall, "The function call to be injected as defer <pkg>.<call>()() and defer <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.")
 	cmdInject.Flags.StringVar(&injectCallImportFlag, "import", apilogImport, "Import path for the injected call.")
+	cmdInject.Flags.StringVar(&templateFlag, "template", "", "Template file for the statements to be injected, overriding --call and --import. See the TEMPLATES section of the gologcop help.")
 
+	cmdRemove.Flags.StringVar(&interfacesFlag, "interface", "", "Comma-separated list of interface packages, used to determine the interface implemented by each method for --template.")
 	cmdRemove.Flags.BoolVar(&gofmtFlag, "gofmt", true, "Automatically run gofmt on the modified files.")
 	cmdRemove.Flags.BoolVar(&diffOnlyFlag, "diff-only", false, "Show changes that would be made without actually making them.")
 	cmdRemove.Flags.StringVar(&removeCallFlag, "call", apilogRemoveCall, "The function call to be removed. Note, that the package selector must be included. No attempt is made to remove the import declaration if the package is no longer used as a result of the removal.")
+	cmdRemove.Flags.StringVar(&templateFlag, "template", "", "Template file for the statements to be removed, overriding --call. See the TEMPLATES section of the gologcop help.")
 
 	cmdRoot.Flags.BoolVar(&progressFlag, "progress", false, "Print verbose progress information.")
 	cmdRoot.Flags.BoolVar(&useContextFlag, "use-v23-context", true, "Pass a context.T argument (which must be of type v.io/v23/context.T), if available, to the injected call as its first parameter.")
+	cmdRoot.Flags.BoolVar(&includeTestsFlag, "include-tests", false, "Also process the test files of <packages>, including the *_test packages.")
+	cmdRoot.Flags.StringVar(&tagsFlag, "tags", "", "Comma-separated list of build tags to consider satisfied when loading packages.")
 
 	mergePoliciesFlag = profilesreader.JiriMergePolicies()
 	profilescmdline.RegisterMergePoliciesFlag(&cmdRoot.Flags, &mergePoliciesFlag)
@@ -74,23 +88,73 @@ When checking, it ensures that all implementations in <packages> of all exported
 interfaces declared in packages passed to the -interface flag have an
 appropriate logging construct.
 
+An implementation is any method in the method set of a type T or *T declared in
+<packages>, where T or *T implements the interface, including methods promoted
+via embedding from types declared in any of <packages>.  Each offending method
+is reported along with the interface method it implements.
+
 When injecting or removing, it modifies the source code to inject or remove
 such logging constructs.
 
+Packages are loaded via golang.org/x/tools/go/packages, so <packages> may be in
+a Go module or in a GOPATH workspace.  The go tool runs in the environment of
+the jiri profiles, merged according to -merge-policies.  The listed packages
+are type-checked from source, and type errors in them are fatal, while their
+dependencies are type-checked from export data, which the go tool caches across
+runs.  Files generated by the go tool, e.g. from cgo files, are never modified.
+
+TEMPLATES:
+
+The statements to be injected, checked for or removed may be specified by a
+text/template file via the -template flag, instead of the default
+defer <pkg>.<call>(...)(...) statement.  The template is executed for each
+method with the following fields:
+
+  .Package    the name of the package declaring the method
+  .Method     the name of the method
+  .Receiver   the receiver type of the method, e.g. *T
+  .Interface  the interface implemented by the method, e.g. iface.T
+  .Params     the names of the named parameters
+  .Results    the names of the named results
+  .Context    the name of the v.io/v23/context.T parameter, if any
+
+and the following functions:
+
+  .Import <path>  returns the name to refer to the package <path> by, i.e. the
+                  name it is imported as, adding an import if needed
+  join            strings.Join
+
+The template must produce one or more Go statements, which are injected at the
+beginning of each method.  A method passes the check if it begins with exactly
+those statements, and remove removes them.  For example:
+
+  {{$vtrace := .Import "v.io/v23/vtrace"}}
+  ctx, span := {{$vtrace}}.WithNewSpan({{.Context}}, "{{.Interface}}.{{.Method}}")
+  defer span.Finish()
+
 LIMITATIONS:
 
 Removal will not automatically remove the package import for the call to
 be removed.
+
+Whether an uninstantiated generic type implements an interface, or a type
+implements an uninstantiated generic interface, is determined by the names and
+numbers of parameters and results of their methods only.
 `,
 	Children: []*cmdline.Command{cmdCheck, cmdInject, cmdRemove},
 }
 
 // cmdCheck represents the 'check' command of the gologcop tool.
 var cmdCheck = &cmdline.Command{
-	Runner:   jiri.RunnerFunc(runCheck),
-	Name:     "check",
-	Short:    "Check for log statements in public API implementations",
-	Long:     "Check for log statements in public API implementations.",
+	Runner: jiri.RunnerFunc(runCheck),
+	Name:   "check",
+	Short:  "Check for log statements in public API implementations",
+	Long: `Check for log statements in public API implementations.
+The -format=json flag prints the methods that fail the check as JSON, for
+tools such as presubmit checks.  The -fix flag prints the statements to be
+injected as unified diffs, so that they can be applied via
+"gologcop check -fix ... | git apply".
+`,
 	ArgsName: "<packages>",
 	ArgsLong: "<packages> is the list of packages to be checked.",
 }
@@ -123,7 +187,17 @@ func runCheck(jirix *jiri.X, args []string) error {
 	if len(implementationPackageList) == 0 {
 		return jirix.UsageErrorf("no implementation package listed")
 	}
-	return runInjector(jirix, nil, interfacePackageList, implementationPackageList, true)
+	switch formatFlag {
+	case formatText:
+	case formatJSON:
+		if fixFlag {
+			return jirix.UsageErrorf("-fix can't be used with -format=%s", formatJSON)
+		}
+	default:
+		return jirix.UsageErrorf("unknown format %q", formatFlag)
+	}
+	build.Default.BuildTags = splitCommaSeparatedValues(tagsFlag)
+	return runInjector(jirix, interfacePackageList, implementationPackageList, true)
 }
 
 // cmdInject represents the 'inject' command of the gologcop tool.
@@ -143,7 +217,8 @@ you can see the diff or revert the changes.
 // runInject handles the "inject" command and executes
 // the log injector in injection mode.
 func runInject(jirix *jiri.X, args []string) error {
-	return runInjector(jirix, nil, splitCommaSeparatedValues(interfacesFlag), args, false)
+	build.Default.BuildTags = splitCommaSeparatedValues(tagsFlag)
+	return runInjector(jirix, splitCommaSeparatedValues(interfacesFlag), args, false)
 }
 
 // cmdRemove represents the 'remove' command of the gologcop tool.
@@ -162,5 +237,6 @@ you can see the diff or revert the changes.
 
 // runRemove handles the "remove" command.
 func runRemove(jirix *jiri.X, args []string) error {
-	return runRemover(jirix, nil, args)
+	build.Default.BuildTags = splitCommaSeparatedValues(tagsFlag)
+	return runRemover(jirix, splitCommaSeparatedValues(interfacesFlag), args)
 }
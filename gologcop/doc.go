@@ -12,14 +12,59 @@ When checking, it ensures that all implementations in <packages> of all exported
 interfaces declared in packages passed to the -interface flag have an
 appropriate logging construct.
 
+An implementation is any method in the method set of a type T or *T declared in
+<packages>, where T or *T implements the interface, including methods promoted
+via embedding from types declared in any of <packages>.  Each offending method
+is reported along with the interface method it implements.
+
 When injecting or removing, it modifies the source code to inject or remove such
 logging constructs.
 
+Packages are loaded via golang.org/x/tools/go/packages, so <packages> may be in
+a Go module or in a GOPATH workspace.  The go tool runs in the environment of
+the jiri profiles, merged according to -merge-policies.  The listed packages
+are type-checked from source, and type errors in them are fatal, while their
+dependencies are type-checked from export data, which the go tool caches across
+runs.  Files generated by the go tool, e.g. from cgo files, are never modified.
+
+TEMPLATES:
+
+The statements to be injected, checked for or removed may be specified by a
+text/template file via the -template flag, instead of the default
+defer <pkg>.<call>(...)(...) statement.  The template is executed for each
+method with the following fields:
+
+  .Package    the name of the package declaring the method
+  .Method     the name of the method
+  .Receiver   the receiver type of the method, e.g. *T
+  .Interface  the interface implemented by the method, e.g. iface.T
+  .Params     the names of the named parameters
+  .Results    the names of the named results
+  .Context    the name of the v.io/v23/context.T parameter, if any
+
+and the following functions:
+
+  .Import <path>  returns the name to refer to the package <path> by, i.e. the
+                  name it is imported as, adding an import if needed
+  join            strings.Join
+
+The template must produce one or more Go statements, which are injected at the
+beginning of each method.  A method passes the check if it begins with exactly
+those statements, and remove removes them.  For example:
+
+  {{$vtrace := .Import "v.io/v23/vtrace"}}
+  ctx, span := {{$vtrace}}.WithNewSpan({{.Context}}, "{{.Interface}}.{{.Method}}")
+  defer span.Finish()
+
 LIMITATIONS:
 
 Removal will not automatically remove the package import for the call to be
 removed.
 
+Whether an uninstantiated generic type implements an interface, or a type
+implements an uninstantiated generic interface, is determined by the names and
+numbers of parameters and results of their methods only.
+
 Usage:
    gologcop [flags] <command>
 
@@ -32,10 +77,15 @@ The gologcop commands are:
 The gologcop flags are:
  -color=true
    Use color to format output.
+ -include-tests=false
+   Also process the test files of <packages>, including the *_test packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -progress=false
    Print verbose progress information.
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
  -use-v23-context=true
    Pass a context.T argument (which must be of type v.io/v23/context.T), if
    available, to the injected call as its first parameter.
@@ -50,7 +100,10 @@ The global flags are:
 
 Gologcop check - Check for log statements in public API implementations
 
-Check for log statements in public API implementations.
+Check for log statements in public API implementations. The -format=json flag
+prints the methods that fail the check as JSON, for tools such as presubmit
+checks.  The -fix flag prints the statements to be injected as unified diffs,
+so that they can be applied via "gologcop check -fix ... | git apply".
 
 Usage:
    gologcop check [flags] <packages>
@@ -61,17 +114,33 @@ The gologcop check flags are:
  -call=LogCall
    The function call to be checked for as defer <pkg>.<call>()() and defer
    <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.
+ -fix=false
+   Print the fixes for the methods that fail the check as unified diffs, which
+   can be applied from the root of the repository with git apply or patch -p1.
+   The check results are printed to stderr.
+ -format=text
+   Print the methods that fail the check in the given format, text or json. The
+   json format reports the file, line, function, interface method and the
+   statement to be injected for each method.
  -import=v.io/x/ref/lib/apilog
    Import path for the injected call.
  -interface=
    Comma-separated list of interface packages (required).
+ -template=
+   Template file for the statements to be checked for, overriding --call and
+   --import. See the TEMPLATES section of the gologcop help.
 
  -color=true
    Use color to format output.
+ -include-tests=false
+   Also process the test files of <packages>, including the *_test packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -progress=false
    Print verbose progress information.
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
  -use-v23-context=true
    Pass a context.T argument (which must be of type v.io/v23/context.T), if
    available, to the injected call as its first parameter.
@@ -101,13 +170,21 @@ The gologcop inject flags are:
    Import path for the injected call.
  -interface=
    Comma-separated list of interface packages (required).
+ -template=
+   Template file for the statements to be injected, overriding --call and
+   --import. See the TEMPLATES section of the gologcop help.
 
  -color=true
    Use color to format output.
+ -include-tests=false
+   Also process the test files of <packages>, including the *_test packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -progress=false
    Print verbose progress information.
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
  -use-v23-context=true
    Pass a context.T argument (which must be of type v.io/v23/context.T), if
    available, to the injected call as its first parameter.
@@ -134,13 +211,24 @@ The gologcop remove flags are:
    Show changes that would be made without actually making them.
  -gofmt=true
    Automatically run gofmt on the modified files.
+ -interface=
+   Comma-separated list of interface packages, used to determine the interface
+   implemented by each method for --template.
+ -template=
+   Template file for the statements to be removed, overriding --call. See the
+   TEMPLATES section of the gologcop help.
 
  -color=true
    Use color to format output.
+ -include-tests=false
+   Also process the test files of <packages>, including the *_test packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -progress=false
    Print verbose progress information.
+ -tags=
+   Comma-separated list of build tags to consider satisfied when loading
+   packages.
  -use-v23-context=true
    Pass a context.T argument (which must be of type v.io/v23/context.T), if
    available, to the injected call as its first parameter.
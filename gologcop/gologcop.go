@@ -7,8 +7,6 @@ package main
 import (
 	"fmt"
 	"go/ast"
-	"go/build"
-	"go/parser"
 	"go/token"
 	"go/types"
 	"io"
@@ -21,9 +19,9 @@ import (
 	"strings"
 	"unicode"
 
+	"golang.org/x/tools/go/packages"
 	"v.io/jiri"
 	"v.io/jiri/collect"
-	"v.io/x/devtools/internal/goutil"
 )
 
 const (
@@ -51,136 +49,26 @@ var (
 	removePackage, removeCall string
 )
 
-// parseState encapsulates all of the state acquired during parsing and
-// type checking. It makes sure that any give file or package
-// is parsed or type checked once and only once.
+// parseState encapsulates all of the state acquired during loading and type
+// checking.  It makes sure that any given package is loaded once and only once.
 type parseState struct {
-	jirix    *jiri.X
-	config   *types.Config
-	fset     *token.FileSet
-	info     *types.Info
-	packages map[string]*types.Package // keyed by the package path name.
-	asts     map[string][]*ast.File    // keyed by the package path name
+	jirix        *jiri.X
+	fset         *token.FileSet
+	packages     map[string]*packages.Package // keyed by the package path name.
+	testPackages map[string]*packages.Package // test variants, keyed by the package path name.
+	names        map[string]string            // package names, keyed by the package path name.
 }
 
 func newState(jirix *jiri.X) *parseState {
-	ps := &parseState{
-		jirix:    jirix,
-		fset:     token.NewFileSet(),
-		packages: make(map[string]*types.Package),
-		asts:     make(map[string][]*ast.File),
-		config: &types.Config{
-			IgnoreFuncBodies: true,
-		},
-		info: &types.Info{
-			Types: make(map[ast.Expr]types.TypeAndValue),
-			Defs:  make(map[*ast.Ident]types.Object),
-			Uses:  make(map[*ast.Ident]types.Object),
-		},
-	}
-	ps.config.Importer = ps
-	return ps
-}
-
-func (ps *parseState) Import(path string) (*types.Package, error) {
-	return ps.sourceImporter(path)
-}
-
-func (ps *parseState) parsedPackage(path string) (*types.Package, []*ast.File) {
-	return ps.packages[path], ps.asts[path]
-}
-
-func (ps *parseState) addParsedPackage(path string, pkg *types.Package, asts []*ast.File) {
-	if p, _ := ps.parsedPackage(path); p != nil {
-		fmt.Fprintf(ps.jirix.Stdout(), "Warning: %s is already cached\n", path)
-		return
-	}
-	ps.packages[path] = pkg
-	ps.asts[path] = asts
-}
-
-// sourceImporter will always import from source code.
-func (ps *parseState) sourceImporter(path string) (*types.Package, error) {
-	// It seems that we need to special case the unsafe package.
-	if path == "unsafe" {
-		return types.Unsafe, nil
-	}
-	if pkg, _ := ps.parsedPackage(path); pkg != nil {
-		return pkg, nil
-	}
-	progressMsg(ps.jirix.Stdout(), "importing from source: %s\n", path)
-	bpkg, err := build.Default.Import(path, ".", build.ImportMode(build.ImportComment))
-	_, pkg, err := ps.parseAndTypeCheckPackage(bpkg)
-	if err != nil {
-		return nil, err
-	}
-	return pkg, err
-}
-
-// parseAndTypeCheckPackage will parse and type check a given package.
-func (ps *parseState) parseAndTypeCheckPackage(bpkg *build.Package) ([]*ast.File, *types.Package, error) {
-	if tpkg, asts := ps.parsedPackage(bpkg.ImportPath); tpkg != nil {
-		return asts, tpkg, nil
-	}
-
-	tpkg := types.NewPackage(bpkg.ImportPath, bpkg.Name)
-	checker := types.NewChecker(ps.config, ps.fset, tpkg, ps.info)
-
-	// Parse the files in this package.
-	asts := []*ast.File{}
-	dir := bpkg.Dir
-	for _, fileInPkg := range bpkg.GoFiles {
-		file := filepath.Join(dir, fileInPkg)
-		a, err := parser.ParseFile(ps.fset, file, nil, parser.ParseComments)
-		if err != nil {
-			return nil, nil, err
-		}
-		asts = append(asts, a)
-	}
-	if err := checker.Files(asts); err != nil {
-		return nil, nil, err
-	}
-	// make sure that type checking is complete at this stage. It should
-	// always be so, so this is really an 'assertion' that it is.
-	if !tpkg.Complete() {
-		return nil, nil, fmt.Errorf("checked %q is not completely parsed+checked", bpkg.Name)
+	return &parseState{
+		jirix:        jirix,
+		fset:         token.NewFileSet(),
+		packages:     make(map[string]*packages.Package),
+		testPackages: make(map[string]*packages.Package),
+		names:        make(map[string]string),
 	}
-	progressMsg(ps.jirix.Stdout(), "parsed from source: %s\n", bpkg.ImportPath)
-	ps.addParsedPackage(bpkg.ImportPath, tpkg, asts)
-	return asts, tpkg, nil
 }
 
-// importPkgs will expand the supplied list of  packages using go list
-// (so v.io/v23/... can be used as an interface package spec for example) and
-// then import those packages.
-func importPkgs(jirix *jiri.X, goFlags, packageSpec []string) (ifcs []*build.Package, err error) {
-	flags := append(goFlags, "-merge-policies="+mergePoliciesFlag.String())
-	pkgs, err := goutil.List(jirix, flags, packageSpec...)
-	if err != nil {
-		return nil, err
-	}
-	importer := func(pkgs []string) ([]*build.Package, error) {
-		pkgInfos := []*build.Package{}
-		for _, pkg := range pkgs {
-			pkgInfo, err := build.Default.Import(pkg, ".", build.ImportMode(build.ImportComment))
-			if err != nil {
-				return nil, err
-			}
-			pkgInfos = append(pkgInfos, pkgInfo)
-		}
-		return pkgInfos, nil
-	}
-	bpkgs, err := importer(pkgs)
-	if err != nil {
-		return nil, fmt.Errorf("error importing packages: %v", err)
-	}
-	return bpkgs, nil
-}
-
-// exists is used as the value to indicate existence for maps that
-// function as sets.
-var exists = struct{}{}
-
 func initInjectorFlags() error {
 	parts := strings.FieldsFunc(injectCallImportFlag, unicode.IsSpace)
 	var err error
@@ -203,22 +91,22 @@ func initInjectorFlags() error {
 		return fmt.Errorf("%q doesn't look like an import declaration", injectCallImportFlag)
 	}
 	injectCall = injectCallFlag
-	return nil
+	return initTemplate(templateFlag)
 }
 
 // run runs the log injector.
-func runInjector(jirix *jiri.X, goFlags, interfaceList, implementationList []string, checkOnly bool) error {
+func runInjector(jirix *jiri.X, interfaceList, implementationList []string, checkOnly bool) error {
 	if err := initInjectorFlags(); err != nil {
 		return err
 	}
-	// use 'go list' and the builder to import all of the packages
-	// specified as interfaces and implementations.
-	ifcs, err := importPkgs(jirix, goFlags, interfaceList)
+	// use go/packages to expand the packages specified as interfaces and
+	// implementations.
+	ifcs, err := importPkgs(jirix, interfaceList)
 	if err != nil {
 		return err
 	}
 
-	impls, err := importPkgs(jirix, goFlags, implementationList)
+	impls, err := importPkgs(jirix, implementationList)
 	if err != nil {
 		return err
 	}
@@ -229,53 +117,83 @@ func runInjector(jirix *jiri.X, goFlags, interfaceList, implementationList []str
 
 	ps := newState(jirix)
 	checkFailed := []string{}
+	failures := map[funcDeclRef]error{}
 
-	printHeader(jirix.Stdout(), "Parsing and Type Checking Interface Packages")
+	// The check results go to stderr if stdout is reserved for the JSON
+	// results or the fixes.
+	out := jirix.Stdout()
+	if checkOnly && (formatFlag != formatText || fixFlag) {
+		out = jirix.Stderr()
+	}
+
+	printHeader(jirix.Stdout(), "Parsing and Type Checking Packages")
+	if err := ps.load(append(ifcs, impls...), includeTestsFlag); err != nil {
+		return fmt.Errorf("failed to parse+type check: %s", err)
+	}
 
 	ifcPkgs := []*types.Package{}
 	for _, ifc := range ifcs {
-		_, tpkg, err := ps.parseAndTypeCheckPackage(ifc)
-		if err != nil {
-			return fmt.Errorf("failed to parse+type check: %s: %s", ifc.ImportPath, err)
-		}
-		ifcPkgs = append(ifcPkgs, tpkg)
+		ifcPkgs = append(ifcPkgs, ps.packages[ifc].Types)
 	}
 	publicInterfaces := findPublicInterfaces(jirix, ifcPkgs)
 
+	// Now find the methods that implement those public interfaces.  The
+	// methods of all packages are found before any are checked, since a
+	// method may be promoted via embedding to a type in another package that
+	// implements an interface.
+	methods := map[token.Pos]*types.Named{}
 	for _, impl := range impls {
-		printHeader(jirix.Stdout(), "Parsing and Type Checking Implementation Packages")
-		asts, tpkg, err := ps.parseAndTypeCheckPackage(impl)
-		if err != nil {
-			return fmt.Errorf("failed to parse+type check: %s: %s", impl.ImportPath, err)
+		for _, pkg := range ps.implPackages(impl) {
+			for pos, ifc := range findMethodsImplementing(jirix, ps.fset, pkg.Types, publicInterfaces) {
+				if _, ok := methods[pos]; !ok {
+					methods[pos] = ifc
+				}
+			}
 		}
+	}
 
-		// Now find the methods that implement those public interfaces.
-		methods := findMethodsImplementing(jirix, ps.fset, tpkg, publicInterfaces)
-
-		// and their positions in the files.
-		methodPositions, err := functionDeclarationsAtPositions(ps.fset, asts, ps.info, methods)
-		if err != nil {
-			return err
-		}
-		// then check to see if those methods already have logging statements.
-		needsInjection := checkMethods(methodPositions)
-
-		if checkOnly {
-			if len(needsInjection) > 0 {
-				printHeader(jirix.Stdout(), "Check Results")
-				reportResults(jirix, ps.fset, needsInjection)
-				checkFailed = append(checkFailed, impl.ImportPath)
+	for _, impl := range impls {
+		for _, pkg := range ps.implPackages(impl) {
+			// and their positions in the files.
+			methodPositions, err := functionDeclarationsAtPositions(ps.fset, ps.sourceFiles(pkg), pkg.TypesInfo, methods, ps.packageName)
+			if err != nil {
+				return err
 			}
-		} else {
-			if err := inject(jirix, ps.fset, needsInjection); err != nil {
-				return fmt.Errorf("injection failed for: %s: %s", impl.ImportPath, err)
+			// then check to see if those methods already have logging statements.
+			needsInjection := checkMethods(methodPositions)
+
+			if checkOnly {
+				if len(needsInjection) > 0 {
+					printHeader(out, "Check Results")
+					if err := printResults(out, formatText, ps.fset, needsInjection); err != nil {
+						return err
+					}
+					for m, err := range needsInjection {
+						failures[m] = err
+					}
+					checkFailed = append(checkFailed, pkg.PkgPath)
+				}
+			} else {
+				if err := inject(jirix, ps.fset, needsInjection); err != nil {
+					return fmt.Errorf("injection failed for: %s: %s", pkg.PkgPath, err)
+				}
 			}
 		}
 	}
 
+	if checkOnly && formatFlag == formatJSON {
+		if err := printResults(jirix.Stdout(), formatJSON, ps.fset, failures); err != nil {
+			return err
+		}
+	}
+	if checkOnly && fixFlag {
+		if err := writeFiles(jirix, ps.fset, injectPatches(ps.fset, failures)); err != nil {
+			return err
+		}
+	}
 	if checkOnly && len(checkFailed) > 0 {
 		for _, p := range checkFailed {
-			fmt.Fprintf(jirix.Stdout(), "check failed for: %s\n", p)
+			fmt.Fprintf(out, "check failed for: %s\n", p)
 		}
 		os.Exit(1)
 	}
@@ -284,6 +202,12 @@ func runInjector(jirix *jiri.X, goFlags, interfaceList, implementationList []str
 }
 
 func initRemoverFlags() error {
+	if err := initTemplate(templateFlag); err != nil {
+		return err
+	}
+	if injectTemplate != nil {
+		return nil
+	}
 	parts := strings.Split(removeCallFlag, ".")
 	switch len(parts) {
 	case 2:
@@ -295,14 +219,19 @@ func initRemoverFlags() error {
 	return nil
 }
 
-func runRemover(jirix *jiri.X, goFlags, implementationList []string) error {
+func runRemover(jirix *jiri.X, interfaceList, implementationList []string) error {
 	if err := initRemoverFlags(); err != nil {
 		return err
 	}
 
-	// use 'go list' and the builder to import all of the packages
-	// specified as implementations.
-	impls, err := importPkgs(jirix, goFlags, implementationList)
+	// use go/packages to expand the packages specified as interfaces and
+	// implementations.
+	ifcs, err := importPkgs(jirix, interfaceList)
+	if err != nil {
+		return err
+	}
+
+	impls, err := importPkgs(jirix, implementationList)
 	if err != nil {
 		return err
 	}
@@ -310,21 +239,37 @@ func runRemover(jirix *jiri.X, goFlags, implementationList []string) error {
 	ps := newState(jirix)
 
 	printHeader(jirix.Stdout(), "Package Summary")
+	progressMsg(jirix.Stdout(), "%v expands to %d interface packages\n", interfaceList, len(ifcs))
 	progressMsg(jirix.Stdout(), "%v expands to %d implementation packages\n", implementationList, len(impls))
 
+	if err := ps.load(append(ifcs, impls...), includeTestsFlag); err != nil {
+		return fmt.Errorf("failed to parse+type check: %s", err)
+	}
+
+	ifcPkgs := []*types.Package{}
+	for _, ifc := range ifcs {
+		ifcPkgs = append(ifcPkgs, ps.packages[ifc].Types)
+	}
+	publicInterfaces := findPublicInterfaces(jirix, ifcPkgs)
+
 	for _, impl := range impls {
-		asts, tpkg, err := ps.parseAndTypeCheckPackage(impl)
-		if err != nil {
-			return fmt.Errorf("failed to parse+type check: %s: %s", impl.ImportPath, err)
-		}
-		methods := findMethods(jirix, ps.fset, tpkg)
-		methodPositions, err := functionDeclarationsAtPositions(ps.fset, asts, ps.info, methods)
-		if err != nil {
-			return err
-		}
-		needsRemoval := findRemovals(methodPositions)
-		if err := remove(jirix, ps.fset, needsRemoval); err != nil {
-			return fmt.Errorf("removal failed for: %s: %s", impl.ImportPath, err)
+		for _, pkg := range ps.implPackages(impl) {
+			methods := findMethods(jirix, ps.fset, pkg.Types)
+			// Record the interfaces implemented by the methods, so that
+			// templates that refer to them generate the injected statements.
+			if len(publicInterfaces) > 0 {
+				for pos, ifc := range findMethodsImplementing(jirix, ps.fset, pkg.Types, publicInterfaces) {
+					methods[pos] = ifc
+				}
+			}
+			methodPositions, err := functionDeclarationsAtPositions(ps.fset, ps.sourceFiles(pkg), pkg.TypesInfo, methods, ps.packageName)
+			if err != nil {
+				return err
+			}
+			needsRemoval := findRemovals(methodPositions)
+			if err := remove(jirix, ps.fset, needsRemoval); err != nil {
+				return fmt.Errorf("removal failed for: %s: %s", pkg.PkgPath, err)
+			}
 		}
 	}
 	return nil
@@ -333,23 +278,39 @@ func runRemover(jirix *jiri.X, goFlags, implementationList []string) error {
 // funcDeclRef stores a reference to a function declaration, paired
 // with the file containing it.
 type funcDeclRef struct {
-	Decl    *ast.FuncDecl
-	File    *ast.File
-	LogCall string
+	Decl      *ast.FuncDecl
+	File      *ast.File
+	LogCall   string
+	Interface *types.Named      // The interface implemented by the method, if known.
+	Imports   *[]templateImport // Imports used by a templated LogCall.
+}
+
+// InterfaceMethod returns the name of the interface method implemented by the
+// method, qualified by the package path of the interface, e.g.
+// "v.io/v23/rpc.Server.Stop", or "" if unknown.
+func (m funcDeclRef) InterfaceMethod() string {
+	if m.Interface == nil {
+		return ""
+	}
+	obj := m.Interface.Obj()
+	return obj.Pkg().Path() + "." + obj.Name() + "." + m.Decl.Name.Name
 }
 
 // methodSetVisibleThroughInterfaces returns intersection of all
 // exported method names implemented by t and the union of all method
-// names declared by interfaces.
-func methodSetVisibleThroughInterfaces(t types.Type, interfaces []*types.Interface) map[string]struct{} {
-	set := map[string]struct{}{}
-	for _, ifc := range interfaces {
-		if types.Implements(t, ifc) || types.Implements(types.NewPointer(t), ifc) {
+// names declared by interfaces, mapped to the first interface that
+// declares them.
+func methodSetVisibleThroughInterfaces(t types.Type, interfaces []*types.Named) map[string]*types.Named {
+	set := map[string]*types.Named{}
+	for _, named := range interfaces {
+		if implements(t, named) {
 			// t implements ifc, so add all the public
 			// method names of ifc to set.
+			ifc := named.Underlying().(*types.Interface)
 			for i := 0; i < ifc.NumMethods(); i++ {
-				if name := ifc.Method(i).Name(); ast.IsExported(name) {
-					set[name] = exists
+				name := ifc.Method(i).Name()
+				if _, ok := set[name]; !ok && ast.IsExported(name) {
+					set[name] = named
 				}
 			}
 		}
@@ -357,6 +318,39 @@ func methodSetVisibleThroughInterfaces(t types.Type, interfaces []*types.Interfa
 	return set
 }
 
+// implements returns true if t or *t implements the interface named.  The
+// behavior of types.Implements is unspecified for uninstantiated generic
+// types, so if either t or named is generic, t is considered to implement
+// named if the method set of *t has methods with the same names, number of
+// parameters and results, and variadicity as those of named.
+func implements(t types.Type, named *types.Named) bool {
+	ifc := named.Underlying().(*types.Interface)
+	if !isGeneric(t) && !isGeneric(named) {
+		return types.Implements(t, ifc) || types.Implements(types.NewPointer(t), ifc)
+	}
+	methodSet := types.NewMethodSet(types.NewPointer(t))
+	for i := 0; i < ifc.NumMethods(); i++ {
+		method := ifc.Method(i)
+		sel := methodSet.Lookup(method.Pkg(), method.Name())
+		if sel == nil {
+			return false
+		}
+		want := method.Type().(*types.Signature)
+		got := sel.Obj().Type().(*types.Signature)
+		if want.Params().Len() != got.Params().Len() || want.Results().Len() != got.Results().Len() || want.Variadic() != got.Variadic() {
+			return false
+		}
+	}
+	return true
+}
+
+// isGeneric returns true if t is a generic type that has not been
+// instantiated.
+func isGeneric(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.TypeParams().Len() > 0 && named.TypeArgs().Len() == 0
+}
+
 func hasV23Context(info *types.Info, parameters *ast.FieldList) (*ast.FieldList, string) {
 	if !useContextFlag {
 		return parameters, ""
@@ -405,6 +399,11 @@ func genFmt(info *types.Info, fields *ast.FieldList, indirect bool) ([]string, [
 	args := []string{}
 	for _, param := range fields.List {
 		typ := info.TypeOf(param.Type)
+		if _, ok := param.Type.(*ast.Ellipsis); ok {
+			// Variadic parameters may or may not have a recorded type, depending
+			// on how the package was type-checked, so treat them uniformly.
+			typ = nil
+		}
 		var f string
 		printable := false
 		ellipsis := false
@@ -502,26 +501,36 @@ func genCall(info *types.Info, params, results *ast.FieldList) (string, error) {
 
 // functionDeclarationsAtPositions returns references to function
 // declarations in packages where the position of the identifier token
-// representing the name of the function is in positions.
-func functionDeclarationsAtPositions(fset *token.FileSet, files []*ast.File, info *types.Info, positions map[token.Pos]struct{}) ([]funcDeclRef, error) {
+// representing the name of the function is in positions.  pkgName returns the
+// name of the package with the given import path, for use by templates.
+func functionDeclarationsAtPositions(fset *token.FileSet, files []*ast.File, info *types.Info, positions map[token.Pos]*types.Named, pkgName func(string) (string, error)) ([]funcDeclRef, error) {
 	result := []funcDeclRef{}
 	for _, file := range files {
 		for _, decl := range file.Decls {
 			if decl, ok := decl.(*ast.FuncDecl); ok {
-				call, err := genCall(info, decl.Type.Params, decl.Type.Results)
-				if err != nil {
-					pos := fset.Position(decl.Pos())
-					return nil, fmt.Errorf("%s:%d: %v", pos.Filename, pos.Line, err)
-				}
 				// for each function declaration in packages:
 				//
 				// it's important not to use decl.Pos() here
 				// as it gives us the position of the "func"
 				// token, whereas positions has collected
 				// the locations of method name tokens:
-				if _, ok := positions[decl.Name.Pos()]; ok {
-					result = append(result, funcDeclRef{decl, file, call})
+				ifc, ok := positions[decl.Name.Pos()]
+				if !ok {
+					continue
 				}
+				var call string
+				var imports []templateImport
+				var err error
+				if injectTemplate != nil {
+					call, imports, err = genTemplateCall(info, file, decl, ifc, pkgName)
+				} else {
+					call, err = genCall(info, decl.Type.Params, decl.Type.Results)
+				}
+				if err != nil {
+					pos := fset.Position(decl.Pos())
+					return nil, fmt.Errorf("%s:%d: %v", pos.Filename, pos.Line, err)
+				}
+				result = append(result, funcDeclRef{decl, file, call, ifc, &imports})
 			}
 		}
 	}
@@ -531,7 +540,7 @@ func functionDeclarationsAtPositions(fset *token.FileSet, files []*ast.File, inf
 // findMethodsImplementing searches the specified packages and returns
 // a list of function declarations that are implementations for
 // the specified interfaces.
-func findMethodsImplementing(jirix *jiri.X, fset *token.FileSet, tpkg *types.Package, interfaces []*types.Interface) map[token.Pos]struct{} {
+func findMethodsImplementing(jirix *jiri.X, fset *token.FileSet, tpkg *types.Package, interfaces []*types.Named) map[token.Pos]*types.Named {
 	// positions will hold the set of Pos values of methods
 	// that should be logged.  Each element will be the position of
 	// the identifier token representing the method name of such
@@ -539,7 +548,8 @@ func findMethodsImplementing(jirix *jiri.X, fset *token.FileSet, tpkg *types.Pac
 	// our static analysis library has no easy way to map types.Func
 	// objects to ast.FuncDecl objects, so we then look into AST
 	// declarations and find everything that has a matching position.
-	positions := map[token.Pos]struct{}{}
+	// Each position is mapped to the interface the method implements.
+	positions := map[token.Pos]*types.Named{}
 
 	printHeader(jirix.Stdout(), "Methods Implementing Public Interfaces in %s", tpkg.Path())
 
@@ -559,23 +569,25 @@ func findMethodsImplementing(jirix *jiri.X, fset *token.FileSet, tpkg *types.Pac
 		// we care about, we can just ignore it.
 		if len(apiMethodSet) > 0 {
 			// find all the methods explicitly declared or implicitly
-			// inherited through embedding on type t or *t.
-			methodSet := types.NewMethodSet(typ)
+			// inherited through embedding on type t or *t.  The method
+			// set of *t includes the methods of t, so it is only empty
+			// if t is itself a pointer.
+			methodSet := types.NewMethodSet(types.NewPointer(typ))
 			if methodSet.Len() == 0 {
-				methodSet = types.NewMethodSet(types.NewPointer(typ))
+				methodSet = types.NewMethodSet(typ)
 			}
 			for i := 0; i < methodSet.Len(); i++ {
 				method := methodSet.At(i)
 				fn := method.Obj().(*types.Func)
 				// t may have a method that is not declared in any of
 				// the interfaces we care about. No need to log that.
-				if _, ok := apiMethodSet[fn.Name()]; ok {
+				if ifc, ok := apiMethodSet[fn.Name()]; ok {
 					if fn.Pos() == 0 {
-						// Embedded functions show up with a zero pos.
+						// Methods without source show up with a zero pos.
 						continue
 					}
 					progressMsg(jirix.Stdout(), "%s.%s: %s\n", tpkg.Path(), fn.Name(), fset.Position(fn.Pos()))
-					positions[fn.Pos()] = exists
+					positions[fn.Pos()] = ifc
 				}
 			}
 		}
@@ -583,7 +595,7 @@ func findMethodsImplementing(jirix *jiri.X, fset *token.FileSet, tpkg *types.Pac
 	return positions
 }
 
-func findMethodsInScope(jirix *jiri.X, fset *token.FileSet, positions map[token.Pos]struct{}, scope *types.Scope) {
+func findMethodsInScope(jirix *jiri.X, fset *token.FileSet, positions map[token.Pos]*types.Named, scope *types.Scope) {
 	for _, child := range scope.Names() {
 		object := scope.Lookup(child)
 		typ := object.Type()
@@ -591,16 +603,16 @@ func findMethodsInScope(jirix *jiri.X, fset *token.FileSet, positions map[token.
 		case *types.Named:
 			for i := 0; i < v.NumMethods(); i++ {
 				m := v.Method(i)
-				positions[m.Pos()] = exists
+				positions[m.Pos()] = nil
 			}
 		case *types.Signature:
-			positions[object.Pos()] = exists
+			positions[object.Pos()] = nil
 		}
 	}
 }
 
-func findMethods(jirix *jiri.X, fset *token.FileSet, tpkg *types.Package) map[token.Pos]struct{} {
-	positions := map[token.Pos]struct{}{}
+func findMethods(jirix *jiri.X, fset *token.FileSet, tpkg *types.Package) map[token.Pos]*types.Named {
+	positions := map[token.Pos]*types.Named{}
 	printHeader(jirix.Stdout(), "Methods in %s", tpkg.Path())
 	scope := tpkg.Scope()
 	findMethodsInScope(jirix, fset, positions, scope)
@@ -649,14 +661,14 @@ func countOverlap(a, b string) (i int) {
 	return
 }
 
-// ©3ImportLogPackage will make sure that the file includes an
-// import declaration to the package to be injected, and adds one if it does not
-// already.
-func ensureImportLogPackage(fset *token.FileSet, file *ast.File) (patch, bool) {
+// ensureImport will make sure that the file includes an import declaration
+// to the package with the given import path and tag, and adds one if it does
+// not already.
+func ensureImport(fset *token.FileSet, file *ast.File, importTag, importPath string) (patch, bool) {
 	maxOverlap := 0
 	var candidate token.Pos
 
-	quotedImportPath := strconv.Quote(injectImportPath)
+	quotedImportPath := strconv.Quote(importPath)
 
 	for _, d := range file.Decls {
 		d, ok := d.(*ast.GenDecl)
@@ -676,12 +688,12 @@ func ensureImportLogPackage(fset *token.FileSet, file *ast.File) (patch, bool) {
 			path := s.Path.Value
 
 			// Match import tag.
-			if len(injectImportTag) > 0 && injectImportTag == tag {
+			if len(importTag) > 0 && importTag == tag {
 				return patch{}, false
 			}
 
-			// Match path.
-			if quotedImportPath == path {
+			// Match path, unless the package can't be referred to by name.
+			if quotedImportPath == path && tag != "_" && tag != "." {
 				return patch{}, false
 			}
 
@@ -698,8 +710,8 @@ func ensureImportLogPackage(fset *token.FileSet, file *ast.File) (patch, bool) {
 	}
 
 	impStmt := func() string {
-		if len(injectImportTag) > 0 {
-			return injectImportTag + " " + quotedImportPath + "\n"
+		if len(importTag) > 0 {
+			return importTag + " " + quotedImportPath + "\n"
 		}
 		return quotedImportPath + "\n"
 	}
@@ -741,7 +753,13 @@ func methodBeginsWithNoLogComment(m funcDeclRef) bool {
 func findRemovals(methods []funcDeclRef) map[funcDeclRef]error {
 	result := map[funcDeclRef]error{}
 	for _, m := range methods {
-		if err := validateLogStatement(m.Decl, removePackage, removeCall); err == nil {
+		var err error
+		if injectTemplate != nil {
+			_, err = matchTemplate(m)
+		} else {
+			err = validateLogStatement(m.Decl, removePackage, removeCall)
+		}
+		if err == nil {
 			result[m] = nil
 		}
 	}
@@ -763,7 +781,13 @@ func checkMethods(methods []funcDeclRef) map[funcDeclRef]error {
 // checkMethod checks that method includes an acceptable logging
 // construct before any other non-whitespace or non-comment token.
 func checkMethod(method funcDeclRef) error {
-	if err := validateLogStatement(method.Decl, injectPackage, injectCall); err != nil && !methodBeginsWithNoLogComment(method) {
+	var err error
+	if injectTemplate != nil {
+		_, err = matchTemplate(method)
+	} else {
+		err = validateLogStatement(method.Decl, injectPackage, injectCall)
+	}
+	if err != nil && !methodBeginsWithNoLogComment(method) {
 		return err
 	}
 	return nil
@@ -810,7 +834,7 @@ func writeFiles(jirix *jiri.X, fset *token.FileSet, files map[*ast.File][]patch)
 			beginOffset = patch.NextOffset
 		}
 		patchedSrc = append(patchedSrc, src[beginOffset:]...)
-		if diffOnlyFlag {
+		if diffOnlyFlag || fixFlag {
 			tmpDir, err := s.TempDir("", "")
 			if err != nil {
 				return err
@@ -822,12 +846,20 @@ func writeFiles(jirix *jiri.X, fset *token.FileSet, files map[*ast.File][]patch)
 			}
 			progressMsg(jirix.Stdout(), "Diffing %s with %s\n", filename, tmpFilename)
 			gofmt(jirix, false, []string{tmpFilename})
-			s.Verbose(false).Capture(jirix.Stdout(), jirix.Stderr()).Last("diff", filename, tmpFilename)
+			args := []string{filename, tmpFilename}
+			if fixFlag {
+				// Unified diffs with a/ and b/ prefixes, relative to
+				// the root of the repository, can be applied from the
+				// root with git apply or patch -p1.
+				rel := filepath.ToSlash(repoRelPath(filename))
+				args = append([]string{"-u", "-L", "a/" + rel, "-L", "b/" + rel}, args...)
+			}
+			s.Verbose(false).Capture(jirix.Stdout(), jirix.Stderr()).Last("diff", args...)
 		} else {
 			s.WriteFile(filename, patchedSrc, 644).Done()
 		}
 	}
-	if diffOnlyFlag {
+	if diffOnlyFlag || fixFlag {
 		return nil
 	}
 	return gofmt(jirix, jirix.Verbose(), filesToFormat)
@@ -844,13 +876,14 @@ func remove(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) e
 		}
 	}
 
-	// endAt returns the position of the next statement, comment or function,
-	// i.e. the end of the block of code to be removed.
-	endAt := func(fn *ast.FuncDecl, cm ast.CommentMap) int {
+	// endAt returns the position of the next statement, comment or function
+	// after the first n statements, i.e. the end of the block of code to be
+	// removed.
+	endAt := func(fn *ast.FuncDecl, n int, cm ast.CommentMap) int {
 		endpos := fn.Body.Rbrace
-		stmt := fn.Body.List[0]
-		if len(fn.Body.List) > 1 {
-			nextStmt := fn.Body.List[1]
+		stmt := fn.Body.List[n-1]
+		if len(fn.Body.List) > n {
+			nextStmt := fn.Body.List[n]
 			endpos = nextStmt.Pos()
 			if cg := cm.Filter(nextStmt).Comments(); len(cg) > 0 {
 				if len(cg[0].List) > 0 {
@@ -861,7 +894,7 @@ func remove(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) e
 				}
 			}
 		}
-		stmtLine := fset.Position(stmt.Pos()).Line
+		stmtLine := fset.Position(stmt.End()).Line
 		// Delete any comment on the same line as the logcall.
 		for _, cg := range cm.Filter(stmt).Comments() {
 			for _, c := range cg.List {
@@ -880,9 +913,17 @@ func remove(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) e
 		if len(stmts) == 0 {
 			return fmt.Errorf("no statements found for %s", m.Decl.Name)
 		}
-		// The first statement should be the call we want to remove.
+		// The first statement should be the call we want to remove, or the
+		// first of the statements generated by the template.
+		n := 1
+		if injectTemplate != nil {
+			var err error
+			if n, err = matchTemplate(m); err != nil {
+				return err
+			}
+		}
 		start := fset.Position(stmts[0].Pos()).Offset
-		end := endAt(m.Decl, comments[m.File])
+		end := endAt(m.Decl, n, comments[m.File])
 		files[file] = append(files[file], removeRange(start, end))
 	}
 	return writeFiles(jirix, fset, files)
@@ -902,6 +943,12 @@ func inject(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) e
 		}
 	}
 
+	return writeFiles(jirix, fset, injectPatches(fset, methods))
+}
+
+// injectPatches returns the patches that inject a log call at the beginning
+// of each method in methods, and the imports it needs.
+func injectPatches(fset *token.FileSet, methods map[funcDeclRef]error) map[*ast.File][]patch {
 	files := map[*ast.File][]patch{}
 	for m, _ := range methods {
 		text := m.LogCall
@@ -916,20 +963,36 @@ func inject(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) e
 		files[file] = append(files[file], delta)
 	}
 
-	for file, deltas := range files {
-		if delta, hasChanges := ensureImportLogPackage(fset, file); hasChanges {
-			files[file] = append(deltas, delta)
+	if injectTemplate != nil {
+		imports := map[*ast.File]map[string]string{}
+		for m, _ := range methods {
+			if imports[m.File] == nil {
+				imports[m.File] = map[string]string{}
+			}
+			for _, imp := range *m.Imports {
+				imports[m.File][imp.Path] = imp.Name
+			}
+		}
+		for file, names := range imports {
+			sorted := []string{}
+			for path := range names {
+				sorted = append(sorted, path)
+			}
+			sort.Strings(sorted)
+			for _, path := range sorted {
+				if delta, hasChanges := ensureImport(fset, file, names[path], path); hasChanges {
+					files[file] = append(files[file], delta)
+				}
+			}
+		}
+	} else {
+		for file, deltas := range files {
+			if delta, hasChanges := ensureImport(fset, file, injectImportTag, injectImportPath); hasChanges {
+				files[file] = append(deltas, delta)
+			}
 		}
 	}
-	return writeFiles(jirix, fset, files)
-}
-
-// reportResults prints out the validation results from checkMethods
-// in a human-readable form.
-func reportResults(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) {
-	for m, err := range methods {
-		fmt.Fprintf(jirix.Stdout(), "%v: %s: %v\n", fset.Position(m.Decl.Pos()), m.Decl.Name.Name, err)
-	}
+	return files
 }
 
 // ensureExprsArePointers returns an error if at least one of the
@@ -1032,20 +1095,22 @@ func progressMsg(out io.Writer, format string, args ...interface{}) {
 
 // findPublicInterfaces returns all the public interfaces defined in the
 // supplied packages.
-func findPublicInterfaces(jirix *jiri.X, ifcs []*types.Package) (interfaces []*types.Interface) {
+func findPublicInterfaces(jirix *jiri.X, ifcs []*types.Package) (interfaces []*types.Named) {
 	for _, ifc := range ifcs {
 		printHeader(jirix.Stdout(), "Public Interfaces for %s", ifc.Path())
 		scope := ifc.Scope()
 		for _, child := range scope.Names() {
 			object := scope.Lookup(child)
-			typ := object.Type()
+			typ := types.Unalias(object.Type())
 
 			if object.Exported() && types.IsInterface(typ) {
-				ifcType := typ.Underlying().(*types.Interface)
-
-				if !ifcType.Empty() {
+				named, ok := typ.(*types.Named)
+				if !ok {
+					continue
+				}
+				if !named.Underlying().(*types.Interface).Empty() {
 					progressMsg(jirix.Stdout(), "%s.%s\n", ifc.Path(), object.Name())
-					interfaces = append(interfaces, ifcType)
+					interfaces = append(interfaces, named)
 				}
 			}
 		}
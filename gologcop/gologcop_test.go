@@ -7,11 +7,13 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/build"
 	"go/token"
 	"go/types"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -31,7 +33,7 @@ const (
 	failingPrefix               = "failschecks"
 	withArgsPrefix              = "withargs"
 	withCommandLinePrefix       = "commandline"
-	failingPackageCount         = 8
+	failingPackageCount         = 10
 	withArgsPackageCount        = 2
 	withCommandLinePackageCount = 2
 	testPackagePrefix           = "v.io/x/devtools/gologcop/testdata"
@@ -55,11 +57,14 @@ func TestInvalidPackages(t *testing.T) {
 		if len(methods) == 0 {
 			t.Fatalf("Test package %q passes log checks but it should not", pkg)
 		}
+		for m := range methods {
+			if got, want := m.InterfaceMethod(), "."+m.Decl.Name.Name; !strings.HasPrefix(got, testPackagePrefix+"/iface.") || !strings.HasSuffix(got, want) {
+				t.Errorf("Test package %q: got interface method %q for %s", pkg, got, m.Decl.Name.Name)
+			}
+		}
 	}
 }
 
-var skipProfiles = []string{"-skip-profiles"}
-
 func TestRemove(t *testing.T) {
 	fake, cleanup := jiritest.NewFakeJiriRoot(t)
 	defer cleanup()
@@ -72,7 +77,7 @@ func TestRemove(t *testing.T) {
 	pkg := path.Join(testPackagePrefix, "passeschecks")
 
 	diffOnlyFlag = true
-	if err := runRemover(fake.X, skipProfiles, []string{pkg}); err != nil {
+	if err := runRemover(fake.X, nil, []string{pkg}); err != nil {
 		t.Fatal(err)
 	}
 	diffs := []string{}
@@ -174,6 +179,197 @@ func TestInjectCommandLine(t *testing.T) {
 	testInject(t, "iface3", withCommandLinePrefix, withCommandLinePackageCount)
 }
 
+func TestCheckJSON(t *testing.T) {
+	pkg := path.Join(testPackagePrefix, failingPrefix, "test9")
+	fset, methods := doTest(t, []string{pkg})
+	var buf bytes.Buffer
+	if err := printResults(&buf, formatJSON, fset, methods); err != nil {
+		t.Fatal(err)
+	}
+	var results []jsonResult
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if got, want := len(results), 1; got != want {
+		t.Fatalf("got %d results, want %d: %v", got, want, results)
+	}
+	result := results[0]
+	if got, want := filepath.ToSlash(result.File), "testdata/failschecks/test9/test9.go"; !strings.HasSuffix(got, want) {
+		t.Errorf("got file %q, want suffix %q", got, want)
+	}
+	if got, want := result.Line, 18; got != want {
+		t.Errorf("got line %d, want %d", got, want)
+	}
+	if got, want := result.Function, "(*MixedType).Method2"; got != want {
+		t.Errorf("got function %q, want %q", got, want)
+	}
+	if got, want := result.Interface, testPackagePrefix+"/iface.Interface1.Method2"; got != want {
+		t.Errorf("got interface %q, want %q", got, want)
+	}
+	if got, want := result.Statement, "defer apilog.LogCall("; !strings.HasPrefix(got, want) {
+		t.Errorf("got statement %q, want prefix %q", got, want)
+	}
+}
+
+func TestCheckFix(t *testing.T) {
+	pkg := path.Join(testPackagePrefix, failingPrefix, "test9")
+	fset, methods := doTest(t, []string{pkg})
+
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	fixFlag = true
+	defer func() {
+		fixFlag = false
+	}()
+	if err := writeFiles(fake.X, fset, injectPatches(fset, methods)); err != nil {
+		t.Fatal(err)
+	}
+	// The diff is relative to the root of the repository.
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", failingPrefix, "test9.fix"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), string(buf); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	savedContextFlag := useContextFlag
+	defer func() {
+		useContextFlag = savedContextFlag
+	}()
+	useContextFlag = false
+
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	if _, err := configureDefaultBuildConfig(fake.X, []string{"testpackage"}); err != nil {
+		t.Fatal(err)
+	}
+	ifc := path.Join(testPackagePrefix, "iface")
+	pkgs := []string{
+		path.Join(testPackagePrefix, "embedding", "inner"),
+		path.Join(testPackagePrefix, "embedding", "outer"),
+	}
+
+	// inner.Inner.Method1 only implements an interface method when promoted
+	// to outer.Outer.
+	diffOnlyFlag = true
+	if err := runInjector(fake.X, []string{ifc}, pkgs, false); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "embedding.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimRight(stdout.String(), "\n"), strings.TrimRight(string(buf), "\n"); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	savedContextFlag := useContextFlag
+	savedTemplateFlag := templateFlag
+	defer func() {
+		useContextFlag = savedContextFlag
+		templateFlag = savedTemplateFlag
+	}()
+	useContextFlag = false
+	templateFlag = filepath.Join("testdata", "template", "trace.tmpl")
+
+	pkg := path.Join(testPackagePrefix, "template", "passes")
+	if _, methods := doTest(t, []string{pkg}); len(methods) > 0 {
+		t.Errorf("Test package %q failed to pass the template checks: %v", pkg, methods)
+	}
+	pkg = path.Join(testPackagePrefix, "template", "test1")
+	if _, methods := doTest(t, []string{pkg}); len(methods) != 3 {
+		t.Errorf("Test package %q: got %d methods failing the template checks, want 3", pkg, len(methods))
+	}
+	testInject(t, "iface", "template", 1)
+
+	templateFlag = filepath.Join("testdata", "template", "trace_v2.tmpl")
+	testInject(t, "iface", path.Join("template", "names"), 2)
+
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	if _, err := configureDefaultBuildConfig(fake.X, []string{"testpackage"}); err != nil {
+		t.Fatal(err)
+	}
+	diffOnlyFlag = true
+	ifc := path.Join(testPackagePrefix, "iface")
+	if err := runRemover(fake.X, []string{ifc}, []string{path.Join(testPackagePrefix, "template", "passes")}); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "template", "passes.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimRight(stdout.String(), "\n"), strings.TrimRight(string(buf), "\n"); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIncludeTests(t *testing.T) {
+	savedContextFlag := useContextFlag
+	savedIncludeTestsFlag := includeTestsFlag
+	defer func() {
+		useContextFlag = savedContextFlag
+		includeTestsFlag = savedIncludeTestsFlag
+	}()
+	useContextFlag = false
+
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	if _, err := configureDefaultBuildConfig(fake.X, []string{"testpackage"}); err != nil {
+		t.Fatal(err)
+	}
+	ifc := path.Join(testPackagePrefix, "iface")
+	pkg := path.Join(testPackagePrefix, "withtests")
+
+	diffOnlyFlag = true
+	for _, includeTests := range []bool{false, true} {
+		includeTestsFlag = includeTests
+		stdout := bytes.NewBuffer(nil)
+		jirix := fake.X.Clone(tool.ContextOpts{Stdout: stdout})
+		if err := runInjector(jirix, []string{ifc}, []string{pkg}, false); err != nil {
+			t.Fatal(err)
+		}
+		for _, typ := range []string{"InternalType", "ExternalType"} {
+			if got, want := strings.Contains(stdout.String(), typ), includeTests; got != want {
+				t.Errorf("include tests %v: got injection into %s %v, want %v", includeTests, typ, got, want)
+			}
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	if _, err := configureDefaultBuildConfig(fake.X, []string{"testpackage"}); err != nil {
+		t.Fatal(err)
+	}
+	ifc := path.Join(testPackagePrefix, "iface")
+	pkg := path.Join(testPackagePrefix, "typeerror")
+
+	diffOnlyFlag = true
+	jirix := fake.X.Clone(tool.ContextOpts{Stdout: bytes.NewBuffer(nil)})
+	err := runInjector(jirix, []string{ifc}, []string{pkg}, false)
+	if err == nil || !strings.Contains(err.Error(), "undefined: undefined") {
+		t.Errorf("got error %v, want a type error", err)
+	}
+}
+
 func testInject(t *testing.T, iface, prefix string, testPackageCount int) {
 	fake, cleanup := jiritest.NewFakeJiriRoot(t)
 	defer cleanup()
@@ -189,7 +385,7 @@ func testInject(t *testing.T, iface, prefix string, testPackageCount int) {
 		jirix := fake.X.Clone(tool.ContextOpts{Stdout: stdout})
 		testPkg := "test" + strconv.Itoa(i)
 		pkg := path.Join(testPackagePrefix, prefix, testPkg)
-		if err := runInjector(jirix, skipProfiles, []string{ifc}, []string{pkg}, false); err != nil {
+		if err := runInjector(jirix, []string{ifc}, []string{pkg}, false); err != nil {
 			t.Fatal(err)
 		}
 		diffs := []string{}
@@ -250,12 +446,12 @@ func doTest(t *testing.T, packages []string) (*token.FileSet, map[funcDeclRef]er
 	initInjectorFlags()
 	interfaceList := []string{path.Join(testPackagePrefix, "iface")}
 
-	ifcs, err := importPkgs(fake.X, skipProfiles, interfaceList)
+	ifcs, err := importPkgs(fake.X, interfaceList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	impls, err := importPkgs(fake.X, skipProfiles, packages)
+	impls, err := importPkgs(fake.X, packages)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -265,31 +461,44 @@ func doTest(t *testing.T, packages []string) (*token.FileSet, map[funcDeclRef]er
 	}
 
 	ps := newState(fake.X)
-
-	ifc := ifcs[0]
-	_, ifcpkg, err := ps.parseAndTypeCheckPackage(ifc)
-	if err != nil {
+	if err := ps.load(append(ifcs, impls...), false); err != nil {
 		t.Fatal(err)
 	}
 
+	ifcpkg := ps.packages[ifcs[0]].Types
 	interfaces := findPublicInterfaces(fake.X, []*types.Package{ifcpkg})
 	if len(interfaces) == 0 {
 		t.Fatalf("Log injector did not find any interfaces in %s for %s", interfaceList, ifcpkg.Path())
 	}
 
-	impl := impls[0]
-	asts, tpkg, err := ps.parseAndTypeCheckPackage(impl)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	methods := findMethodsImplementing(fake.X, ps.fset, tpkg, interfaces)
+	impl := ps.packages[impls[0]]
+	methods := findMethodsImplementing(fake.X, ps.fset, impl.Types, interfaces)
 	if len(methods) == 0 {
 		t.Fatalf("Log injector could not find any methods implementing the test interfaces in %v", impls)
 	}
-	methodPositions, err := functionDeclarationsAtPositions(ps.fset, asts, ps.info, methods)
+	methodPositions, err := functionDeclarationsAtPositions(ps.fset, ps.sourceFiles(impl), impl.TypesInfo, methods, ps.packageName)
 	if err != nil {
 		t.Fatal(err)
 	}
 	return ps.fset, checkMethods(methodPositions)
 }
+
+func TestRepoRelPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gologcop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := repoRelPath(filepath.Join(repo, "a", "b.go")), filepath.Join("a", "b.go"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// Files outside of git repositories fall back to relPath.
+	other := filepath.Join(dir, "other", "c.go")
+	if got, want := repoRelPath(other), relPath(other); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"v.io/jiri"
+	"v.io/jiri/profiles"
+	"v.io/jiri/profiles/profilesreader"
+)
+
+// The packages to be checked, injected or removed are parsed and type-checked
+// from source, including function bodies, while their dependencies are
+// type-checked from the export data produced by the go tool.  The go tool
+// caches export data across runs, so only the listed packages are type-checked
+// from source on each run.
+const (
+	listMode = packages.NeedName | packages.NeedFiles
+	loadMode = listMode | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+)
+
+// packagesConfig returns the configuration used to load packages via
+// golang.org/x/tools/go/packages, which understands Go modules, go.work
+// workspaces, vendor directories and build tags.  The go tool runs in the
+// environment of the jiri profiles, merged according to -merge-policies.
+func packagesConfig(jirix *jiri.X, mode packages.LoadMode, tests bool) (*packages.Config, error) {
+	rd, err := profilesreader.NewReader(jirix, profilesreader.UseProfiles, jiri.ProfilesDBDir)
+	if err != nil {
+		return nil, err
+	}
+	rd.MergeEnvFromProfiles(mergePoliciesFlag, profiles.NativeTarget(), "jiri")
+	env := os.Environ()
+	for key, value := range rd.ToMap() {
+		env = append(env, key+"="+value)
+	}
+	if build.Default.GOPATH != "" {
+		env = append(env, "GOPATH="+build.Default.GOPATH)
+	}
+	cfg := &packages.Config{
+		Mode:  mode,
+		Env:   env,
+		Tests: tests,
+	}
+	if tags := build.Default.BuildTags; len(tags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(tags, ",")}
+	}
+	return cfg, nil
+}
+
+// importPkgs expands the supplied list of package patterns (so v.io/v23/... can
+// be used as an interface package spec for example) and returns the sorted
+// import paths of the matching packages.
+func importPkgs(jirix *jiri.X, packageSpec []string) ([]string, error) {
+	cfg, err := packagesConfig(jirix, listMode, false)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := packages.Load(cfg, packageSpec...)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadErrors(pkgs); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, pkg := range pkgs {
+		paths = append(paths, pkg.PkgPath)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// loadErrors returns an error describing all package errors in pkgs, or nil if
+// there were no errors.  Type errors are fatal, since methods that can't be
+// type-checked can't be checked for, or injected with, logging constructs.  The
+// go tool also reports type errors when compiling export data, as errors
+// starting with "# <package>"; these are ignored for packages that are
+// type-checked from source, since their type errors are reported directly.
+func loadErrors(pkgs []*packages.Package) error {
+	var msgs []string
+	seen := make(map[string]bool)
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			if len(p.Syntax) > 0 && (strings.HasPrefix(err.Msg, "# "+p.PkgPath+"\n") || strings.HasPrefix(err.Msg, "# "+p.PkgPath+" [")) {
+				continue
+			}
+			if msg := err.Error(); !seen[msg] {
+				seen[msg] = true
+				msgs = append(msgs, msg)
+			}
+		}
+	})
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// load parses and type-checks the packages with the given import paths.  All
+// packages are loaded together, so that the types of interface packages are
+// shared with the implementation packages that import them.  If tests is true,
+// the packages are also loaded with their test files, i.e. the package
+// augmented with its _test.go files and the *_test package.
+func (ps *parseState) load(paths []string, tests bool) error {
+	progressMsg(ps.jirix.Stdout(), "loading: %s\n", strings.Join(paths, " "))
+	cfg, err := packagesConfig(ps.jirix, loadMode, tests)
+	if err != nil {
+		return err
+	}
+	cfg.Fset = ps.fset
+	pkgs, err := packages.Load(cfg, paths...)
+	if err != nil {
+		return err
+	}
+	if err := loadErrors(pkgs); err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		switch {
+		case pkg.ID == pkg.PkgPath:
+			ps.packages[pkg.PkgPath] = pkg
+		case pkg.ID == pkg.PkgPath+" ["+strings.TrimSuffix(pkg.PkgPath, "_test")+".test]":
+			ps.testPackages[pkg.PkgPath] = pkg
+		}
+	}
+	return nil
+}
+
+// implPackages returns the loaded packages for the given implementation
+// package path.  If the package was loaded with its test files, the package
+// augmented with its _test.go files is returned instead of the package, along
+// with the *_test package if there is one.
+func (ps *parseState) implPackages(path string) []*packages.Package {
+	pkg := ps.testPackages[path]
+	if pkg == nil {
+		pkg = ps.packages[path]
+	}
+	pkgs := []*packages.Package{pkg}
+	if xtest := ps.testPackages[path+"_test"]; xtest != nil {
+		pkgs = append(pkgs, xtest)
+	}
+	return pkgs
+}
+
+// packageName returns the name of the package with the given import path.  The
+// name is looked up in the loaded packages and their dependencies, and the
+// package is only loaded if it isn't one of them.
+func (ps *parseState) packageName(importPath string) (string, error) {
+	if name, ok := ps.names[importPath]; ok {
+		return name, nil
+	}
+	var loaded []*packages.Package
+	for _, pkg := range ps.packages {
+		loaded = append(loaded, pkg)
+	}
+	for _, pkg := range ps.testPackages {
+		loaded = append(loaded, pkg)
+	}
+	packages.Visit(loaded, nil, func(p *packages.Package) {
+		if p.Name != "" {
+			ps.names[p.PkgPath] = p.Name
+		}
+	})
+	if name, ok := ps.names[importPath]; ok {
+		return name, nil
+	}
+	cfg, err := packagesConfig(ps.jirix, packages.NeedName, false)
+	if err != nil {
+		return "", err
+	}
+	pkgs, err := packages.Load(cfg, importPath)
+	if err != nil {
+		return "", err
+	}
+	if err := loadErrors(pkgs); err != nil {
+		return "", err
+	}
+	if len(pkgs) != 1 || pkgs[0].Name == "" {
+		return "", fmt.Errorf("failed to find package %q", importPath)
+	}
+	ps.names[importPath] = pkgs[0].Name
+	return pkgs[0].Name, nil
+}
+
+// sourceFiles returns the syntax trees of the Go source files of pkg.  Files
+// generated by the go tool, e.g. from cgo files, are skipped since they can't
+// be modified.
+func (ps *parseState) sourceFiles(pkg *packages.Package) []*ast.File {
+	goFiles := make(map[string]bool)
+	for _, file := range pkg.GoFiles {
+		goFiles[file] = true
+	}
+	var files []*ast.File
+	for _, file := range pkg.Syntax {
+		filename := ps.fset.PositionFor(file.Pos(), false).Filename
+		if goFiles[filename] {
+			files = append(files, file)
+		} else {
+			progressMsg(ps.jirix.Stdout(), "skipping generated file: %s\n", filename)
+		}
+	}
+	return files
+}
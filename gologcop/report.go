@@ -0,0 +1,141 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// jsonResult is the JSON representation of a method that fails the check.
+type jsonResult struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Function  string `json:"function"`
+	Interface string `json:"interface,omitempty"`
+	Statement string `json:"statement"`
+	Message   string `json:"message"`
+}
+
+func newJSONResult(fset *token.FileSet, m funcDeclRef, err error) jsonResult {
+	pos := fset.Position(m.Decl.Pos())
+	return jsonResult{
+		File:      relPath(pos.Filename),
+		Line:      pos.Line,
+		Function:  functionName(m.Decl),
+		Interface: m.InterfaceMethod(),
+		Statement: strings.TrimSpace(m.LogCall),
+		Message:   err.Error(),
+	}
+}
+
+// functionName returns the name of the function declared by decl, qualified
+// by its receiver type if it is a method, e.g. "(*T).Method".
+func functionName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		return "(*" + types.ExprString(star.X) + ")." + decl.Name.Name
+	}
+	return types.ExprString(recv) + "." + decl.Name.Name
+}
+
+// relPath returns filename relative to the current directory if it is below
+// it, or filename otherwise.
+func relPath(filename string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return filename
+	}
+	rel, err := filepath.Rel(wd, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filename
+	}
+	return rel
+}
+
+// repoRelPath returns filename relative to the root of the git repository
+// containing it, i.e. the closest directory above it that contains a .git
+// entry.  If filename isn't in a git repository, it returns relPath(filename).
+func repoRelPath(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return relPath(filename)
+	}
+	dir := filepath.Dir(abs)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				return rel
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return relPath(filename)
+}
+
+// printResults prints the methods that fail the check to w in the given
+// format, ordered by position.
+func printResults(w io.Writer, format string, fset *token.FileSet, methods map[funcDeclRef]error) error {
+	sorted := []funcDeclRef{}
+	for m := range methods {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		pi, pj := fset.Position(sorted[i].Decl.Pos()), fset.Position(sorted[j].Decl.Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	switch format {
+	case formatText:
+		for _, m := range sorted {
+			printResult(w, fset, m, methods[m])
+		}
+		return nil
+	case formatJSON:
+		results := []jsonResult{}
+		for _, m := range sorted {
+			results = append(results, newJSONResult(fset, m, methods[m]))
+		}
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// printResult prints a method that fails the check in a human-readable form.
+func printResult(w io.Writer, fset *token.FileSet, m funcDeclRef, err error) {
+	if name := m.InterfaceMethod(); name != "" {
+		fmt.Fprintf(w, "%v: %s (implements %s): %v\n", fset.Position(m.Decl.Pos()), m.Decl.Name.Name, name, err)
+		return
+	}
+	fmt.Fprintf(w, "%v: %s: %v\n", fset.Position(m.Decl.Pos()), m.Decl.Name.Name, err)
+}
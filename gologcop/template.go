@@ -0,0 +1,209 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// injectTemplate is the template for the statements to be injected, checked
+// for or removed, if one was specified via the -template flag.
+var injectTemplate *template.Template
+
+// templateData is the data that injection templates are executed with.
+type templateData struct {
+	// Package is the name of the package declaring the method.
+	Package string
+	// Method is the name of the method.
+	Method string
+	// Receiver is the receiver type of the method, e.g. "*T", or "" for
+	// functions.
+	Receiver string
+	// Interface is the interface that the method implements, qualified by its
+	// package name, e.g. "iface.T", or "" if unknown.
+	Interface string
+	// Params holds the names of the named parameters, excluding "_".
+	Params []string
+	// Results holds the names of the named results, excluding "_".
+	Results []string
+	// Context is the name of the v.io/v23/context.T parameter, or "" if there
+	// is none or the -use-v23-context flag is false.
+	Context string
+
+	info    *types.Info
+	file    *ast.File
+	pkgName func(importPath string) (string, error)
+	imports []templateImport
+}
+
+// templateImport is an import declaration needed by the injected statements.
+// Name is the name to import the package as, or "" if it is the last element
+// of Path.
+type templateImport struct {
+	Path, Name string
+}
+
+// Import records that the injected statements use the package with the given
+// import path and returns the name to refer to it by.  If the file already
+// imports the package, that is the name it is imported as; otherwise it is the
+// name declared by the package, and the import declaration is added to the
+// file, with that name if it isn't the last element of the path.
+func (d *templateData) Import(importPath string) (string, error) {
+	for _, spec := range d.file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if spec.Name != nil {
+			if name := spec.Name.Name; name != "_" && name != "." {
+				return name, nil
+			}
+			continue
+		}
+		if d.info != nil {
+			if obj, ok := d.info.Implicits[spec].(*types.PkgName); ok {
+				return obj.Name(), nil
+			}
+		}
+	}
+	name, err := d.pkgName(importPath)
+	if err != nil {
+		return "", err
+	}
+	imp := templateImport{Path: importPath}
+	if name != path.Base(importPath) {
+		imp.Name = name
+	}
+	d.imports = append(d.imports, imp)
+	return name, nil
+}
+
+// templateFuncs are the functions available to injection templates, in
+// addition to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// initTemplate parses the template file, if any.
+func initTemplate(filename string) error {
+	injectTemplate = nil
+	if filename == "" {
+		return nil
+	}
+	tmpl, err := template.New(filepath.Base(filename)).Funcs(templateFuncs).ParseFiles(filename)
+	if err != nil {
+		return err
+	}
+	injectTemplate = tmpl
+	return nil
+}
+
+// newTemplateData returns the template data for decl, which is declared in
+// file and implements a method of ifc, if ifc isn't nil.  pkgName returns the
+// name of the package with the given import path.
+func newTemplateData(info *types.Info, file *ast.File, decl *ast.FuncDecl, ifc *types.Named, pkgName func(string) (string, error)) *templateData {
+	names := func(fields *ast.FieldList) []string {
+		var result []string
+		if fields == nil {
+			return result
+		}
+		for _, field := range fields.List {
+			for _, n := range field.Names {
+				if n.Name != "_" {
+					result = append(result, n.Name)
+				}
+			}
+		}
+		return result
+	}
+	data := &templateData{
+		Package: file.Name.Name,
+		Method:  decl.Name.Name,
+		Params:  names(decl.Type.Params),
+		Results: names(decl.Type.Results),
+		info:    info,
+		file:    file,
+		pkgName: pkgName,
+	}
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		data.Receiver = types.ExprString(decl.Recv.List[0].Type)
+	}
+	if ifc != nil {
+		data.Interface = ifc.Obj().Pkg().Name() + "." + ifc.Obj().Name()
+	}
+	if info != nil {
+		if _, context := hasV23Context(info, decl.Type.Params); context != "nil" {
+			data.Context = context
+		}
+	}
+	return data
+}
+
+// genTemplateCall executes the injection template for decl, returning the
+// text to be injected and the imports of the packages it uses.
+func genTemplateCall(info *types.Info, file *ast.File, decl *ast.FuncDecl, ifc *types.Named, pkgName func(string) (string, error)) (string, []templateImport, error) {
+	data := newTemplateData(info, file, decl, ifc, pkgName)
+	var buf bytes.Buffer
+	if err := injectTemplate.Execute(&buf, data); err != nil {
+		return "", nil, err
+	}
+	text := strings.TrimSpace(buf.String())
+	stmts, err := parseStatements(text)
+	if err != nil {
+		return "", nil, fmt.Errorf("template %s: %v", injectTemplate.Name(), err)
+	}
+	if len(stmts) == 0 {
+		return "", nil, fmt.Errorf("template %s: no statements", injectTemplate.Name())
+	}
+	return "\n\t" + strings.Replace(text, "\n", "\n\t", -1) + " " + logCallComment, data.imports, nil
+}
+
+// parseStatements parses text as a list of statements.
+func parseStatements(text string) ([]ast.Stmt, error) {
+	src := "package p\nfunc _() {\n" + text + "\n}\n"
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	return file.Decls[0].(*ast.FuncDecl).Body.List, nil
+}
+
+// formatStatement returns the canonical formatting of stmt, ignoring comments
+// and the original line breaks.
+func formatStatement(stmt ast.Stmt) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), stmt)
+	return buf.String()
+}
+
+// matchTemplate returns the number of statements generated by the injection
+// template for method, or an error if method does not begin with those
+// statements.
+func matchTemplate(method funcDeclRef) (int, error) {
+	want, err := parseStatements(method.LogCall)
+	if err != nil {
+		return 0, err
+	}
+	got := method.Decl.Body.List
+	if len(got) == 0 {
+		return 0, &errNotExists{"empty method"}
+	}
+	for i, stmt := range want {
+		if i >= len(got) || formatStatement(got[i]) != formatStatement(stmt) {
+			return 0, &errNotExists{fmt.Sprintf("statement %d does not match template %s", i+1, injectTemplate.Name())}
+		}
+	}
+	return len(want), nil
+}
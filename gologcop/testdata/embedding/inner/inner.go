@@ -0,0 +1,11 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// inner declares a type that only implements the test interfaces when
+// embedded in outer.Outer.
+package inner
+
+type Inner struct{}
+
+func (Inner) Method1() {}
@@ -0,0 +1,20 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// outer declares a type that implements the test interfaces via a method
+// promoted from inner.Inner.
+package outer
+
+import (
+	"v.io/x/devtools/gologcop/testdata/embedding/inner"
+	"v.io/x/ref/lib/apilog"
+)
+
+type Outer struct {
+	inner.Inner
+}
+
+func (*Outer) Method2(int) {
+	defer apilog.LogCall()() // gologcop: DO NOT EDIT, MUST BE FIRST STATEMENT
+}
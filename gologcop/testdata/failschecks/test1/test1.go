@@ -15,7 +15,7 @@ type Type1 struct{}
 
 func (Type1) Method1() {
 	fmt.Println("test")
-	defer vlog.LogCall()()
+	defer apilog.LogCall()()
 }
 func (Type1) Method2(int) {
 	//nologcall
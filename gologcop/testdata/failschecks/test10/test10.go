@@ -0,0 +1,20 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// test10 fails the log check because the methods of the generic
+// types are not logged.
+package test10
+
+type GenericType[T any] struct{}
+
+func (GenericType[T]) Method1()       {}
+func (*GenericType[T]) Method2(a int) {}
+
+type Container[T any] struct {
+	items []T
+}
+
+func (c *Container[T]) Put(v T) {
+	c.items = append(c.items, v)
+}
@@ -17,5 +17,5 @@ type Type1 struct{}
 
 func (Type1) Method1() {
 	fmt.Println("test")
-	defer vlog.LogCall()()
+	defer apilog.LogCall()()
 }
@@ -0,0 +1,18 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// test9 fails the log check because the method with a pointer
+// receiver is not logged, even though the type also has a logged
+// method with a value receiver.
+package test9
+
+import "v.io/x/ref/lib/apilog"
+
+type MixedType struct{}
+
+func (MixedType) Method1() {
+	defer apilog.LogCall()() // gologcop: DO NOT EDIT, MUST BE FIRST STATEMENT
+}
+
+func (*MixedType) Method2(int) {}
@@ -17,3 +17,7 @@ type unexportedInterface interface {
 type ReturnsValueInterface interface {
 	ReturnsSomething(a int) int
 }
+
+type Putter[T any] interface {
+	Put(v T)
+}
@@ -57,7 +57,7 @@ func (obj LogCallfTest) AnotherTestForRemove(a int) (b int) {
 	// this comment should make no difference
 	defer apilog.LogCallf("switch test")("") // gologcop: DO NOT EDIT, MUST BE FIRST STATEMENT
 	switch {
-	case 4:
+	case a == 4:
 		// Another comment
 	}
 	return 42
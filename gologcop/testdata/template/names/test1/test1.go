@@ -0,0 +1,15 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// test1 doesn't import the trace package, whose name isn't the last element
+// of its import path.
+package test1
+
+type Type1 struct{}
+
+func (Type1) Method1() {}
+
+func (Type1) Method2(a int) {
+	_ = a
+}
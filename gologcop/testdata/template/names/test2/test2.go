@@ -0,0 +1,18 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// test2 already imports the trace package under another name.
+package test2
+
+import tr "v.io/x/devtools/gologcop/testdata/template/trace/v2"
+
+type Type1 struct{}
+
+func (Type1) Method1() {
+	tr.Count("unrelated")
+}
+
+func (Type1) Method2(a int) {
+	_ = a
+}
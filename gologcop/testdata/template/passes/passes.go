@@ -0,0 +1,29 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// passes should pass the template checks, as it includes the templated
+// statements.
+package passes
+
+import "v.io/x/devtools/gologcop/testdata/template/trace"
+
+type Type1 struct{}
+
+func (Type1) Method1() {
+	trace.Count("iface.Interface1.Method1")
+	defer trace.Span("Type1.Method1")()
+}
+
+func (Type1) Method2(a int) {
+	trace.Count("iface.Interface1.Method2") // random comment
+	defer trace.Span("Type1.Method2", a)()  // gologcop: DO NOT EDIT, MUST BE FIRST STATEMENT
+	_ = a
+}
+
+type ReturnsValue struct{}
+
+func (r ReturnsValue) ReturnsSomething(a int) int {
+	//nologcall
+	return a
+}
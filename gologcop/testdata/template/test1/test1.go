@@ -0,0 +1,23 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// test1 has no templated statements, and an out of date one.
+package test1
+
+import "v.io/x/devtools/gologcop/testdata/template/trace"
+
+type Type1 struct{}
+
+func (Type1) Method1() {}
+
+func (Type1) Method2(a int) {
+	_ = a
+}
+
+type ReturnsValue struct{}
+
+func (r ReturnsValue) ReturnsSomething(a int) int {
+	defer trace.Span("ReturnsValue.ReturnsSomething")()
+	return a
+}
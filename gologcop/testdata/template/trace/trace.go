@@ -0,0 +1,15 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// trace is used by the statements injected by the test template.
+package trace
+
+// Count increments the counter with the given name.
+func Count(name string) {}
+
+// Span starts a span with the given name and arguments, and returns a
+// function that ends it.
+func Span(name string, args ...interface{}) func() {
+	return func() {}
+}
@@ -0,0 +1,14 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// typeerror can't be checked or injected, because Method1 has a type error.
+package typeerror
+
+type Type1 struct{}
+
+func (Type1) Method1() {
+	undefined()
+}
+
+func (Type1) Method2(int) {}
@@ -30,4 +30,5 @@ func (Type1) Method6(a int, b ...interface{}) {
 }
 
 func (Type1) Method7(a map[string]struct{}, e []int) (m map[bool]struct{}, err error) {
+	return
 }
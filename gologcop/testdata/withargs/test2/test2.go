@@ -14,6 +14,7 @@ import "v.io/x/devtools/gologcop/testdata/iface2"
 type Type2 struct{}
 
 func (Type2) ReturnsSomething(a int) int {
+	return a
 }
 
 var _ iface2.ReturnsValueInterface = Type2{}
@@ -0,0 +1,10 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package withtests
+
+type InternalType struct{}
+
+func (InternalType) Method1()      {}
+func (InternalType) Method2(a int) {}
@@ -0,0 +1,255 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package history contains types and functions for recording the
+// results of test runs in a local history file and summarizing the
+// history of tests, e.g. to triage flaky tests offline.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/runutil"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+)
+
+// Record records the outcome of a jiri test, or of one of the test
+// cases of its xUnit report, in a test run.
+type Record struct {
+	// Time is when the test run started.
+	Time time.Time `json:"time"`
+	// Test is the name of the jiri test.
+	Test string `json:"test"`
+	// Package and Case identify the test case. They are empty in the
+	// record of the jiri test as a whole.
+	Package string `json:"package,omitempty"`
+	Case    string `json:"case,omitempty"`
+	// Status is the outcome of the test.
+	Status test.Status `json:"status"`
+	// Duration is how long the test took.
+	Duration time.Duration `json:"duration"`
+	// Snapshot identifies the revisions of the projects the test ran
+	// against. It ends with DirtySuffix if any of the projects had
+	// uncommitted or untracked changes.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Host is the name of the machine the test ran on.
+	Host string `json:"host,omitempty"`
+}
+
+// DirtySuffix is the suffix of the snapshots of projects with
+// uncommitted or untracked changes. Such snapshots don't identify the
+// source code that a test ran against, since the changes may differ
+// between runs.
+const DirtySuffix = "+dirty"
+
+// Name returns the name of the test the record is about, i.e. the
+// name of the jiri test or "<package>.<case>" for test cases.
+func (r Record) Name() string {
+	if r.Case == "" {
+		return r.Test
+	}
+	return r.Package + "." + r.Case
+}
+
+// NewRecords returns the given record of a run of a jiri test,
+// followed by the records of the test cases of the given xUnit test
+// suites of the run.
+func NewRecords(run Record, suites []xunit.TestSuite) []Record {
+	records := []Record{run}
+	for _, s := range suites {
+		if s.Name == "NO_TESTS" {
+			continue
+		}
+		for _, c := range s.Cases {
+			r := run
+			r.Package, r.Case = c.Classname, c.Name
+			if r.Package == "" {
+				r.Package = s.Name
+			}
+			// Strip the suffix of the test case name, e.g. "[linux,amd64]",
+			// so that test cases can be looked up by "<package>.<case>".
+			if i := strings.Index(r.Case, " ["); i >= 0 {
+				r.Case = r.Case[:i]
+			}
+			switch {
+			case len(c.Failures) > 0 || len(c.Errors) > 0:
+				r.Status = test.Failed
+			case len(c.Skipped) > 0:
+				r.Status = test.Skipped
+			case len(c.FlakyFailures) > 0:
+				r.Status = test.PassedOnRetry
+			default:
+				r.Status = test.Passed
+			}
+			r.Duration = 0
+			if seconds, err := strconv.ParseFloat(c.Time, 64); err == nil {
+				r.Duration = time.Duration(seconds * float64(time.Second))
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// Append appends the given records to the history file at the given
+// path. The records are written in a single write to a file opened in
+// append mode, so that concurrent test runs don't interleave their
+// records.
+func Append(jirix *jiri.X, path string, records []Record) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("Encode(%v) failed: %v", r, err)
+		}
+	}
+	if err := jirix.NewSeq().MkdirAll(filepath.Dir(path), os.FileMode(0755)).Done(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.FileMode(0644))
+	if err != nil {
+		return fmt.Errorf("OpenFile(%v) failed: %v", path, err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("Write(%v) failed: %v", path, err)
+	}
+	return file.Close()
+}
+
+// Load returns the records of the history file at the given path,
+// ordered by time. A missing history file has no records.
+func Load(jirix *jiri.X, path string) ([]Record, error) {
+	data, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	records := []Record{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var r Record
+		if err := decoder.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Decode(%v) failed: %v", path, err)
+		}
+		records = append(records, r)
+	}
+	sort.Stable(byTime(records))
+	return records, nil
+}
+
+type byTime []Record
+
+func (r byTime) Len() int           { return len(r) }
+func (r byTime) Less(i, j int) bool { return r[i].Time.Before(r[j].Time) }
+func (r byTime) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// Select returns the records of the test with the given name, as
+// returned by Record.Name.
+func Select(records []Record, name string) []Record {
+	selected := []Record{}
+	for _, r := range records {
+		if r.Name() == name {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// Summary summarizes the runs of a test.
+type Summary struct {
+	// Runs is the number of runs that passed or failed. Skipped runs
+	// are ignored.
+	Runs int
+	// Passed is the number of runs that passed, including the runs
+	// that passed on retry.
+	Passed int
+	// Flaky is the number of runs that either passed on retry, or had
+	// a different outcome than the previous run of the same jiri test
+	// at the same snapshot. Runs at dirty snapshots are not compared.
+	Flaky int
+	// OlderDuration and RecentDuration are the mean durations of the
+	// older and the more recent half of the runs.
+	OlderDuration, RecentDuration time.Duration
+}
+
+// Summarize summarizes the given records of a test, ordered by time.
+func Summarize(records []Record) Summary {
+	var s Summary
+	var durations []time.Duration
+	// The outcomes of the last runs, keyed by the jiri test and the
+	// snapshot, since a test case may be run by several jiri tests, e.g.
+	// with and without the race detector.
+	type runKey struct{ test, snapshot string }
+	lastPassed := map[runKey]bool{}
+	for _, r := range records {
+		if r.Status == test.Pending || r.Status == test.Skipped {
+			continue
+		}
+		s.Runs++
+		passed := r.Status.Succeeded()
+		if passed {
+			s.Passed++
+		}
+		flaky := r.Status == test.PassedOnRetry
+		if r.Snapshot != "" && !strings.HasSuffix(r.Snapshot, DirtySuffix) {
+			key := runKey{r.Test, r.Snapshot}
+			if last, ok := lastPassed[key]; ok && last != passed {
+				flaky = true
+			}
+			lastPassed[key] = passed
+		}
+		if flaky {
+			s.Flaky++
+		}
+		durations = append(durations, r.Duration)
+	}
+	half := len(durations) / 2
+	s.OlderDuration = mean(durations[:half])
+	s.RecentDuration = mean(durations[half:])
+	return s
+}
+
+// PassRate returns the fraction of the runs that passed.
+func (s Summary) PassRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Passed) / float64(s.Runs)
+}
+
+// FlakinessScore returns the fraction of the runs that were flaky,
+// from 0 for a test with consistent outcomes to 1.
+func (s Summary) FlakinessScore() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Flaky) / float64(s.Runs)
+}
+
+func mean(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return sum / time.Duration(len(durations))
+}
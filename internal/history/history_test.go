@@ -0,0 +1,161 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package history_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/jiri/jiritest"
+	"v.io/x/devtools/internal/history"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+)
+
+func TestNewRecords(t *testing.T) {
+	start := time.Date(2015, time.December, 31, 12, 0, 0, 0, time.UTC)
+	run := history.Record{
+		Time:     start,
+		Test:     "vanadium-go-test",
+		Status:   test.Failed,
+		Duration: time.Minute,
+		Snapshot: "abc",
+		Host:     "host",
+	}
+	suites := []xunit.TestSuite{
+		xunit.TestSuite{
+			Name: "v.io/x/foo",
+			Cases: []xunit.TestCase{
+				xunit.TestCase{Classname: "v.io/x/foo", Name: "TestPass [linux,amd64]", Time: "1.50"},
+				xunit.TestCase{Classname: "v.io/x/foo", Name: "TestFail", Failures: []xunit.Failure{{Message: "error"}}},
+				xunit.TestCase{Classname: "v.io/x/foo", Name: "TestSkip", Skipped: []string{""}},
+				xunit.TestCase{Classname: "v.io/x/foo", Name: "TestFlaky", FlakyFailures: []xunit.Failure{{Message: "error"}}},
+			},
+		},
+		xunit.TestSuite{
+			Name:  "NO_TESTS",
+			Cases: []xunit.TestCase{xunit.TestCase{Classname: "NO_TESTS", Name: "NO_TESTS"}},
+		},
+	}
+	record := func(name string, status test.Status, duration time.Duration) history.Record {
+		r := run
+		r.Package, r.Case, r.Status, r.Duration = "v.io/x/foo", name, status, duration
+		return r
+	}
+	want := []history.Record{
+		run,
+		record("TestPass", test.Passed, 1500*time.Millisecond),
+		record("TestFail", test.Failed, 0),
+		record("TestSkip", test.Skipped, 0),
+		record("TestFlaky", test.PassedOnRetry, 0),
+	}
+	got := history.NewRecords(run, suites)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	if got, want := got[1].Name(), "v.io/x/foo.TestPass"; got != want {
+		t.Fatalf("unexpected name: got %v, want %v", got, want)
+	}
+}
+
+func TestAppendLoad(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	path := filepath.Join(fake.X.Root, "history", "test_history.v1.json")
+	records, err := history.Load(fake.X, path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("unexpected records in a missing history file: %v", records)
+	}
+	start := time.Date(2015, time.December, 31, 12, 0, 0, 0, time.UTC)
+	r1 := history.Record{Time: start.Add(time.Hour), Test: "vanadium-go-test", Status: test.Passed, Duration: time.Second}
+	r2 := history.Record{Time: start, Test: "vanadium-go-test", Package: "v.io/x/foo", Case: "TestFoo", Status: test.Failed}
+	if err := history.Append(fake.X, path, []history.Record{r1}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := history.Append(fake.X, path, []history.Record{r2}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	got, err := history.Load(fake.X, path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// The records are ordered by time.
+	if want := []history.Record{r2, r1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2015, time.December, 31, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		statuses  []test.Status
+		snapshots []string
+		tests     []string
+		want      history.Summary
+	}{
+		{
+			statuses:  nil,
+			snapshots: nil,
+			want:      history.Summary{},
+		},
+		{
+			// A test that broke and got fixed isn't flaky.
+			statuses:  []test.Status{test.Passed, test.Failed, test.Failed, test.Passed},
+			snapshots: []string{"a", "b", "b", "c"},
+			want:      history.Summary{Runs: 4, Passed: 2, Flaky: 0, OlderDuration: 1500 * time.Millisecond, RecentDuration: 3500 * time.Millisecond},
+		},
+		{
+			// A test that changes outcome at the same snapshot is flaky.
+			statuses:  []test.Status{test.Passed, test.Failed, test.Passed, test.Skipped},
+			snapshots: []string{"a", "a", "b", "b"},
+			want:      history.Summary{Runs: 3, Passed: 2, Flaky: 1, OlderDuration: 1 * time.Second, RecentDuration: 2500 * time.Millisecond},
+		},
+		{
+			// A test case that has different outcomes in different jiri
+			// tests at the same snapshot isn't flaky.
+			statuses:  []test.Status{test.Passed, test.Failed},
+			snapshots: []string{"a", "a"},
+			tests:     []string{"vanadium-go-test", "vanadium-go-race"},
+			want:      history.Summary{Runs: 2, Passed: 1, Flaky: 0, OlderDuration: 1 * time.Second, RecentDuration: 2 * time.Second},
+		},
+		{
+			// Runs at dirty snapshots aren't compared.
+			statuses:  []test.Status{test.Passed, test.Failed},
+			snapshots: []string{"a" + history.DirtySuffix, "a" + history.DirtySuffix},
+			want:      history.Summary{Runs: 2, Passed: 1, Flaky: 0, OlderDuration: 1 * time.Second, RecentDuration: 2 * time.Second},
+		},
+		{
+			// A test that passes on retry is flaky.
+			statuses:  []test.Status{test.Passed, test.PassedOnRetry},
+			snapshots: []string{"", ""},
+			want:      history.Summary{Runs: 2, Passed: 2, Flaky: 1, OlderDuration: 1 * time.Second, RecentDuration: 2 * time.Second},
+		},
+	}
+	for _, testCase := range testCases {
+		records := []history.Record{}
+		for i, status := range testCase.statuses {
+			testName := "vanadium-go-test"
+			if testCase.tests != nil {
+				testName = testCase.tests[i]
+			}
+			records = append(records, history.Record{
+				Time:     start.Add(time.Duration(i) * time.Hour),
+				Test:     testName,
+				Status:   status,
+				Duration: time.Duration(i+1) * time.Second,
+				Snapshot: testCase.snapshots[i],
+			})
+		}
+		if got, want := history.Summarize(records), testCase.want; got != want {
+			t.Errorf("Summarize(%v, %v): got %#v, want %#v", testCase.statuses, testCase.snapshots, got, want)
+		}
+	}
+}
@@ -26,8 +26,10 @@ type Result struct {
 	TimeoutValue         time.Duration       // Used when Status == TimedOut
 	MergeConflictCL      string              // Used when Status == MergeConflict
 	ToolsBuildFailureMsg string              // Used when Status == ToolsBuildFailure
+	FailedDependency     string              // Used when Status == Skipped
 	ExcludedTests        map[string][]string // Tests that are excluded within packages keyed by package name
 	SkippedTests         map[string][]string // Tests that are skipped within packages keyed by package name
+	FlakyTests           map[string][]string // Tests that passed on retry within packages keyed by package name
 }
 
 const (
@@ -38,6 +40,7 @@ const (
 	MergeConflict
 	ToolsBuildFailure
 	TimedOut
+	PassedOnRetry
 )
 
 func (s Status) String() string {
@@ -52,11 +55,19 @@ func (s Status) String() string {
 		return "MERGE CONFLICT"
 	case TimedOut:
 		return "TIMED OUT"
+	case PassedOnRetry:
+		return "PASSED ON RETRY"
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// Succeeded checks whether the status indicates that the test passed,
+// possibly after rerunning its flaky test functions.
+func (s Status) Succeeded() bool {
+	return s == Passed || s == PassedOnRetry
+}
+
 func Pass(ctx *tool.Context, format string, a ...interface{}) {
 	strOK := "ok"
 	if ctx.Color() {
@@ -0,0 +1,367 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// testResultRE matches the lines that report the results of tests,
+	// e.g. "--- PASS: TestFoo (0.01s)".
+	testResultRE = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+)s\)`)
+	// pkgResultRE matches the lines that report the results of packages,
+	// e.g. "ok  	v.io/x/foo	0.01s".
+	pkgResultRE = regexp.MustCompile(`^(ok|FAIL|\?)\s+(\S+)(\s|$)`)
+)
+
+// goTestEvent is an event emitted by "go test -json", as documented by
+// "go doc cmd/test2json".
+type goTestEvent struct {
+	Action      string
+	Package     string
+	Test        string
+	Elapsed     float64
+	Output      string
+	ImportPath  string
+	FailedBuild string
+}
+
+// goTestCase collects the results of a test or subtest.
+type goTestCase struct {
+	name string
+	// result is "pass", "fail" or "skip", or "" if the test didn't finish,
+	// e.g. because it timed out.
+	result  string
+	elapsed float64
+	output  bytes.Buffer
+}
+
+// goTestPackage collects the results of the tests of a package.
+type goTestPackage struct {
+	name        string
+	result      string
+	buildFailed bool
+	output      bytes.Buffer
+	cases       []*goTestCase
+	casesByName map[string]*goTestCase
+}
+
+func (p *goTestPackage) testCase(name string) *goTestCase {
+	c, ok := p.casesByName[name]
+	if !ok {
+		c = &goTestCase{name: name}
+		p.cases = append(p.cases, c)
+		p.casesByName[name] = c
+	}
+	return c
+}
+
+// goTestParser parses the output of "go test".
+type goTestParser struct {
+	pkgs       []*goTestPackage
+	pkgsByName map[string]*goTestPackage
+	// output collects the output that can't be attributed to a package.
+	output bytes.Buffer
+}
+
+func newGoTestPackage(name string) *goTestPackage {
+	return &goTestPackage{name: name, casesByName: map[string]*goTestCase{}}
+}
+
+func (p *goTestParser) pkg(name string) *goTestPackage {
+	pkg, ok := p.pkgsByName[name]
+	if !ok {
+		pkg = newGoTestPackage(name)
+		p.pkgs = append(p.pkgs, pkg)
+		p.pkgsByName[name] = pkg
+	}
+	return pkg
+}
+
+// TestSuitesFromGoTestOutput reads data from the given input, assuming
+// it contains test results generated by "go test -json" or, failing
+// that, by "go test -v", and returns it as an in-memory data
+// structure, with one test suite per package. Each test and subtest
+// is reported as a test case, along with its output.
+func TestSuitesFromGoTestOutput(testOutput io.Reader) ([]*TestSuite, error) {
+	lines, err := readLines(testOutput)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*goTestEvent, len(lines))
+	isJSON := false
+	for i, line := range lines {
+		if events[i] = parseGoTestEvent(line); events[i] != nil {
+			isJSON = true
+		}
+	}
+	p := &goTestParser{pkgsByName: map[string]*goTestPackage{}}
+	if isJSON {
+		// Lines that aren't events, e.g. build errors, are attributed to
+		// the package named by the last "# <package>" line.
+		var pkg *goTestPackage
+		for i, line := range lines {
+			switch {
+			case events[i] != nil:
+				p.handleEvent(events[i])
+			case strings.HasPrefix(line, "# ") && len(strings.Fields(line)) > 1:
+				pkg = p.pkg(strings.Fields(line)[1])
+				pkg.output.WriteString(line)
+			case pkg != nil:
+				pkg.output.WriteString(line)
+			default:
+				p.output.WriteString(line)
+			}
+		}
+	} else {
+		p.handleText(lines)
+	}
+	return p.suites(), nil
+}
+
+// TextFromGoTestOutput returns the text output by "go test" without the
+// -json flag, given its output with the flag. Lines that aren't events,
+// e.g. build errors, are kept as is.
+func TextFromGoTestOutput(testOutput string) string {
+	lines, _ := readLines(strings.NewReader(testOutput))
+	var text bytes.Buffer
+	for _, line := range lines {
+		if event := parseGoTestEvent(line); event != nil {
+			text.WriteString(event.Output)
+		} else {
+			text.WriteString(line)
+		}
+	}
+	return text.String()
+}
+
+// readLines reads the lines of the given input, including their line
+// terminators. Unlike bufio.Scanner, it doesn't limit the length of lines.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseGoTestEvent returns the event encoded by the given line of the
+// output of "go test -json", or nil if the line isn't an event.
+func parseGoTestEvent(line string) *goTestEvent {
+	if !strings.HasPrefix(line, "{") {
+		return nil
+	}
+	var event goTestEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Action == "" {
+		return nil
+	}
+	return &event
+}
+
+// isFramingLine checks whether the given line of test output only
+// reports that a test started or resumed.
+func isFramingLine(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *goTestParser) handleEvent(event *goTestEvent) {
+	if event.Package == "" {
+		// Build events identify the package by its import path,
+		// followed by the test binary, e.g. "v.io/x/foo [v.io/x/foo.test]".
+		if fields := strings.Fields(event.ImportPath); len(fields) > 0 {
+			pkg := p.pkg(fields[0])
+			switch event.Action {
+			case "build-output":
+				pkg.output.WriteString(event.Output)
+			case "build-fail":
+				pkg.buildFailed = true
+			}
+		}
+		return
+	}
+	pkg := p.pkg(event.Package)
+	if event.FailedBuild != "" {
+		pkg.buildFailed = true
+	}
+	if event.Test == "" {
+		switch event.Action {
+		case "output", "bench":
+			pkg.output.WriteString(event.Output)
+		case "pass", "fail", "skip":
+			pkg.result = event.Action
+		}
+		return
+	}
+	c := pkg.testCase(event.Test)
+	switch event.Action {
+	case "output", "bench":
+		if !isFramingLine(event.Output) {
+			c.output.WriteString(event.Output)
+		}
+	case "pass", "fail", "skip":
+		c.result, c.elapsed = event.Action, event.Elapsed
+	}
+}
+
+// handleText parses the output of "go test -v".
+func (p *goTestParser) handleText(lines []string) {
+	// The name of a package is only known once its result is reported,
+	// so the results of its tests are collected in a package without a
+	// name until then.
+	pkg := newGoTestPackage("")
+	var current *goTestCase
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if isFramingLine(line) {
+			if fields := strings.Fields(trimmed); len(fields) > 2 {
+				current = pkg.testCase(fields[2])
+			}
+			continue
+		}
+		if m := testResultRE.FindStringSubmatch(line); m != nil {
+			// Before Go 1.14, the output of tests followed their
+			// results, so the output following a result is attributed
+			// to the test.
+			current = pkg.testCase(m[2])
+			current.result = strings.ToLower(m[1])
+			current.elapsed, _ = strconv.ParseFloat(m[3], 64)
+			current.output.WriteString(line)
+			continue
+		}
+		if m := pkgResultRE.FindStringSubmatch(line); m != nil {
+			pkg.name = m[2]
+			switch m[1] {
+			case "ok", "?":
+				pkg.result = "pass"
+			case "FAIL":
+				pkg.result = "fail"
+			}
+			if strings.Contains(line, "[build failed]") || strings.Contains(line, "[setup failed]") {
+				pkg.buildFailed = true
+			}
+			pkg.output.WriteString(line)
+			p.addPackage(pkg)
+			pkg, current = newGoTestPackage(""), nil
+			continue
+		}
+		switch {
+		case trimmed == "PASS":
+		case trimmed == "FAIL":
+			pkg.result = "fail"
+		case current != nil:
+			current.output.WriteString(line)
+		default:
+			pkg.output.WriteString(line)
+		}
+	}
+	if len(pkg.cases) > 0 || pkg.output.Len() > 0 {
+		// The output ended before the result of the package, e.g.
+		// because the test binary was killed.
+		p.addPackage(pkg)
+	}
+}
+
+// addPackage adds the given package, merging it with the results of a
+// package of the same name.
+func (p *goTestParser) addPackage(pkg *goTestPackage) {
+	existing, ok := p.pkgsByName[pkg.name]
+	if !ok {
+		p.pkgs = append(p.pkgs, pkg)
+		p.pkgsByName[pkg.name] = pkg
+		return
+	}
+	for _, c := range pkg.cases {
+		*existing.testCase(c.name) = *c
+	}
+	existing.output.Write(pkg.output.Bytes())
+	existing.buildFailed = existing.buildFailed || pkg.buildFailed
+	if pkg.result != "" {
+		existing.result = pkg.result
+	}
+}
+
+// skipReason returns the reason for skipping a test with the given output.
+func skipReason(output string) string {
+	var reason []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !testResultRE.MatchString(line) {
+			reason = append(reason, line)
+		}
+	}
+	return strings.Join(reason, "\n")
+}
+
+// suites returns the test suites of the packages.
+func (p *goTestParser) suites() []*TestSuite {
+	suites := []*TestSuite{}
+	for _, pkg := range p.pkgs {
+		s := &TestSuite{Name: pkg.name}
+		for _, c := range pkg.cases {
+			tc := TestCase{
+				Classname: pkg.name,
+				Name:      c.name,
+				Time:      fmt.Sprintf("%.2f", c.elapsed),
+			}
+			switch c.result {
+			case "pass":
+			case "skip":
+				tc.Skipped = []string{skipReason(c.output.String())}
+				s.Skip++
+			case "fail":
+				tc.Failures = append(tc.Failures, Failure{Message: "error", Data: c.output.String()})
+				s.Failures++
+			default:
+				// The test didn't finish, e.g. because it timed out or
+				// panicked, which is reported in the output of the
+				// package.
+				tc.Failures = append(tc.Failures, Failure{Message: "error", Data: c.output.String() + pkg.output.String()})
+				s.Failures++
+			}
+			s.Cases = append(s.Cases, tc)
+			s.Tests++
+		}
+		if pkg.result == "fail" && s.Failures == 0 {
+			// The package failed without any of its tests failing,
+			// e.g. because it didn't build or TestMain failed.
+			message := "package failure"
+			if pkg.buildFailed {
+				message = "build failure"
+			}
+			s.Cases = append(s.Cases, TestCase{
+				Classname: pkg.name,
+				Name:      "Test",
+				Time:      "0.00",
+				Failures:  []Failure{{Message: message, Data: pkg.output.String() + p.output.String()}},
+			})
+			s.Tests++
+			s.Failures++
+		}
+		suites = append(suites, s)
+	}
+	return suites
+}
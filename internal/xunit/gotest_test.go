@@ -0,0 +1,161 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testdataPkg = "v.io/x/devtools/internal/xunit/testdata/"
+
+// testCaseResult summarizes a test case for comparison.
+type testCaseResult struct {
+	name, result, data string
+}
+
+func summarize(suite *TestSuite) []testCaseResult {
+	var results []testCaseResult
+	for _, c := range suite.Cases {
+		r := testCaseResult{name: c.Name, result: "pass"}
+		switch {
+		case len(c.Failures) > 0:
+			r.result, r.data = c.Failures[0].Message, c.Failures[0].Data
+		case len(c.Skipped) > 0:
+			r.result, r.data = "skip", c.Skipped[0]
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func parseTestdata(t *testing.T, file string) []*TestSuite {
+	f, err := os.Open(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer f.Close()
+	suites, err := TestSuitesFromGoTestOutput(f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(suites), 1; got != want {
+		t.Fatalf("%v: got %v suites, want %v", file, got, want)
+	}
+	return suites
+}
+
+func TestTestSuitesFromGoTestOutput(t *testing.T) {
+	for _, file := range []string{"foo.json", "foo.txt"} {
+		suite := parseTestdata(t, file)[0]
+		if got, want := suite.Name, testdataPkg+"foo"; got != want {
+			t.Errorf("%v: got suite %q, want %q", file, got, want)
+		}
+		if got, want := []int{suite.Tests, suite.Failures, suite.Skip}, []int{6, 3, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got tests, failures, skips %v, want %v", file, got, want)
+		}
+		results := summarize(suite)
+		for i := range results {
+			// The output of failed tests includes their results, which is
+			// checked separately.
+			if results[i].result == "error" {
+				if !strings.Contains(results[i].data, "--- FAIL: "+results[i].name) {
+					t.Errorf("%v: output of %v doesn't contain its result: %q", file, results[i].name, results[i].data)
+				}
+				results[i].data = strings.TrimRight(results[i].data[:strings.Index(results[i].data, "--- FAIL")], " ")
+			}
+		}
+		want := []testCaseResult{
+			{"TestPass", "pass", ""},
+			{"TestFail", "error", "    foo_test.go:6: bad value\n"},
+			{"TestSkip", "skip", "foo_test.go:7: not today"},
+			{"TestSub", "error", ""},
+			{"TestSub/a", "pass", ""},
+			{"TestSub/b", "error", "    foo_test.go:10: sub failed\n"},
+		}
+		if !reflect.DeepEqual(results, want) {
+			t.Errorf("%v: got test cases %v, want %v", file, results, want)
+		}
+	}
+}
+
+func TestTestSuitesFromGoTestOutputTimeout(t *testing.T) {
+	for _, file := range []string{"slow.json", "slow.txt"} {
+		suite := parseTestdata(t, file)[0]
+		results := summarize(suite)
+		if got, want := len(results), 1; got != want {
+			t.Fatalf("%v: got %v test cases, want %v", file, got, want)
+		}
+		if got, want := results[0].name, "TestWithSleep"; got != want {
+			t.Errorf("%v: got test case %q, want %q", file, got, want)
+		}
+		if got, want := results[0].result, "error"; got != want {
+			t.Errorf("%v: got result %q, want %q", file, got, want)
+		}
+		if !strings.Contains(results[0].data, "panic: test timed out") {
+			t.Errorf("%v: failure doesn't report the timeout: %q", file, results[0].data)
+		}
+	}
+}
+
+func TestTestSuitesFromGoTestOutputBuildFailure(t *testing.T) {
+	for _, file := range []string{"broken.json", "broken.txt"} {
+		suite := parseTestdata(t, file)[0]
+		if got, want := suite.Name, testdataPkg+"broken"; got != want {
+			t.Errorf("%v: got suite %q, want %q", file, got, want)
+		}
+		results := summarize(suite)
+		if got, want := len(results), 1; got != want {
+			t.Fatalf("%v: got %v test cases, want %v", file, got, want)
+		}
+		if got, want := results[0].result, "build failure"; got != want {
+			t.Errorf("%v: got result %q, want %q", file, got, want)
+		}
+		if !strings.Contains(results[0].data, "too many return values") {
+			t.Errorf("%v: failure doesn't report the build error: %q", file, results[0].data)
+		}
+	}
+}
+
+func TestTestSuitesFromGoTestOutputBareComment(t *testing.T) {
+	output := "# \n" + `{"Action":"run","Package":"v.io/x/foo","Test":"TestFoo"}
+{"Action":"pass","Package":"v.io/x/foo","Test":"TestFoo","Elapsed":0}
+{"Action":"pass","Package":"v.io/x/foo","Elapsed":0.002}
+`
+	suites, err := TestSuitesFromGoTestOutput(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(suites), 1; got != want {
+		t.Fatalf("got %v suites, want %v", got, want)
+	}
+	if got, want := suites[0].Name, "v.io/x/foo"; got != want {
+		t.Errorf("got suite %q, want %q", got, want)
+	}
+}
+
+func TestTextFromGoTestOutput(t *testing.T) {
+	output := `{"Action":"run","Package":"v.io/x/foo","Test":"TestFoo"}
+{"Action":"output","Package":"v.io/x/foo","Test":"TestFoo","Output":"=== RUN   TestFoo\n"}
+{"Action":"output","Package":"v.io/x/foo","Test":"TestFoo","Output":"    foo_test.go:6: bad value\n"}
+{"Action":"output","Package":"v.io/x/foo","Test":"TestFoo","Output":"--- FAIL: TestFoo (0.00s)\n"}
+{"Action":"fail","Package":"v.io/x/foo","Test":"TestFoo","Elapsed":0}
+not an event
+{"Action":"output","Package":"v.io/x/foo","Output":"FAIL\tv.io/x/foo\t0.002s\n"}
+{"Action":"fail","Package":"v.io/x/foo","Elapsed":0.002}
+`
+	want := `=== RUN   TestFoo
+    foo_test.go:6: bad value
+--- FAIL: TestFoo (0.00s)
+not an event
+FAIL	v.io/x/foo	0.002s
+`
+	if got := TextFromGoTestOutput(output); got != want {
+		t.Errorf("got text\n%v\nwant\n%v", got, want)
+	}
+}
@@ -7,17 +7,14 @@
 package xunit
 
 import (
-	"bytes"
 	"encoding/xml"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"v.io/jiri"
-	"v.io/x/devtools/tooldata"
 )
 
 type TestSuites struct {
@@ -41,6 +38,12 @@ type TestCase struct {
 	Failures  []Failure `xml:"failure"`
 	Time      string    `xml:"time,attr"`
 	Skipped   []string  `xml:"skipped"`
+	// FlakyFailures records the failed attempts of a test case that
+	// passed when rerun.
+	FlakyFailures []Failure `xml:"flakyFailure"`
+	// RerunFailures records the failed reruns of a test case that
+	// failed.
+	RerunFailures []Failure `xml:"rerunFailure"`
 }
 
 type Error struct {
@@ -107,50 +110,3 @@ func ReportPath(testName string) string {
 		return filepath.Join(workspace, fileName)
 	}
 }
-
-// TestSuitesFromGoTestOutput reads data from the given input, assuming
-// it contains test results generated by "go test -v", and returns it
-// as an in-memory data structure.
-func TestSuitesFromGoTestOutput(jirix *jiri.X, testOutput io.Reader) ([]*TestSuite, error) {
-	bin, err := tooldata.ThirdPartyBinPath(jirix, "go2xunit")
-	if err != nil {
-		return nil, err
-	}
-	var out, errout bytes.Buffer
-	if err := jirix.NewSeq().Read(testOutput).Capture(&out, &errout).Last(bin); err != nil {
-		fmt.Fprintf(os.Stderr, "XX: %v\n", string(out.Bytes()))
-		fmt.Fprintf(os.Stderr, "YY: %v\n", string(errout.Bytes()))
-
-		return nil, err
-	}
-	var suite TestSuite
-	if err := xml.Unmarshal(out.Bytes(), &suite); err != nil {
-		return nil, fmt.Errorf("Unmarshal() failed: %v\n%v", err, out.String())
-	}
-	if suite.Tests > 0 {
-		return []*TestSuite{&suite}, nil
-	}
-	// go2xunit has most likely output multiple testsuites i.e.
-	// <testsuites>
-	//   <testsuite>
-	//   </testsuite>
-	//   <testsuite>
-	//   </testsuite>
-	// </testsuites>
-	// which results in zero tests if Unmarshal is called expecting a single
-	// testsuite. This seems to happen when a test itself invokes a test.
-	var suites TestSuites
-	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
-		if !strings.Contains(err.Error(), "expected element type <testsuites> but have <testsuite>") {
-			return nil, fmt.Errorf("Unmarshal() failed: %v\n%v", err, out.String())
-		}
-	}
-	if len(suites.Suites) == 0 {
-		return []*TestSuite{&suite}, nil
-	}
-	rc := make([]*TestSuite, len(suites.Suites), len(suites.Suites))
-	for i, _ := range suites.Suites {
-		rc[i] = &suites.Suites[i]
-	}
-	return rc, nil
-}
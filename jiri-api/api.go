@@ -10,11 +10,13 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"v.io/jiri"
@@ -26,22 +28,27 @@ import (
 	"v.io/jiri/project"
 	"v.io/jiri/runutil"
 	"v.io/jiri/tool"
+	"v.io/x/devtools/jiri-api/exitcode"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/cmdline"
-	"v.io/x/lib/envvar"
 )
 
 var (
 	detailedOutputFlag bool
-	gotoolsBinPathFlag string
+	strictFlag         bool
+	formatFlag         string
 	readerFlags        profilescmdline.ReaderFlagValues
 
 	commentRE = regexp.MustCompile("^($|[:space:]*#)")
+
+	errAPICheckFailed = errors.New("public API check failed")
 )
 
 func init() {
 	cmdAPICheck.Flags.BoolVar(&detailedOutputFlag, "detailed", true, "If true, shows each API change in an expanded form. Otherwise, only a summary is shown.")
-	cmdAPI.Flags.StringVar(&gotoolsBinPathFlag, "gotools-bin", "", "The path to the gotools binary to use. If empty, gotools will be built if necessary.")
+	cmdAPICheck.Flags.BoolVar(&strictFlag, "strict", false, "If true, the check fails on any API change. Otherwise, it only fails on breaking changes.")
+	cmdAPICheck.Flags.StringVar(&formatFlag, "format", formatText, "The output format, text or json. The json format reports the project, package, .api file and changes of each changed package.")
+	cmdAPILog.Flags.StringVar(&formatFlag, "format", formatText, "The output format, text or json.")
 	profilescmdline.RegisterReaderFlags(&cmdAPI.Flags, &readerFlags, "v23:base", jiri.ProfilesDBDir)
 	tool.InitializeProjectFlags(&cmdAPI.Flags)
 	tool.InitializeRunFlags(&cmdAPI.Flags)
@@ -52,15 +59,25 @@ var cmdAPI = &cmdline.Command{
 	Name:     "api",
 	Short:    "Manage vanadium public API",
 	Long:     "Use this command to ensure that no unintended changes are made to the vanadium public API.",
-	Children: []*cmdline.Command{cmdAPICheck, cmdAPIUpdate},
+	Children: []*cmdline.Command{cmdAPICheck, cmdAPIUpdate, cmdAPILog},
 }
 
 // cmdAPICheck represents the "jiri api check" command.
 var cmdAPICheck = &cmdline.Command{
-	Runner:   jiri.RunnerFunc(runAPICheck),
-	Name:     "check",
-	Short:    "Check if any changes have been made to the public API",
-	Long:     "Check if any changes have been made to the public API.",
+	Runner: jiri.RunnerFunc(runAPICheck),
+	Name:   "check",
+	Short:  "Check if any changes have been made to the public API",
+	Long: `
+Check if any changes have been made to the public API.
+
+The public API of each package is compared with its .api file entry by entry,
+ignoring the formatting of types and the names of parameters and results.  Each
+change is classified as compatible, e.g. an added function, method, type,
+interface or struct field, or breaking, e.g. a removed or changed entry or a
+method added to an existing interface.  The changes are listed by package and
+kind, and the check fails with exit status 3 if there are any breaking changes,
+or any changes at all if -strict is set.
+`,
 	ArgsName: "<projects>",
 	ArgsLong: "<projects> is a list of vanadium projects to check. If none are specified, all projects that require a public API check upon presubmit are checked.",
 }
@@ -92,71 +109,26 @@ type packageChange struct {
 	name          string
 	projectName   string
 	apiFilePath   string
-	oldAPI        map[string]bool // set
-	newAPI        map[string]bool // set
+	changes       []apiChange
 	newAPIContent []byte
 
 	// If true, indicates that there was a problem reading the old API file.
 	apiFileError error
 }
 
-// buildGotools builds the gotools binary and returns the path to the built
-// binary and the function to call to clean up the built binary (always
-// non-nil). If the binary could not be built, the empty string and a non-nil
-// error are returned.
-//
-// If the gotools_bin flag is specified, that path, a no-op cleanup and a
-// nil error are returned.
-func buildGotools(jirix *jiri.X) (string, func() error, error) {
-	nopCleanup := func() error { return nil }
-	if gotoolsBinPathFlag != "" {
-		return gotoolsBinPathFlag, nopCleanup, nil
-	}
-
-	// Determine the location of the gotools source.
-	projects, err := project.LocalProjects(jirix, project.FastScan)
-	if err != nil {
-		return "", nopCleanup, err
-	}
-	project, err := projects.FindUnique("third_party")
-	if err != nil {
-		return "", nopCleanup, fmt.Errorf("error finding project %q: %v", "third_party", err)
-	}
-	newGoPath := filepath.Join(project.Path, "go")
-
-	s := jirix.NewSeq()
-
-	// Build the gotools binary.
-	tempDir, err := s.TempDir("", "")
-	if err != nil {
-		return "", nopCleanup, err
-	}
-	cleanup := func() error { return jirix.NewSeq().RemoveAll(tempDir).Done() }
-
-	gotoolsBin := filepath.Join(tempDir, "gotools")
-	env := envvar.CopyMap(jirix.Env())
-	env["GOPATH"] = newGoPath
-	if err := s.Env(env).Last("go", "build", "-o", gotoolsBin, "github.com/visualfc/gotools"); err != nil {
-		return "", cleanup, err
-	}
-
-	return gotoolsBin, cleanup, nil
-}
-
-// getCurrentAPI runs the gotools api command against the given directory and
-// returns the bytes that should go into the .api file for that directory.
-func getCurrentAPI(jirix *jiri.X, gotoolsBin, dir string) ([]byte, error) {
+// getCurrentAPI computes the public API of the package in the given directory
+// and returns the bytes that should go into the .api file for that directory.
+func getCurrentAPI(jirix *jiri.X, dir string) ([]byte, error) {
 	rd, err := profilesreader.NewReader(jirix, readerFlags.ProfilesMode, readerFlags.DBFilename)
 	if err != nil {
 		return nil, err
 	}
 	rd.MergeEnvFromProfiles(readerFlags.MergePolicies, profiles.NativeTarget(), "jiri")
-	s := jirix.NewSeq()
-	var output bytes.Buffer
-	if err := s.Capture(&output, nil).Env(rd.ToMap()).Last(gotoolsBin, "goapi", dir); err != nil {
+	pkg, err := loadPackage(rd.ToMap(), dir)
+	if err != nil || pkg == nil {
 		return nil, err
 	}
-	return output.Bytes(), nil
+	return packageAPI(pkg.Types), nil
 }
 
 func isFailedAPICheckFatal(projectName string, apiCheckProjects map[string]struct{}, apiFileError error) bool {
@@ -181,13 +153,15 @@ func shouldIgnoreFile(file string) bool {
 	return false
 }
 
-func splitLinesToSet(in []byte) map[string]bool {
-	result := make(map[string]bool)
-	scanner := bufio.NewScanner(bytes.NewReader(in))
-	for scanner.Scan() {
-		result[scanner.Text()] = true
+// isBreaking returns true if the package change includes any breaking
+// changes.
+func (change packageChange) isBreaking() bool {
+	for _, c := range change.changes {
+		if c.breaking {
+			return true
+		}
 	}
-	return result
+	return false
 }
 
 func packageName(path string) string {
@@ -201,11 +175,6 @@ func packageName(path string) string {
 }
 
 func getPackageChanges(jirix *jiri.X, apiCheckProjects map[string]struct{}, args []string) (changes []packageChange, e error) {
-	gotoolsBin, cleanup, err := buildGotools(jirix)
-	if err != nil {
-		return nil, err
-	}
-	defer collect.Error(cleanup, &e)
 	projects, err := project.ParseNames(jirix, args, apiCheckProjects)
 	if err != nil {
 		return nil, err
@@ -228,7 +197,7 @@ func getPackageChanges(jirix *jiri.X, apiCheckProjects map[string]struct{}, args
 		}
 		for dir := range dirs {
 			// Read the API state in the working directory.
-			currentAPI, err := getCurrentAPI(jirix, gotoolsBin, dir)
+			currentAPI, err := getCurrentAPI(jirix, dir)
 			if err != nil {
 				return nil, err
 			}
@@ -262,54 +231,89 @@ func getPackageChanges(jirix *jiri.X, apiCheckProjects map[string]struct{}, args
 					name:          pkgName,
 					projectName:   project.Name,
 					apiFilePath:   apiFilePath,
-					oldAPI:        splitLinesToSet(apiFileContents),
-					newAPI:        splitLinesToSet(currentAPI),
+					changes:       diffAPI(apiFileContents, currentAPI),
 					newAPIContent: currentAPI,
 					apiFileError:  apiFileError,
 				})
 			}
 		}
 	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
 	return
 }
 
 func runAPICheck(jirix *jiri.X, args []string) error {
-	return doAPICheck(jirix, args, detailedOutputFlag)
+	if formatFlag != formatText && formatFlag != formatJSON {
+		return jirix.UsageErrorf("unknown format %q", formatFlag)
+	}
+	err := doAPICheck(jirix, args, detailedOutputFlag, strictFlag, formatFlag)
+	if err == errAPICheckFailed {
+		// Exit with a distinct status, so that failed checks can be told
+		// apart from failures to run the check.
+		fmt.Fprintf(jirix.Stderr(), "ERROR: %v\n", err)
+		return cmdline.ErrExitCode(exitcode.APICheckFailedExitCode)
+	}
+	return err
+}
+
+// changeGroups lists the groups of changes printed for each package, in order.
+var changeGroups = []struct {
+	kind     changeKind
+	breaking bool
+}{
+	{removedEntry, true},
+	{changedEntry, true},
+	{addedEntry, true},
+	{addedEntry, false},
 }
 
 func printChangeSummary(out io.Writer, change packageChange, detailedOutput bool) {
-	var removedEntries []string
-	var addedEntries []string
-	for entry, _ := range change.oldAPI {
-		if !change.newAPI[entry] {
-			removedEntries = append(removedEntries, entry)
-		}
-	}
-	for entry, _ := range change.newAPI {
-		if !change.oldAPI[entry] {
-			addedEntries = append(addedEntries, entry)
+	if !detailedOutput {
+		counts := make(map[changeKind]int)
+		breaking := 0
+		for _, c := range change.changes {
+			counts[c.kind]++
+			if c.breaking {
+				breaking++
+			}
 		}
+		fmt.Fprintf(out, "package %s: %d entries removed, %d entries changed, %d entries added, %d breaking changes\n", change.name, counts[removedEntry], counts[changedEntry], counts[addedEntry], breaking)
+		return
 	}
-	if detailedOutput {
-		fmt.Fprintf(out, "Changes for package %s\n", change.name)
-		if len(removedEntries) > 0 {
-			fmt.Fprintf(out, "The following %d entries were removed:\n", len(removedEntries))
-			for _, entry := range removedEntries {
-				fmt.Fprintf(out, "\t%s\n", entry)
+	fmt.Fprintf(out, "Changes for package %s\n", change.name)
+	for _, group := range changeGroups {
+		var entries []apiChange
+		for _, c := range change.changes {
+			if c.kind == group.kind && c.breaking == group.breaking {
+				entries = append(entries, c)
 			}
 		}
-		if len(addedEntries) > 0 {
-			fmt.Fprintf(out, "The following %d entries were added:\n", len(addedEntries))
-			for _, entry := range addedEntries {
-				fmt.Fprintf(out, "\t%s\n", entry)
+		if len(entries) == 0 {
+			continue
+		}
+		compatibility := "compatible"
+		if group.breaking {
+			compatibility = "breaking"
+		}
+		fmt.Fprintf(out, "The following %d entries were %v (%s):\n", len(entries), group.kind, compatibility)
+		for _, c := range entries {
+			switch c.kind {
+			case removedEntry:
+				fmt.Fprintf(out, "\t%s\n", c.old)
+			case changedEntry:
+				fmt.Fprintf(out, "\t%s\n\t=> %s\n", c.old, c.new)
+			case addedEntry:
+				fmt.Fprintf(out, "\t%s\n", c.new)
 			}
 		}
-	} else {
-		fmt.Fprintf(out, "package %s: %d entries removed, %d entries added\n", change.name, len(removedEntries), len(addedEntries))
 	}
 }
 
-func doAPICheck(jirix *jiri.X, args []string, detailedOutput bool) error {
+// doAPICheck prints the changes to the public API of the given projects in the
+// given format and returns errAPICheckFailed if there are breaking changes, or
+// any changes if strict is true.  Packages whose .api files only differ from
+// their public API in formatting are not reported.
+func doAPICheck(jirix *jiri.X, args []string, detailedOutput, strict bool, format string) error {
 	config, err := tooldata.LoadConfig(jirix)
 	if err != nil {
 		return err
@@ -317,16 +321,31 @@ func doAPICheck(jirix *jiri.X, args []string, detailedOutput bool) error {
 	changes, err := getPackageChanges(jirix, config.APICheckProjects(), args)
 	if err != nil {
 		return err
-	} else if len(changes) > 0 {
-		for _, change := range changes {
-			if change.apiFileError != nil {
+	}
+	if format == formatJSON {
+		if err := printPackageChangesJSON(jirix.Stdout(), changes); err != nil {
+			return err
+		}
+	}
+	failed := false
+	for _, change := range changes {
+		switch {
+		case change.apiFileError != nil:
+			if format == formatText {
 				fmt.Fprintf(jirix.Stdout(), "ERROR: package %s: could not read the package's .api file: %v\n", change.name, change.apiFileError)
 				fmt.Fprintf(jirix.Stdout(), "ERROR: a readable .api file is required for all packages in project %s\n", change.projectName)
-			} else {
+			}
+			failed = true
+		case len(change.changes) > 0:
+			if format == formatText {
 				printChangeSummary(jirix.Stdout(), change, detailedOutput)
 			}
+			failed = failed || strict || change.isBreaking()
 		}
 	}
+	if failed {
+		return errAPICheckFailed
+	}
 	return nil
 }
 
@@ -6,8 +6,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -30,12 +32,6 @@ func writeFileOrDie(t *testing.T, jirix *jiri.X, path, contents string) {
 // representing the environment that was created, along with a cleanup closure
 // that should be deferred.
 func setupAPITest(t *testing.T) (*jiritest.FakeJiriRoot, func()) {
-	// Capture JIRI_ROOT, using a relative path.  We use this to find the
-	// third_party repository below.
-	realRoot, err := filepath.Abs(filepath.Join("..", "..", "..", "..", "..", "..", ".."))
-	if err != nil {
-		t.Fatal(err)
-	}
 	// Set up a fake jiri environment, with a test project.
 	fake, cleanupFake := jiritest.NewFakeJiriRoot(t)
 	if err := fake.CreateRemoteProject("test"); err != nil {
@@ -50,37 +46,11 @@ func setupAPITest(t *testing.T) (*jiritest.FakeJiriRoot, func()) {
 		t.Fatal(err)
 	}
 
-	// Set up a third_party project, based on the real root.  We need the real
-	// third_party sources in order for buildGotools to work.
-	if err := fake.CreateRemoteProject("third_party"); err != nil {
-		t.Fatal(err)
-	}
-	if err := fake.AddProject(project.Project{
-		Name:   "third_party",
-		Path:   "third_party",
-		Remote: filepath.Join(realRoot, "third_party"),
-	}); err != nil {
-		t.Fatal(err)
-	}
-
 	if err := fake.UpdateUniverse(false); err != nil {
 		t.Fatal(err)
 	}
 
-	// Build gotools for use in the rest of the api tests.
-	gotoolsPath, cleanupGotools, err := buildGotools(fake.X)
-	if err != nil {
-		t.Fatalf("buildGotools failed: %v", err)
-	}
-	gotoolsBinPathFlag = gotoolsPath
-
-	return fake, func() {
-		if err := cleanupGotools(); err != nil {
-			t.Fatal(err)
-		}
-		cleanupFake()
-		gotoolsBinPathFlag = ""
-	}
+	return fake, cleanupFake
 }
 
 // TestPublicAPICheckError checks that the public API check fails for
@@ -117,8 +87,8 @@ func testFunction() {
 
 	var buf bytes.Buffer
 	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
-	if err := doAPICheck(fake.X, []string{"test"}, true); err != nil {
-		t.Fatalf("doAPICheck failed: %v", err)
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatText); err != errAPICheckFailed {
+		t.Fatalf("doAPICheck returned %v, want %v", err, errAPICheckFailed)
 	} else if buf.String() == "" {
 		t.Fatalf("doAPICheck detected no changes, but some were expected")
 	}
@@ -157,13 +127,115 @@ func TestFunction() {
 
 	var buf bytes.Buffer
 	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
-	if err := doAPICheck(fake.X, []string{"test"}, true); err != nil {
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatText); err != nil {
 		t.Fatalf("doAPICheck failed: %v", err)
 	} else if buf.String() != "" {
 		t.Fatalf("doAPICheck detected changes, but none were expected: %s", buf.String())
 	}
 }
 
+// TestPublicAPICheckCompatible checks that the public API check only fails
+// for a CL that introduces compatible changes to the public API if -strict is
+// set.
+func TestPublicAPICheckCompatible(t *testing.T) {
+	fake, cleanup := setupAPITest(t)
+	defer cleanup()
+	config := tooldata.NewConfig(tooldata.APICheckProjectsOpt(map[string]struct{}{"test": struct{}{}}))
+	if err := tooldata.SaveConfig(fake.X, config); err != nil {
+		t.Fatalf("%v", err)
+	}
+	branch := "my-branch"
+	projectPath := filepath.Join(fake.X.Root, "test")
+	if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(projectPath)).CreateAndCheckoutBranch(branch); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Simulate an API with an existing public function called TestFunction.
+	writeFileOrDie(t, fake.X, filepath.Join(projectPath, ".api"), `# This is a comment that should be ignored
+pkg main, func TestFunction()
+`)
+
+	// Write a change that adds TestFunction2.
+	writeFileOrDie(t, fake.X, filepath.Join(projectPath, "file.go"), `package main
+
+func TestFunction() {
+}
+
+func TestFunction2(a, b int) {
+}`)
+
+	commitMessage := "Commit file.go"
+	if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(projectPath)).CommitFile("file.go", commitMessage); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var buf bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatText); err != nil {
+		t.Fatalf("doAPICheck failed: %v", err)
+	} else if !strings.Contains(buf.String(), "were added (compatible)") {
+		t.Fatalf("doAPICheck did not report the compatible change: %s", buf.String())
+	}
+	buf.Reset()
+	if err := doAPICheck(fake.X, []string{"test"}, true, true, formatText); err != errAPICheckFailed {
+		t.Fatalf("doAPICheck returned %v, want %v", err, errAPICheckFailed)
+	}
+}
+
+// TestPublicAPICheckJSON checks that the public API check reports the changes
+// to the public API as JSON.
+func TestPublicAPICheckJSON(t *testing.T) {
+	fake, cleanup := setupAPITest(t)
+	defer cleanup()
+	config := tooldata.NewConfig(tooldata.APICheckProjectsOpt(map[string]struct{}{"test": struct{}{}}))
+	if err := tooldata.SaveConfig(fake.X, config); err != nil {
+		t.Fatalf("%v", err)
+	}
+	branch := "my-branch"
+	projectPath := filepath.Join(fake.X.Root, "test")
+	if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(projectPath)).CreateAndCheckoutBranch(branch); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Simulate an API with an existing public function called TestFunction.
+	writeFileOrDie(t, fake.X, filepath.Join(projectPath, ".api"), `pkg main, func TestFunction()
+`)
+
+	// Write a change that renames TestFunction to TestFunction2.
+	writeFileOrDie(t, fake.X, filepath.Join(projectPath, "file.go"), `package main
+
+func TestFunction2() {
+}`)
+
+	commitMessage := "Commit file.go"
+	if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(projectPath)).CommitFile("file.go", commitMessage); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var buf bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatJSON); err != errAPICheckFailed {
+		t.Fatalf("doAPICheck returned %v, want %v", err, errAPICheckFailed)
+	}
+	var got []jsonPackageChange
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%s) failed: %v", buf.String(), err)
+	}
+	want := []jsonPackageChange{{
+		Project:  "test",
+		Package:  got[0].Package,
+		APIFile:  filepath.Join(projectPath, ".api"),
+		Breaking: true,
+		Changes: []jsonChange{
+			{Kind: "removed", Old: "pkg main, func TestFunction()", Breaking: true},
+			{Kind: "added", New: "pkg main, func TestFunction2()"},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
 // TestPublicAPIMissingAPIFile ensures that the check will fail if a 'required
 // check' project has a missing .api file and a non-empty public API.
 func TestPublicAPIMissingAPIFile(t *testing.T) {
@@ -192,8 +264,8 @@ func TestFunction() {
 
 	var buf bytes.Buffer
 	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
-	if err := doAPICheck(fake.X, []string{"test"}, true); err != nil {
-		t.Fatalf("doAPICheck failed: %v", err)
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatText); err != errAPICheckFailed {
+		t.Fatalf("doAPICheck returned %v, want %v", err, errAPICheckFailed)
 	} else if buf.String() == "" {
 		t.Fatalf("doAPICheck should have failed, but did not")
 	} else if !strings.Contains(buf.String(), "could not read the package's .api file") {
@@ -229,7 +301,7 @@ func testFunction() {
 
 	var buf bytes.Buffer
 	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
-	if err := doAPICheck(fake.X, []string{"test"}, true); err != nil {
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatText); err != nil {
 		t.Fatalf("doAPICheck failed: %v", err)
 	} else if output := buf.String(); output != "" {
 		t.Fatalf("doAPICheck should have passed, but did not: %s", output)
@@ -269,7 +341,7 @@ func TestFunction() {
 
 	var buf bytes.Buffer
 	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &buf})
-	if err := doAPICheck(fake.X, []string{"test"}, true); err != nil {
+	if err := doAPICheck(fake.X, []string{"test"}, true, false, formatText); err != nil {
 		t.Fatalf("doAPICheck failed: %v", err)
 	} else if buf.String() != "" {
 		t.Fatalf("doAPICheck should have passed, but did not: %s", buf.String())
@@ -0,0 +1,240 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// changeKind identifies the kind of a change to an entry of the public API.
+type changeKind int
+
+const (
+	removedEntry changeKind = iota
+	changedEntry
+	addedEntry
+)
+
+func (k changeKind) String() string {
+	switch k {
+	case removedEntry:
+		return "removed"
+	case changedEntry:
+		return "changed"
+	case addedEntry:
+		return "added"
+	}
+	return "unknown"
+}
+
+// apiEntry is an entry of a .api file, e.g.
+//
+//	pkg p, func F(int) error
+//
+// split into the key that identifies the exported symbol, e.g. "pkg p, func
+// F", and its normalized type or value, e.g. "func(int) error".
+type apiEntry struct {
+	line  string
+	key   string
+	value string
+}
+
+// apiChange is a change to an entry of the public API.
+type apiChange struct {
+	kind     changeKind
+	old, new string // The old and new .api lines; old is empty for added entries and new is empty for removed entries.
+	breaking bool
+}
+
+// parseAPIEntry parses a line of a .api file, which is of the form
+// "pkg <package>, <declaration>", where <declaration> is one of:
+//
+//	const <name> = <value>
+//	const <name> <type>
+//	var <name> <type>
+//	func <name>(<params>) <results>
+//	method (<receiver>) <name>(<params>) <results>
+//	type <name> <type>
+//	type <name> struct, <field> <type>
+//	type <name> struct, embedded <type>
+//	type <name> interface { <methods> }
+//	type <name> interface, <method>(<params>) <results>
+//
+// Lines that don't match any of these forms are compared verbatim.
+func parseAPIEntry(line string) apiEntry {
+	line = strings.Join(strings.Fields(line), " ")
+	entry := apiEntry{line: line, key: line}
+	i := strings.Index(line, ", ")
+	if !strings.HasPrefix(line, "pkg ") || i == -1 {
+		return entry
+	}
+	pkg, decl := line[:i+2], line[i+2:]
+	key, value := decl, ""
+	// nameEnd returns the index of the space following the name declared by
+	// decl, or -1 if there is none.
+	nameEnd := func() int {
+		start := strings.Index(decl, " ") + 1
+		if j := strings.Index(decl[start:], " "); j != -1 {
+			return start + j
+		}
+		return -1
+	}
+	switch {
+	case strings.HasPrefix(decl, "type "):
+		j := strings.Index(decl, ", ")
+		if j != -1 && (strings.HasSuffix(decl[:j], " struct") || strings.HasSuffix(decl[:j], " interface")) {
+			// A field or method of a struct or interface type.  The types
+			// of embedded fields are part of their keys.
+			typ, member := decl[:j+2], decl[j+2:]
+			switch {
+			case strings.HasPrefix(member, "embedded "):
+			case strings.HasSuffix(typ, " interface, "):
+				if p := strings.Index(member, "("); p != -1 {
+					key, value = typ+member[:p], "func"+member[p:]
+				}
+			default:
+				if s := strings.Index(member, " "); s != -1 {
+					key, value = typ+member[:s], member[s+1:]
+				}
+			}
+		} else if s := nameEnd(); s != -1 {
+			key, value = decl[:s], decl[s+1:]
+			// The methods of interfaces are listed in separate entries.
+			if strings.HasPrefix(value, "interface") {
+				value = "interface"
+			}
+		}
+	case strings.HasPrefix(decl, "func "):
+		if p := strings.Index(decl, "("); p != -1 {
+			key, value = decl[:p], "func"+decl[p:]
+		}
+	case strings.HasPrefix(decl, "method "):
+		if r := strings.Index(decl, ") "); r != -1 {
+			if p := strings.Index(decl[r+2:], "("); p != -1 {
+				p += r + 2
+				key, value = decl[:p], "func"+decl[p:]
+			}
+		}
+	case strings.HasPrefix(decl, "const ") && strings.Contains(decl, " = "):
+		// The value and the type of a constant are listed in separate
+		// entries.
+		j := strings.Index(decl, " = ")
+		key, value = decl[:j+2], decl[j+3:]
+	case strings.HasPrefix(decl, "const "), strings.HasPrefix(decl, "var "):
+		if s := nameEnd(); s != -1 {
+			key, value = decl[:s], decl[s+1:]
+		}
+	}
+	entry.key = pkg + key
+	if value != "" {
+		entry.value = normalize(value)
+	}
+	return entry
+}
+
+// normalize returns the canonical form of the given Go type or expression,
+// ignoring the formatting and the names of parameters and results, or the
+// input with its white space collapsed if it can't be parsed.  The types of
+// untyped constants, e.g. ideal-int, are not valid Go and are kept as is.
+func normalize(s string) string {
+	if strings.HasPrefix(s, "ideal-") {
+		return s
+	}
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ft, ok := n.(*ast.FuncType); ok {
+			stripNames(ft.Params)
+			stripNames(ft.Results)
+		}
+		return true
+	})
+	return types.ExprString(expr)
+}
+
+// stripNames removes the names from fields, repeating the type of fields that
+// declare several names.
+func stripNames(fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	var list []*ast.Field
+	for _, f := range fields.List {
+		for i := 0; i < len(f.Names) || i == 0; i++ {
+			list = append(list, &ast.Field{Type: f.Type})
+		}
+	}
+	fields.List = list
+}
+
+// parseAPI parses the contents of a .api file, returning the entries indexed
+// by their keys.  Empty lines and comments are ignored.
+func parseAPI(in []byte) map[string]apiEntry {
+	result := make(map[string]apiEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			entry := parseAPIEntry(line)
+			result[entry.key] = entry
+		}
+	}
+	return result
+}
+
+// isBreaking returns true if the given change may break existing users of the
+// API, whose entries before the change are oldEntries.  Removing or changing an
+// entry is breaking, as is adding a method to an existing interface, since
+// existing implementations of the interface no longer implement it.  Adding
+// any other entry, e.g. a function, a struct field or a new interface along
+// with its methods, is compatible.
+func isBreaking(kind changeKind, entry apiEntry, oldEntries map[string]apiEntry) bool {
+	if kind != addedEntry {
+		return true
+	}
+	i := strings.Index(entry.key, " interface, ")
+	if i == -1 {
+		return false
+	}
+	_, ok := oldEntries[entry.key[:i]]
+	return ok
+}
+
+// diffAPI compares the contents of two .api files and returns the changes from
+// the old to the new API, ordered by kind and then by entry.  Entries whose
+// types or values are equal after normalization are considered unchanged.
+func diffAPI(oldAPI, newAPI []byte) []apiChange {
+	oldEntries, newEntries := parseAPI(oldAPI), parseAPI(newAPI)
+	var changes []apiChange
+	for key, o := range oldEntries {
+		n, ok := newEntries[key]
+		switch {
+		case !ok:
+			changes = append(changes, apiChange{kind: removedEntry, old: o.line, breaking: isBreaking(removedEntry, o, oldEntries)})
+		case n.value != o.value:
+			changes = append(changes, apiChange{kind: changedEntry, old: o.line, new: n.line, breaking: isBreaking(changedEntry, n, oldEntries)})
+		}
+	}
+	for key, n := range newEntries {
+		if _, ok := oldEntries[key]; !ok {
+			changes = append(changes, apiChange{kind: addedEntry, new: n.line, breaking: isBreaking(addedEntry, n, oldEntries)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		ci, cj := changes[i], changes[j]
+		if ci.kind != cj.kind {
+			return ci.kind < cj.kind
+		}
+		return ci.old+ci.new < cj.old+cj.new
+	})
+	return changes
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAPIEntry(t *testing.T) {
+	tests := []struct {
+		line, key, value string
+	}{
+		{"pkg p, func F(a, b int) (err error)", "pkg p, func F", "func(int, int) error"},
+		{"pkg p, func F(int,int)  error", "pkg p, func F", "func(int, int) error"},
+		{"pkg p, method (*T) M(map[string] int) bool", "pkg p, method (*T) M", "func(map[string]int) bool"},
+		{"pkg p, const C = 10", "pkg p, const C =", "10"},
+		{"pkg p, const C ideal-int", "pkg p, const C", "ideal-int"},
+		{"pkg p, var V []*T", "pkg p, var V", "[]*T"},
+		{"pkg p, type T struct", "pkg p, type T", "struct"},
+		{"pkg p, type T struct, F func(a, b int)", "pkg p, type T struct, F", "func(int, int)"},
+		{"pkg p, type T struct, embedded *U", "pkg p, type T struct, embedded *U", ""},
+		{"pkg p, type F func(a, b int)", "pkg p, type F", "func(int, int)"},
+		{"pkg p, type I interface { M, N }", "pkg p, type I", "interface"},
+		{"pkg p, type I interface, M(int) error", "pkg p, type I interface, M", "func(int) error"},
+		{"pkg p, type I interface, unexported methods", "pkg p, type I interface, unexported methods", ""},
+		{"not an entry", "not an entry", ""},
+	}
+	for _, test := range tests {
+		entry := parseAPIEntry(test.line)
+		if entry.key != test.key || entry.value != test.value {
+			t.Errorf("parseAPIEntry(%q): got (%q, %q), want (%q, %q)", test.line, entry.key, entry.value, test.key, test.value)
+		}
+	}
+}
+
+func TestDiffAPI(t *testing.T) {
+	oldAPI := []byte(`pkg p, func Changed(int)
+pkg p, func Reformatted(a, b int) error
+pkg p, func Removed()
+pkg p, type I interface { M }
+pkg p, type I interface, M()
+pkg p, type T struct
+`)
+	newAPI := []byte(`pkg p, func Added()
+pkg p, func Changed(string)
+pkg p, func Reformatted(int, int) error
+pkg p, type I interface { M, N }
+pkg p, type I interface, M()
+pkg p, type I interface, N()
+pkg p, type J interface { M }
+pkg p, type J interface, M()
+pkg p, type T struct
+pkg p, type T struct, F int
+`)
+	want := []apiChange{
+		{kind: removedEntry, old: "pkg p, func Removed()", breaking: true},
+		{kind: changedEntry, old: "pkg p, func Changed(int)", new: "pkg p, func Changed(string)", breaking: true},
+		{kind: addedEntry, new: "pkg p, func Added()"},
+		{kind: addedEntry, new: "pkg p, type I interface, N()", breaking: true},
+		{kind: addedEntry, new: "pkg p, type J interface { M }"},
+		{kind: addedEntry, new: "pkg p, type J interface, M()"},
+		{kind: addedEntry, new: "pkg p, type T struct, F int"},
+	}
+	if got := diffAPI(oldAPI, newAPI); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got := diffAPI(newAPI, newAPI); len(got) != 0 {
+		t.Errorf("got %#v, want no changes", got)
+	}
+}
@@ -15,6 +15,7 @@ Usage:
 The jiri api commands are:
    check       Check if any changes have been made to the public API
    fix         Update api files to reflect changes to the public API
+   log         Show the history of the public API of a package
    help        Display help for commands or topics
 
 The jiri api flags are:
@@ -22,9 +23,6 @@ The jiri api flags are:
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
- -gotools-bin=
-   The path to the gotools binary to use. If empty, gotools will be built if
-   necessary.
  -manifest=
    Name of the project manifest.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -50,6 +48,14 @@ Jiri api check - Check if any changes have been made to the public API
 
 Check if any changes have been made to the public API.
 
+The public API of each package is compared with its .api file entry by entry,
+ignoring the formatting of types and the names of parameters and results.  Each
+change is classified as compatible, e.g. an added function, method, type,
+interface or struct field, or breaking, e.g. a removed or changed entry or a
+method added to an existing interface.  The changes are listed by package and
+kind, and the check fails with exit status 3 if there are any breaking changes,
+or any changes at all if -strict is set.
+
 Usage:
    jiri api check [flags] <projects>
 
@@ -60,14 +66,17 @@ The jiri api check flags are:
  -detailed=true
    If true, shows each API change in an expanded form. Otherwise, only a summary
    is shown.
+ -format=text
+   The output format, text or json. The json format reports the project,
+   package, .api file and changes of each changed package.
+ -strict=false
+   If true, the check fails on any API change. Otherwise, it only fails on
+   breaking changes.
 
  -color=true
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
- -gotools-bin=
-   The path to the gotools binary to use. If empty, gotools will be built if
-   necessary.
  -manifest=
    Name of the project manifest.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -98,9 +107,41 @@ The jiri api fix flags are:
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
- -gotools-bin=
-   The path to the gotools binary to use. If empty, gotools will be built if
-   necessary.
+ -manifest=
+   Name of the project manifest.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri api log - Show the history of the public API of a package
+
+Show the history of the public API of a package, i.e. the commits that changed
+the package's .api file, oldest first, along with the entries that each commit
+added, removed or changed.  Commits that only reformat the .api file are
+omitted.
+
+Usage:
+   jiri api log [flags] <package>
+
+<package> is the import path of the package.
+
+The jiri api log flags are:
+ -format=text
+   The output format, text or json.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
  -manifest=
    Name of the project manifest.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
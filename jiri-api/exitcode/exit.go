@@ -0,0 +1,12 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package exitcode
+
+const (
+	// APICheckFailedExitCode is returned when the api check finds
+	// breaking changes to the public API, or any changes in strict
+	// mode.
+	APICheckFailedExitCode = 3
+)
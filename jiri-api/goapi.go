@@ -0,0 +1,363 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// The public API of a package is computed from its exported declarations,
+// type-checked from source, while its dependencies are type-checked from the
+// export data produced by the go tool.
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+
+// loadPackage parses and type-checks the package in dir, using the given
+// environment variables.  It returns nil if dir doesn't contain a Go package,
+// e.g. because all its files are excluded by build constraints.
+func loadPackage(env map[string]string, dir string) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: loadMode,
+		Dir:  dir,
+		Env:  os.Environ(),
+	}
+	for key, value := range env {
+		cfg.Env = append(cfg.Env, key+"="+value)
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s: expected one package, got %d", dir, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.GoFiles) == 0 {
+		return nil, nil
+	}
+	var msgs []string
+	for _, err := range pkg.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	if len(msgs) > 0 {
+		return nil, errors.New(strings.Join(msgs, "\n"))
+	}
+	return pkg, nil
+}
+
+// packageAPI returns the public API of pkg, in the format of the .api files,
+// i.e. one line per feature, sorted.  A feature is an exported constant,
+// variable, function, type, method, struct field or interface method, e.g.
+//
+//	pkg p, func F(int, ...string) (bool, error)
+//	pkg p, method (*T) M() error
+//	pkg p, type T struct, F map[string]io.Reader
+//
+// Types are qualified by the names of the packages that declare them, unless
+// they are declared by pkg itself, and byte and rune are written as uint8 and
+// int32.  The format is that of the goapi tool, so the .api files written by
+// earlier versions of "jiri api fix" remain up to date.
+func packageAPI(pkg *types.Package) []byte {
+	w := &apiWriter{
+		pkg:      pkg,
+		features: make(map[string]bool),
+		scope:    []string{"pkg " + pkg.Name()},
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if obj := scope.Lookup(name); obj.Exported() {
+			w.emitObj(obj)
+		}
+	}
+	var features []string
+	for f := range w.features {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	var buf bytes.Buffer
+	for _, f := range features {
+		buf.WriteString(f)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// apiWriter collects the features of the public API of a package.
+type apiWriter struct {
+	pkg      *types.Package
+	features map[string]bool // set
+	scope    []string
+}
+
+func (w *apiWriter) emitf(format string, args ...interface{}) {
+	f := strings.Join(w.scope, ", ") + ", " + fmt.Sprintf(format, args...)
+	w.features[f] = true
+}
+
+// pushScope adds name to the scope of the features emitted until the returned
+// function is called.
+func (w *apiWriter) pushScope(name string) func() {
+	w.scope = append(w.scope, name)
+	return func() { w.scope = w.scope[:len(w.scope)-1] }
+}
+
+func (w *apiWriter) emitObj(obj types.Object) {
+	switch obj := obj.(type) {
+	case *types.Const:
+		w.emitf("const %s %s", obj.Name(), w.typeString(obj.Type()))
+	case *types.Var:
+		w.emitf("var %s %s", obj.Name(), w.typeString(obj.Type()))
+	case *types.TypeName:
+		w.emitType(obj)
+	case *types.Func:
+		w.emitf("func %s%s", obj.Name(), w.signatureString(obj.Type().(*types.Signature)))
+	}
+}
+
+func (w *apiWriter) emitType(obj *types.TypeName) {
+	name, typ := obj.Name(), obj.Type()
+	if obj.IsAlias() {
+		w.emitf("type %s = %s", name, w.typeString(typ))
+		return
+	}
+	switch u := typ.Underlying().(type) {
+	case *types.Struct:
+		w.emitStructType(name, u)
+	case *types.Interface:
+		// The methods of interfaces are emitted by emitIfaceType.
+		w.emitIfaceType(name, u)
+		return
+	default:
+		w.emitf("type %s %s", name, w.typeString(u))
+	}
+	// Emit the methods with value receivers, followed by the methods with
+	// pointer receivers.
+	emitted := make(map[string]bool)
+	for _, t := range []types.Type{typ, types.NewPointer(typ)} {
+		mset := types.NewMethodSet(t)
+		for i := 0; i < mset.Len(); i++ {
+			m := mset.At(i)
+			if name := m.Obj().Name(); m.Obj().Exported() && !emitted[name] {
+				emitted[name] = true
+				sig := m.Type().(*types.Signature)
+				w.emitf("method (%s) %s%s", w.typeString(sig.Recv().Type()), name, w.signatureString(sig))
+			}
+		}
+	}
+}
+
+func (w *apiWriter) emitStructType(name string, typ *types.Struct) {
+	typeStruct := fmt.Sprintf("type %s struct", name)
+	w.emitf("%s", typeStruct)
+	defer w.pushScope(typeStruct)()
+	for i := 0; i < typ.NumFields(); i++ {
+		f := typ.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		if f.Anonymous() {
+			w.emitf("embedded %s", w.typeString(f.Type()))
+			continue
+		}
+		w.emitf("%s %s", f.Name(), w.typeString(f.Type()))
+	}
+}
+
+func (w *apiWriter) emitIfaceType(name string, typ *types.Interface) {
+	pop := w.pushScope("type " + name + " interface")
+	var methodNames []string
+	complete := true
+	mset := types.NewMethodSet(typ)
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj().(*types.Func)
+		if !m.Exported() {
+			complete = false
+			continue
+		}
+		methodNames = append(methodNames, m.Name())
+		w.emitf("%s%s", m.Name(), w.signatureString(m.Type().(*types.Signature)))
+	}
+	if !complete {
+		// Interfaces with unexported methods can only be implemented by the
+		// package itself, so only record that there are unexported methods,
+		// rather than the full list of methods.
+		w.emitf("unexported methods")
+	}
+	pop()
+	if !complete {
+		return
+	}
+	if len(methodNames) == 0 {
+		w.emitf("type %s interface {}", name)
+		return
+	}
+	sort.Strings(methodNames)
+	w.emitf("type %s interface { %s }", name, strings.Join(methodNames, ", "))
+}
+
+func (w *apiWriter) typeString(typ types.Type) string {
+	var buf bytes.Buffer
+	w.writeType(&buf, typ)
+	return buf.String()
+}
+
+func (w *apiWriter) signatureString(sig *types.Signature) string {
+	var buf bytes.Buffer
+	w.writeSignature(&buf, sig)
+	return buf.String()
+}
+
+func (w *apiWriter) writeType(buf *bytes.Buffer, typ types.Type) {
+	switch typ := typ.(type) {
+	case *types.Basic:
+		s := typ.Name()
+		switch typ.Kind() {
+		case types.UnsafePointer:
+			s = "unsafe.Pointer"
+		case types.UntypedBool:
+			s = "ideal-bool"
+		case types.UntypedInt:
+			s = "ideal-int"
+		case types.UntypedRune:
+			s = "ideal-char"
+		case types.UntypedFloat:
+			s = "ideal-float"
+		case types.UntypedComplex:
+			s = "ideal-complex"
+		case types.UntypedString:
+			s = "ideal-string"
+		default:
+			switch s {
+			case "byte":
+				s = "uint8"
+			case "rune":
+				s = "int32"
+			}
+		}
+		buf.WriteString(s)
+	case *types.Array:
+		fmt.Fprintf(buf, "[%d]", typ.Len())
+		w.writeType(buf, typ.Elem())
+	case *types.Slice:
+		buf.WriteString("[]")
+		w.writeType(buf, typ.Elem())
+	case *types.Struct:
+		// Only the exported fields are part of the public API.
+		buf.WriteString("struct{")
+		sep := ""
+		for i := 0; i < typ.NumFields(); i++ {
+			field := typ.Field(i)
+			if !field.Exported() {
+				continue
+			}
+			buf.WriteString(sep)
+			sep = "; "
+			if !field.Embedded() {
+				buf.WriteString(field.Name() + " ")
+			}
+			w.writeType(buf, field.Type())
+		}
+		buf.WriteString("}")
+	case *types.Pointer:
+		buf.WriteByte('*')
+		w.writeType(buf, typ.Elem())
+	case *types.Signature:
+		buf.WriteString("func")
+		w.writeSignature(buf, typ)
+	case *types.Interface:
+		buf.WriteString("interface{")
+		if typ.NumMethods() > 0 {
+			var names []string
+			for i := 0; i < typ.NumMethods(); i++ {
+				names = append(names, typ.Method(i).Name())
+			}
+			sort.Strings(names)
+			buf.WriteString(" " + strings.Join(names, ", ") + " ")
+		}
+		buf.WriteString("}")
+	case *types.Map:
+		buf.WriteString("map[")
+		w.writeType(buf, typ.Key())
+		buf.WriteByte(']')
+		w.writeType(buf, typ.Elem())
+	case *types.Chan:
+		switch typ.Dir() {
+		case types.SendOnly:
+			buf.WriteString("chan<- ")
+		case types.RecvOnly:
+			buf.WriteString("<-chan ")
+		default:
+			buf.WriteString("chan ")
+		}
+		w.writeType(buf, typ.Elem())
+	case *types.Alias:
+		w.writeType(buf, types.Unalias(typ))
+	case *types.Named:
+		if pkg := typ.Obj().Pkg(); pkg != nil && pkg != w.pkg {
+			buf.WriteString(pkg.Name())
+			buf.WriteByte('.')
+		}
+		buf.WriteString(typ.Obj().Name())
+		if args := typ.TypeArgs(); args.Len() > 0 {
+			buf.WriteByte('[')
+			for i := 0; i < args.Len(); i++ {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				w.writeType(buf, args.At(i))
+			}
+			buf.WriteByte(']')
+		}
+	case *types.TypeParam:
+		buf.WriteString(typ.Obj().Name())
+	default:
+		buf.WriteString(types.TypeString(typ, w.qualifier))
+	}
+}
+
+// qualifier qualifies the types declared by packages other than w.pkg by the
+// names of those packages.
+func (w *apiWriter) qualifier(pkg *types.Package) string {
+	if pkg == w.pkg {
+		return ""
+	}
+	return pkg.Name()
+}
+
+func (w *apiWriter) writeSignature(buf *bytes.Buffer, sig *types.Signature) {
+	w.writeParams(buf, sig.Params(), sig.Variadic())
+	switch res := sig.Results(); res.Len() {
+	case 0:
+	case 1:
+		buf.WriteByte(' ')
+		w.writeType(buf, res.At(0).Type())
+	default:
+		buf.WriteByte(' ')
+		w.writeParams(buf, res, false)
+	}
+}
+
+func (w *apiWriter) writeParams(buf *bytes.Buffer, t *types.Tuple, variadic bool) {
+	buf.WriteByte('(')
+	for i := 0; i < t.Len(); i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		typ := t.At(i).Type()
+		if variadic && i+1 == t.Len() {
+			buf.WriteString("...")
+			typ = typ.(*types.Slice).Elem()
+		}
+		w.writeType(buf, typ)
+	}
+	buf.WriteByte(')')
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+const apiTestSource = `package p
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	C      = 10
+	D byte = 'a'
+	E      = "e"
+	e      = 1
+)
+
+var V, W []*T
+
+type T struct {
+	io.Reader
+	F   map[string]time.Duration
+	G   func(a, b int) (n int, err error)
+	h   int
+	S   struct {
+		X, y int
+		io.Writer
+	}
+}
+
+func (T) M(s ...string) bool { return false }
+func (*T) N() (int, error)   { return 0, nil }
+func (*T) n()                {}
+
+type I interface {
+	io.Closer
+	M(chan<- rune) <-chan struct{}
+}
+
+type J interface {
+	j()
+}
+
+type K int
+
+func F(int, string) {}
+func f()           {}
+`
+
+const apiTestWant = `pkg p, const C ideal-int
+pkg p, const D uint8
+pkg p, const E ideal-string
+pkg p, func F(int, string)
+pkg p, method (*T) N() (int, error)
+pkg p, method (T) M(...string) bool
+pkg p, method (T) Read([]uint8) (int, error)
+pkg p, type I interface { Close, M }
+pkg p, type I interface, Close() error
+pkg p, type I interface, M(chan<- int32) <-chan struct{}
+pkg p, type J interface, unexported methods
+pkg p, type K int
+pkg p, type T struct
+pkg p, type T struct, F map[string]time.Duration
+pkg p, type T struct, G func(int, int) (int, error)
+pkg p, type T struct, S struct{X int; io.Writer}
+pkg p, type T struct, embedded io.Reader
+pkg p, var V []*T
+pkg p, var W []*T
+`
+
+func TestPackageAPI(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", apiTestSource, 0)
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	config := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := config.Check("p", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if got, want := string(packageAPI(pkg)), apiTestWant; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
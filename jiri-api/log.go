@@ -0,0 +1,154 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/gitutil"
+	"v.io/jiri/project"
+	"v.io/x/lib/cmdline"
+)
+
+// cmdAPILog represents the "jiri api log" command.
+var cmdAPILog = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runAPILog),
+	Name:   "log",
+	Short:  "Show the history of the public API of a package",
+	Long: `
+Show the history of the public API of a package, i.e. the commits that changed
+the package's .api file, oldest first, along with the entries that each commit
+added, removed or changed.  Commits that only reformat the .api file are
+omitted.
+`,
+	ArgsName: "<package>",
+	ArgsLong: "<package> is the import path of the package.",
+}
+
+// apiCommit is a commit that changed the public API of a package.
+type apiCommit struct {
+	hash    string
+	date    string
+	author  string
+	subject string
+	changes []apiChange
+}
+
+func runAPILog(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("unexpected number of arguments: got %v, want 1", len(args))
+	}
+	if formatFlag != formatText && formatFlag != formatJSON {
+		return jirix.UsageErrorf("unknown format %q", formatFlag)
+	}
+	root, file, err := findAPIFile(jirix, args[0])
+	if err != nil {
+		return err
+	}
+	commits, err := apiHistory(jirix, root, file)
+	if err != nil {
+		return err
+	}
+	if formatFlag == formatJSON {
+		return printCommitsJSON(jirix.Stdout(), commits)
+	}
+	printCommits(jirix.Stdout(), commits)
+	return nil
+}
+
+// findAPIFile returns the root directory of the project that contains the
+// package with the given import path, and the path of the package's .api file
+// relative to that directory.
+func findAPIFile(jirix *jiri.X, pkg string) (string, string, error) {
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return "", "", err
+	}
+	for _, p := range projects {
+		files, err := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(p.Path)).TrackedFiles()
+		if err != nil {
+			return "", "", err
+		}
+		for _, file := range files {
+			dir := filepath.Dir(file)
+			if packageName(filepath.Join(p.Path, dir)) == pkg {
+				return p.Path, filepath.Join(dir, ".api"), nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("package %s not found in any project", pkg)
+}
+
+// apiHistory returns the commits of the git repository at root that changed
+// the public API recorded in the given .api file, oldest first.
+func apiHistory(jirix *jiri.X, root, file string) ([]apiCommit, error) {
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, nil).Last("git", "-C", root, "log", "--reverse", "--date=short", "--format=%H%x00%ad%x00%an%x00%s", "--", file); err != nil {
+		return nil, err
+	}
+	var commits []apiCommit
+	var prev []byte
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log output: %q", line)
+		}
+		// The .api file doesn't exist at commits that removed it, in which
+		// case the public API is empty.
+		var content bytes.Buffer
+		if err := jirix.NewSeq().Capture(&content, ioutil.Discard).Last("git", "-C", root, "show", fields[0]+":"+filepath.ToSlash(file)); err != nil {
+			content.Reset()
+		}
+		if changes := diffAPI(prev, content.Bytes()); len(changes) > 0 {
+			commits = append(commits, apiCommit{
+				hash:    fields[0],
+				date:    fields[1],
+				author:  fields[2],
+				subject: fields[3],
+				changes: changes,
+			})
+		}
+		prev = content.Bytes()
+	}
+	return commits, nil
+}
+
+// printCommits prints the commits that changed the public API in a
+// human-readable form.
+func printCommits(w io.Writer, commits []apiCommit) {
+	for i, commit := range commits {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		hash := commit.hash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Fprintf(w, "%s %s %s: %s\n", hash, commit.date, commit.author, commit.subject)
+		for _, c := range commit.changes {
+			compatibility := "compatible"
+			if c.breaking {
+				compatibility = "breaking"
+			}
+			switch c.kind {
+			case removedEntry:
+				fmt.Fprintf(w, "\t%v (%s): %s\n", c.kind, compatibility, c.old)
+			case changedEntry:
+				fmt.Fprintf(w, "\t%v (%s): %s => %s\n", c.kind, compatibility, c.old, c.new)
+			case addedEntry:
+				fmt.Fprintf(w, "\t%v (%s): %s\n", c.kind, compatibility, c.new)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"v.io/jiri/gitutil"
+)
+
+// TestAPILog checks that the history of the public API of a package is
+// reconstructed from the commits that changed its .api file.
+func TestAPILog(t *testing.T) {
+	fake, cleanup := setupAPITest(t)
+	defer cleanup()
+	projectPath := filepath.Join(fake.X.Root, "test")
+	git := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(projectPath))
+	apiFile := filepath.Join(projectPath, ".api")
+
+	for _, commit := range []struct{ api, message string }{
+		{"pkg main, func F(int)\n", "Add F"},
+		{"# A comment.\npkg main, func F(a int)\n", "Reformat F"},
+		{"pkg main, func F(int)\npkg main, func G()\n", "Add G"},
+		{"pkg main, func G()\n", "Remove F"},
+	} {
+		writeFileOrDie(t, fake.X, apiFile, commit.api)
+		if err := git.CommitFile(".api", commit.message); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	commits, err := apiHistory(fake.X, projectPath, ".api")
+	if err != nil {
+		t.Fatalf("apiHistory failed: %v", err)
+	}
+	var subjects []string
+	for _, commit := range commits {
+		subjects = append(subjects, commit.subject)
+	}
+	if got, want := strings.Join(subjects, ","), "Add F,Add G,Remove F"; got != want {
+		t.Fatalf("got commits %v, want %v", got, want)
+	}
+	if got, want := commits[2].changes, []apiChange{{kind: removedEntry, old: "pkg main, func F(int)", breaking: true}}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got changes %#v, want %#v", got, want)
+	}
+
+	var out bytes.Buffer
+	if err := printCommitsJSON(&out, commits); err != nil {
+		t.Fatalf("printCommitsJSON failed: %v", err)
+	}
+	if got, want := out.String(), `"kind": "removed"`; !strings.Contains(got, want) {
+		t.Errorf("got %s, want it to contain %s", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// jsonChange is the JSON representation of an apiChange.
+type jsonChange struct {
+	Kind     string `json:"kind"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	Breaking bool   `json:"breaking"`
+}
+
+func newJSONChanges(changes []apiChange) []jsonChange {
+	result := []jsonChange{}
+	for _, c := range changes {
+		result = append(result, jsonChange{
+			Kind:     c.kind.String(),
+			Old:      c.old,
+			New:      c.new,
+			Breaking: c.breaking,
+		})
+	}
+	return result
+}
+
+// jsonPackageChange is the JSON representation of a packageChange.
+type jsonPackageChange struct {
+	Project  string       `json:"project"`
+	Package  string       `json:"package"`
+	APIFile  string       `json:"apiFile"`
+	Error    string       `json:"error,omitempty"`
+	Breaking bool         `json:"breaking"`
+	Changes  []jsonChange `json:"changes"`
+}
+
+// printJSON prints v to w as indented JSON.
+func printJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// printPackageChangesJSON prints the package changes to w as JSON.  Packages
+// whose .api files only differ from their public API in formatting are
+// omitted, as they are by the text output.
+func printPackageChangesJSON(w io.Writer, changes []packageChange) error {
+	result := []jsonPackageChange{}
+	for _, change := range changes {
+		if change.apiFileError == nil && len(change.changes) == 0 {
+			continue
+		}
+		c := jsonPackageChange{
+			Project:  change.projectName,
+			Package:  change.name,
+			APIFile:  change.apiFilePath,
+			Breaking: change.isBreaking(),
+			Changes:  newJSONChanges(change.changes),
+		}
+		if change.apiFileError != nil {
+			c.Error = change.apiFileError.Error()
+		}
+		result = append(result, c)
+	}
+	return printJSON(w, result)
+}
+
+// jsonCommit is the JSON representation of an apiCommit.
+type jsonCommit struct {
+	Commit  string       `json:"commit"`
+	Date    string       `json:"date"`
+	Author  string       `json:"author"`
+	Subject string       `json:"subject"`
+	Changes []jsonChange `json:"changes"`
+}
+
+// printCommitsJSON prints the commits that changed the public API to w as
+// JSON.
+func printCommitsJSON(w io.Writer, commits []apiCommit) error {
+	result := []jsonCommit{}
+	for _, commit := range commits {
+		result = append(result, jsonCommit{
+			Commit:  commit.hash,
+			Date:    commit.date,
+			Author:  commit.author,
+			Subject: commit.subject,
+			Changes: newJSONChanges(commit.changes),
+		})
+	}
+	return printJSON(w, result)
+}
@@ -3,8 +3,6 @@
 // license that can be found in the LICENSE file.
 
 // TODO(jsimsa):
-// - Add support for shell files without the .sh suffix.
-// - Add support for Makefiles.
 // - Decide what to do with the contents of the testdata directory.
 
 // The following enables go generate to generate the doc.go file.
@@ -20,9 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 
 	"v.io/jiri"
 	"v.io/jiri/collect"
@@ -34,7 +30,12 @@ import (
 	"v.io/x/lib/cmdline"
 )
 
+var (
+	noticeFlag string
+)
+
 func init() {
+	cmdCopyrightInventory.Flags.StringVar(&noticeFlag, "notice", "", "If set, the path of the notice file to generate, which lists the third-party dependencies along with the texts of their licenses.")
 	tool.InitializeProjectFlags(&cmdCopyright.Flags)
 	tool.InitializeRunFlags(&cmdCopyright.Flags)
 }
@@ -45,14 +46,9 @@ const (
 	jiriIgnore      = ".jiriignore"
 )
 
-var (
-	copyrightRE = regexp.MustCompile(`^Copyright [[:digit:]]* The Vanadium Authors. All rights reserved.
-Use of this source code is governed by a BSD-style
-license that can be found in the LICENSE file.
-$`)
-)
-
 type copyrightAssets struct {
+	// Copyright is the template of the copyright header of projects that
+	// don't have a copyright configuration.
 	Copyright        string
 	MatchFiles       map[string]string
 	MatchPrefixFiles map[string]string
@@ -63,6 +59,9 @@ type languageSpec struct {
 	CommentSuffix string
 	Interpreters  map[string]struct{}
 	FileExtension string
+	// FileNames lists the names of files without the file extension,
+	// e.g. Makefile.
+	FileNames map[string]struct{}
 }
 
 var languages map[string]languageSpec = map[string]languageSpec{
@@ -79,6 +78,13 @@ var languages map[string]languageSpec = map[string]languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".dart",
 	},
+	"dockerfile": languageSpec{
+		CommentPrefix: "# ",
+		FileExtension: ".Dockerfile",
+		FileNames: map[string]struct{}{
+			"Dockerfile": struct{}{},
+		},
+	},
 	"go": languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".go",
@@ -87,6 +93,11 @@ var languages map[string]languageSpec = map[string]languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".h",
 	},
+	"html": languageSpec{
+		CommentPrefix: "<!-- ",
+		CommentSuffix: " -->",
+		FileExtension: ".html",
+	},
 	"java": languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".java",
@@ -94,11 +105,54 @@ var languages map[string]languageSpec = map[string]languageSpec{
 	"javascript": languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".js",
+		Interpreters: map[string]struct{}{
+			"node":   struct{}{},
+			"nodejs": struct{}{},
+		},
+	},
+	"kotlin": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".kt",
+	},
+	"kotlin_script": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".kts",
+	},
+	"makefile": languageSpec{
+		CommentPrefix: "# ",
+		FileExtension: ".mk",
+		FileNames: map[string]struct{}{
+			"GNUmakefile": struct{}{},
+			"Makefile":    struct{}{},
+			"makefile":    struct{}{},
+		},
+		Interpreters: map[string]struct{}{
+			"make": struct{}{},
+		},
 	},
 	"mojom": languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".mojom",
 	},
+	"objective_c": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".m",
+	},
+	"objective_cpp": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".mm",
+	},
+	"proto": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".proto",
+	},
+	"python": languageSpec{
+		CommentPrefix: "# ",
+		FileExtension: ".py",
+		Interpreters: map[string]struct{}{
+			"python": struct{}{},
+		},
+	},
 	"shell": languageSpec{
 		CommentPrefix: "# ",
 		FileExtension: ".sh",
@@ -107,10 +161,29 @@ var languages map[string]languageSpec = map[string]languageSpec{
 			"sh":   struct{}{},
 		},
 	},
+	"swift": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".swift",
+	},
+	"typescript": languageSpec{
+		CommentPrefix: "// ",
+		FileExtension: ".ts",
+		Interpreters: map[string]struct{}{
+			"ts-node": struct{}{},
+		},
+	},
 	"vdl": languageSpec{
 		CommentPrefix: "// ",
 		FileExtension: ".vdl",
 	},
+	"yaml": languageSpec{
+		CommentPrefix: "# ",
+		FileExtension: ".yaml",
+	},
+	"yml": languageSpec{
+		CommentPrefix: "# ",
+		FileExtension: ".yml",
+	},
 }
 
 // cmdCopyright represents the "jiri copyright" command.
@@ -122,14 +195,38 @@ This command can be used to check if all source code files of Vanadium
 projects contain the appropriate copyright header and also if all
 projects contains the appropriate licensing files. Optionally, the
 command can be used to fix the appropriate copyright headers and
-licensing files.
+licensing files. The command can also be used to list the licenses of
+the third-party dependencies of projects.
+
+Projects released under other licenses can add a ".jiricopyright" file that
+declares the license of the project, using its SPDX identifier, and the
+copyright holder, e.g.:
+
+  <copyright>
+    <license>Apache-2.0</license>
+    <author>The Example Authors</author>
+    <firstYear>2014</firstYear>
+  </copyright>
+
+The supported licenses are Apache-2.0, BSD-3-Clause, MIT and MPL-2.0; other
+licenses can be used by specifying the template of their copyright header using
+a <header> element. The "[YEAR]" and "[AUTHOR]" placeholders of the template
+are replaced by the current year, or the range of years starting with
+<firstYear>, and by the copyright holder. Setting <spdx> to true makes the fix
+command add SPDX headers, i.e. a copyright line followed by an "SPDX-License-
+Identifier" line, instead. SPDX headers that identify the license of the
+project are always accepted, while headers of other licenses are reported as
+conflicting, and other headers of the license of the project, e.g. with another
+copyright holder, are replaced. The licensing files of projects with a
+".jiricopyright" file are not checked, except for the presence of a LICENSE
+file.
 
 In order to ignore checked in third-party assets which have their own copyright
 and licensing headers a ".jiriignore" file can be added to a project. The
 ".jiriignore" file is expected to contain a single regular expression pattern per
 line.
 `,
-	Children: []*cmdline.Command{cmdCopyrightCheck, cmdCopyrightFix},
+	Children: []*cmdline.Command{cmdCopyrightCheck, cmdCopyrightFix, cmdCopyrightInventory},
 }
 
 // cmdCopyrightCheck represents the "jiri copyright check" command.
@@ -197,12 +294,21 @@ func copyrightHelper(jirix *jiri.X, args []string, fix bool) error {
 // createComment creates a copyright header comment out of the given
 // comment symbol and copyright header data.
 func createComment(prefix, suffix, header string) string {
-	return prefix + strings.Replace(header, "\n", suffix+"\n"+prefix, -1) + suffix + "\n\n"
+	comment := ""
+	for _, line := range strings.Split(header, "\n") {
+		if line == "" {
+			// Avoid trailing white space in empty lines.
+			comment += strings.TrimRight(prefix, " ") + strings.TrimLeft(suffix, " ") + "\n"
+		} else {
+			comment += prefix + line + suffix + "\n"
+		}
+	}
+	return comment + "\n"
 }
 
 // checkFile checks that the given file contains the appropriate
-// copyright header.
-func checkFile(jirix *jiri.X, path string, assets *copyrightAssets, fix bool) (bool, error) {
+// copyright header for the given license.
+func checkFile(jirix *jiri.X, path string, license *projectLicense, fix bool) (bool, error) {
 	// Some projects contain third-party files in a "third_party" subdir.
 	// Skip such files for the same reason that we skip the third_party project.
 	if strings.Contains(path, string(filepath.Separator)+"third_party"+string(filepath.Separator)) {
@@ -210,7 +316,7 @@ func checkFile(jirix *jiri.X, path string, assets *copyrightAssets, fix bool) (b
 	}
 
 	// Peak at the first line of the file looking for the interpreter
-	// directive (e.g. #!/bin/bash).
+	// directive (e.g. #!/bin/bash or #!/usr/bin/env python).
 	interpreter, err := detectInterpreter(jirix, path)
 	if err != nil {
 		return false, err
@@ -218,32 +324,35 @@ func checkFile(jirix *jiri.X, path string, assets *copyrightAssets, fix bool) (b
 	missingCopyright := false
 	s := jirix.NewSeq()
 	for _, lang := range languages {
-		if _, ok := lang.Interpreters[filepath.Base(interpreter)]; ok || strings.HasSuffix(path, lang.FileExtension) {
+		if lang.matches(path, interpreter) {
 			data, err := s.ReadFile(path)
 			if err != nil {
 				return false, err
 			}
-			if !hasCopyright(data, lang.CommentPrefix, lang.CommentSuffix) {
-				if fix {
-					copyright := createComment(lang.CommentPrefix, lang.CommentSuffix, assets.Copyright)
-					// Add the copyright header to the beginning of the file.
-					if interpreter != "" {
-						// Handle the interpreter directive.
-						directiveLine := hashbang + interpreter + "\n"
-						data = bytes.TrimPrefix(data, []byte(directiveLine))
-						copyright = directiveLine + copyright
-					}
-					data := append([]byte(copyright), data...)
-					info, err := s.Stat(path)
-					if err != nil {
-						return false, err
-					}
-					if err := s.WriteFile(path, data, info.Mode()).Done(); err != nil {
-						return false, err
-					}
-				} else {
-					missingCopyright = true
+			fixed, conflict := license.fixHeader(data, lang)
+			if fixed == nil {
+				continue
+			}
+			// Conflicting headers are reported even when they are fixed,
+			// since replacing them changes the license of the file.
+			if conflict != "" && conflict != license.spdx {
+				fmt.Fprintf(jirix.Stderr(), "%v copyright header conflicts with the project license: got %v, want %v\n", path, conflict, license.spdx)
+			}
+			if fix {
+				info, err := s.Stat(path)
+				if err != nil {
+					return false, err
+				}
+				if err := s.WriteFile(path, fixed, info.Mode()).Done(); err != nil {
+					return false, err
+				}
+			} else {
+				missingCopyright = true
+				switch conflict {
+				case "":
 					fmt.Fprintf(jirix.Stderr(), "%v copyright is missing\n", path)
+				case license.spdx:
+					fmt.Fprintf(jirix.Stderr(), "%v copyright header does not match the project copyright\n", path)
 				}
 			}
 		}
@@ -253,7 +362,8 @@ func checkFile(jirix *jiri.X, path string, assets *copyrightAssets, fix bool) (b
 
 // checkProject checks that the given project contains the appropriate
 // licensing files and that its source code files contain the
-// appropriate copyright header. If the fix option is set, the
+// appropriate copyright header for the license declared by the project's
+// copyright configuration. If the fix option is set, the
 // function fixes up the project. Otherwise, the function reports
 // violations to standard error output.
 func checkProject(jirix *jiri.X, project project.Project, assets *copyrightAssets, fix bool) (m bool, e error) {
@@ -292,21 +402,43 @@ func checkProject(jirix *jiri.X, project project.Project, assets *copyrightAsset
 		return missing, nil
 	}
 
-	missing := false
-	// Check the licensing files that require an exact match.
-	if missingLicense, err := check(assets.MatchFiles, bytes.Equal); err != nil {
+	config, err := readCopyrightConfig(jirix, project)
+	if err != nil {
 		return false, err
-	} else {
-		if missingLicense {
-			missing = true
-		}
+	}
+	license, err := newProjectLicense(assets, config)
+	if err != nil {
+		return false, fmt.Errorf("project %v: %v", project.Name, err)
 	}
 
-	// Check the licensing files that require a prefix match.
-	if missingLicense, err := check(assets.MatchPrefixFiles, bytes.HasPrefix); err != nil {
-		return false, err
+	missing := false
+	if config == nil {
+		// Check the licensing files that require an exact match.
+		if missingLicense, err := check(assets.MatchFiles, bytes.Equal); err != nil {
+			return false, err
+		} else {
+			if missingLicense {
+				missing = true
+			}
+		}
+
+		// Check the licensing files that require a prefix match.
+		if missingLicense, err := check(assets.MatchPrefixFiles, bytes.HasPrefix); err != nil {
+			return false, err
+		} else {
+			if missingLicense {
+				missing = true
+			}
+		}
 	} else {
-		if missingLicense {
+		// The licensing files of other licenses are maintained by the
+		// project itself, so only check that the license is present.
+		path := filepath.Join(project.Path, "LICENSE")
+		if _, err := jirix.NewSeq().Stat(path); err != nil {
+			if !runutil.IsNotExist(err) {
+				return false, err
+			}
+			fmt.Fprintf(jirix.Stderr(), "%v is missing\n", path)
 			missing = true
 		}
 	}
@@ -334,7 +466,7 @@ func checkProject(jirix *jiri.X, project project.Project, assets *copyrightAsset
 		if ignore, err := isIgnored(file, expressions); err != nil {
 			return false, err
 		} else if !ignore {
-			if missingCopyright, err := checkFile(jirix, filepath.Join(project.Path, file), assets, fix); err != nil {
+			if missingCopyright, err := checkFile(jirix, filepath.Join(project.Path, file), license, fix); err != nil {
 				return missing, err
 			} else {
 				if missingCopyright {
@@ -346,8 +478,20 @@ func checkProject(jirix *jiri.X, project project.Project, assets *copyrightAsset
 	return missing, nil
 }
 
-// detectInterpret returns the interpreter directive of the given
-// file, if it contains one.
+// matches checks whether the given file, which uses the given
+// interpreter, is written in the language.
+func (lang languageSpec) matches(path, interpreter string) bool {
+	if _, ok := lang.Interpreters[interpreter]; ok {
+		return true
+	}
+	if _, ok := lang.FileNames[filepath.Base(path)]; ok {
+		return true
+	}
+	return lang.FileExtension != "" && strings.HasSuffix(path, lang.FileExtension)
+}
+
+// detectInterpreter returns the name of the interpreter of the given
+// file (e.g. bash), if it contains an interpreter directive.
 func detectInterpreter(jirix *jiri.X, path string) (_ string, e error) {
 	file, err := jirix.NewSeq().Open(path)
 	if err != nil {
@@ -365,7 +509,7 @@ func detectInterpreter(jirix *jiri.X, path string) (_ string, e error) {
 	if err := scanner.Err(); err == nil {
 		line := scanner.Text()
 		if strings.HasPrefix(line, hashbang) {
-			return strings.TrimPrefix(line, hashbang), nil
+			return interpreterName(strings.TrimPrefix(line, hashbang)), nil
 		}
 		return "", nil
 	} else {
@@ -373,24 +517,40 @@ func detectInterpreter(jirix *jiri.X, path string) (_ string, e error) {
 	}
 }
 
-// hasCopyright checks that the given byte slice contains the
-// copyright header.
-func hasCopyright(data []byte, prefix, suffix string) bool {
-	buffer := bytes.NewBuffer(data)
-	lines, nlines := "", 0
-	for nlines < 3 {
-		line, err := buffer.ReadString('\n')
-		if err != nil {
-			break
+// interpreterName returns the name of the interpreter of the given
+// interpreter directive, without its version, e.g. "python" for
+// "/usr/bin/env -S python3 -u".
+func interpreterName(directive string) string {
+	args := strings.Fields(directive)
+	if len(args) == 0 {
+		return ""
+	}
+	if filepath.Base(args[0]) == "env" {
+		// Skip the options and the environment variables of env to
+		// find the command it runs.
+		args = args[1:]
+	loop:
+		for len(args) > 0 {
+			switch arg := args[0]; {
+			case strings.HasPrefix(arg, "--split-string="):
+				args[0] = strings.TrimPrefix(arg, "--split-string=")
+				break loop
+			case strings.HasPrefix(arg, "-S") && len(arg) > 2:
+				args[0] = strings.TrimPrefix(arg, "-S")
+				break loop
+			case (arg == "-u" || arg == "-C") && len(args) > 1:
+				// These options take an argument.
+				args = args[1:]
+			case !strings.HasPrefix(arg, "-") && !strings.Contains(arg, "="):
+				break loop
+			}
+			args = args[1:]
 		}
-		// Skip the interpreter directive (e.g. #!/bin/bash).
-		if strings.HasPrefix(line, hashbang) {
-			continue
+		if len(args) == 0 {
+			return ""
 		}
-		lines += strings.TrimSuffix(strings.TrimPrefix(line, prefix), suffix+"\n") + "\n"
-		nlines++
 	}
-	return copyrightRE.MatchString(lines)
+	return strings.TrimRight(filepath.Base(args[0]), "0123456789.")
 }
 
 // loadAssets returns an in-memory representation of the copyright
@@ -423,8 +583,7 @@ func loadAssets(jirix *jiri.X, dir string) (*copyrightAssets, error) {
 	if err != nil {
 		return nil, err
 	}
-	currentYear := strconv.Itoa(time.Now().Year())
-	result.Copyright = strings.Replace(string(bytes), "[YEAR]", currentYear, 1)
+	result.Copyright = string(bytes)
 	return &result, nil
 }
 
@@ -11,7 +11,9 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"v.io/jiri/gitutil"
 	"v.io/jiri/jiritest"
@@ -34,6 +36,11 @@ func TestCopyright(t *testing.T) {
 		t.Fatalf("%v", err)
 	}
 
+	license, err := newProjectLicense(assets, nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
 	if err := fake.CreateRemoteProject("test"); err != nil {
 		t.Fatalf("%v", err)
 	}
@@ -66,7 +73,7 @@ func TestCopyright(t *testing.T) {
 		if err := s.WriteFile(filepath.Join(projectPath, file), nil, os.FileMode(0600)).Done(); err != nil {
 			t.Fatalf("%v", err)
 		}
-		missing, err := checkFile(fake.X, filepath.Join(project.Path, file), assets, true)
+		missing, err := checkFile(fake.X, filepath.Join(project.Path, file), license, true)
 		if err != nil {
 			t.Fatalf("%v", err)
 		}
@@ -373,3 +380,155 @@ func TestCopyrightIsIgnored(t *testing.T) {
 		}
 	}
 }
+
+func TestLicenseHeaders(t *testing.T) {
+	apache, err := newProjectLicense(nil, &copyrightConfig{
+		License: "Apache-2.0",
+		Author:  "The Example Authors",
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	mit, err := newProjectLicense(nil, &copyrightConfig{
+		License:   "MIT",
+		Author:    "The Example Authors",
+		FirstYear: 2014,
+		SPDX:      true,
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	custom, err := newProjectLicense(nil, &copyrightConfig{
+		License: "Apache-2.0",
+		Author:  "The Example Authors",
+		Header:  "Copyright [YEAR] [AUTHOR]\nLicensed under the Apache License, Version 2.0.",
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	apacheHeader := expandHeader(licenseHeaders["Apache-2.0"], "The Example Authors", 0)
+	customHeader := expandHeader("Copyright [YEAR] [AUTHOR]\nLicensed under the Apache License, Version 2.0.", "The Example Authors", 0)
+	mitHeader := fmt.Sprintf("Copyright 2014-%d The Example Authors\nSPDX-License-Identifier: MIT", time.Now().Year())
+	bsdHeader := `// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+`
+	tests := []struct {
+		license  *projectLicense
+		lang     string
+		data     string
+		fixed    string
+		conflict string
+	}{
+		// Headers of the project license are accepted.
+		{apache, "go", createComment("// ", "", strings.Replace(licenseHeaders["Apache-2.0"], "[YEAR] [AUTHOR]", "2015 The Example Authors", 1)) + "package p\n", "", ""},
+		{mit, "go", "// Copyright 2015 The Example Authors\n// Use of this source code is governed by an MIT-style\n// license that can be found in the LICENSE file.\n\npackage p\n", "", ""},
+		{mit, "go", "// Copyright 2014-2015 The Example Authors\n// SPDX-License-Identifier: MIT\n\npackage p\n", "", ""},
+		// SPDX headers of the project license are accepted.
+		{apache, "go", "// Copyright 2015-2016 Someone Else\n// SPDX-License-Identifier: Apache-2.0\n\npackage p\n", "", ""},
+		{apache, "css", "/* SPDX-License-Identifier: Apache-2.0 */\n", "", ""},
+		// Missing headers are added.
+		{apache, "go", "package p\n", createComment("// ", "", apacheHeader) + "package p\n", ""},
+		{apache, "shell", "#!/bin/bash\necho\n", "#!/bin/bash\n" + createComment("# ", "", apacheHeader) + "echo\n", ""},
+		{mit, "go", "// Package p.\npackage p\n", createComment("// ", "", mitHeader) + "// Package p.\npackage p\n", ""},
+		// Conflicting headers are replaced.
+		{apache, "go", "// SPDX-License-Identifier: MIT\n\npackage p\n", createComment("// ", "", apacheHeader) + "package p\n", "MIT"},
+		{apache, "go", bsdHeader + "package p\n", createComment("// ", "", apacheHeader) + "package p\n", "BSD-3-Clause"},
+		{mit, "shell", "#!/bin/sh\n" + strings.Replace(bsdHeader, "//", "#", -1) + "echo\n", "#!/bin/sh\n" + createComment("# ", "", mitHeader) + "echo\n", "BSD-3-Clause"},
+		// Headers of the project license that don't match the header of the
+		// project are replaced.
+		{apache, "go", createComment("// ", "", strings.Replace(licenseHeaders["Apache-2.0"], "[YEAR] [AUTHOR]", "2015 Someone Else", 1)) + "package p\n", createComment("// ", "", apacheHeader) + "package p\n", "Apache-2.0"},
+		{custom, "go", createComment("// ", "", apacheHeader) + "package p\n", createComment("// ", "", customHeader) + "package p\n", "Apache-2.0"},
+		{custom, "go", createComment("// ", "", customHeader) + "package p\n", "", ""},
+		// Headers are placed after interpreter directives, XML declarations,
+		// encoding pragmas and build constraints.
+		{mit, "python", "#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\nimport os\n", "#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\n" + createComment("# ", "", mitHeader) + "import os\n", ""},
+		{mit, "python", "# vim: set fileencoding=utf-8 :\n" + createComment("# ", "", mitHeader) + "import os\n", "", ""},
+		{mit, "html", "<?xml version=\"1.0\"?>\n<html></html>\n", "<?xml version=\"1.0\"?>\n" + createComment("<!-- ", " -->", mitHeader) + "<html></html>\n", ""},
+		{mit, "go", "//go:build linux\n// +build linux\n\npackage p\n", "//go:build linux\n// +build linux\n\n" + createComment("// ", "", mitHeader) + "package p\n", ""},
+		{mit, "go", "//go:build linux\n\n" + strings.Replace(bsdHeader, "Vanadium", "Example", 1) + "package p\n", "//go:build linux\n\n" + createComment("// ", "", mitHeader) + "package p\n", "BSD-3-Clause"},
+		{mit, "go", createComment("// ", "", mitHeader) + "//go:build linux\n\npackage p\n", "", ""},
+		{mit, "shell", "#!/bin/sh", "#!/bin/sh\n" + createComment("# ", "", mitHeader), ""},
+	}
+	for _, test := range tests {
+		fixed, conflict := test.license.fixHeader([]byte(test.data), languages[test.lang])
+		if got, want := string(fixed), test.fixed; got != want {
+			t.Errorf("%q: got fixed data\n%s\nwant\n%s", test.data, got, want)
+		}
+		if got, want := conflict, test.conflict; got != want {
+			t.Errorf("%q: got conflict %q, want %q", test.data, got, want)
+		}
+	}
+}
+
+func TestCheckFileConflict(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	var errOut bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{
+		Stderr: io.MultiWriter(os.Stderr, &errOut),
+	})
+	license, err := newProjectLicense(nil, &copyrightConfig{
+		License: "MIT",
+		Author:  "The Example Authors",
+		SPDX:    true,
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	s := fake.X.NewSeq()
+	path := filepath.Join(fake.X.Root, "test.go")
+	data := "// SPDX-License-Identifier: Apache-2.0\n\npackage p\n"
+	want := fmt.Sprintf("%v copyright header conflicts with the project license: got Apache-2.0, want MIT\n", path)
+
+	// The conflict is reported both when checking and when fixing the file.
+	for _, fix := range []bool{false, true} {
+		errOut.Reset()
+		if err := s.WriteFile(path, []byte(data), os.FileMode(0600)).Done(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		missing, err := checkFile(fake.X, path, license, fix)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if got, want := missing, !fix; got != want {
+			t.Errorf("fix %v: got missing copyright %v, want %v", fix, got, want)
+		}
+		if got := errOut.String(); got != want {
+			t.Errorf("fix %v: got %q, want %q", fix, got, want)
+		}
+		fixed, err := s.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if got, want := string(fixed) != data, fix; got != want {
+			t.Errorf("fix %v: got file changed %v, want %v", fix, got, want)
+		}
+	}
+}
+
+func TestInterpreterName(t *testing.T) {
+	tests := []struct {
+		directive, name string
+	}{
+		{"", ""},
+		{"/bin/bash", "bash"},
+		{"/bin/sh -e", "sh"},
+		{"/usr/bin/python2.7", "python"},
+		{"/usr/bin/env python3", "python"},
+		{"/usr/bin/env node", "node"},
+		{"/usr/bin/env -S python3 -u", "python"},
+		{"/usr/bin/env -Snode --harmony", "node"},
+		{"/usr/bin/env --split-string=bash -e", "bash"},
+		{"/usr/bin/env -i -u HOME PATH=/bin bash", "bash"},
+		{"/usr/bin/env", ""},
+		{"/usr/bin/make -f", "make"},
+	}
+	for _, test := range tests {
+		if got, want := interpreterName(test.directive), test.name; got != want {
+			t.Errorf("interpreterName(%q): got %q, want %q", test.directive, got, want)
+		}
+	}
+}
@@ -9,7 +9,30 @@
 This command can be used to check if all source code files of Vanadium projects
 contain the appropriate copyright header and also if all projects contains the
 appropriate licensing files. Optionally, the command can be used to fix the
-appropriate copyright headers and licensing files.
+appropriate copyright headers and licensing files. The command can also be used
+to list the licenses of the third-party dependencies of projects.
+
+Projects released under other licenses can add a ".jiricopyright" file that
+declares the license of the project, using its SPDX identifier, and the
+copyright holder, e.g.:
+
+  <copyright>
+    <license>Apache-2.0</license>
+    <author>The Example Authors</author>
+    <firstYear>2014</firstYear>
+  </copyright>
+
+The supported licenses are Apache-2.0, BSD-3-Clause, MIT and MPL-2.0; other
+licenses can be used by specifying the template of their copyright header using
+a <header> element. The "[YEAR]" and "[AUTHOR]" placeholders of the template are
+replaced by the current year, or the range of years starting with <firstYear>,
+and by the copyright holder. Setting <spdx> to true makes the fix command add
+SPDX headers, i.e. a copyright line followed by an "SPDX-License-Identifier"
+line, instead. SPDX headers that identify the license of the project are always
+accepted, while headers of other licenses are reported as conflicting, and other
+headers of the license of the project, e.g. with another copyright holder, are
+replaced. The licensing files of projects with a ".jiricopyright" file are not
+checked, except for the presence of a LICENSE file.
 
 In order to ignore checked in third-party assets which have their own copyright
 and licensing headers a ".jiriignore" file can be added to a project. The
@@ -22,6 +45,7 @@ Usage:
 The jiri copyright commands are:
    check       Check copyright headers and licensing files
    fix         Fix copyright headers and licensing files
+   inventory   List the licenses of third-party dependencies
    help        Display help for commands or topics
 
 The jiri copyright flags are:
@@ -72,6 +96,39 @@ The jiri copyright fix flags are:
  -v=false
    Print verbose output.
 
+Jiri copyright inventory - List the licenses of third-party dependencies
+
+List the licenses of the third-party dependencies of projects. The dependencies
+are the subdirectories of "third_party" directories, and the files matched by
+the patterns of ".jiriignore" files, grouped by pattern. The licenses of
+dependencies are identified by the text of the license files (e.g. LICENSE or
+COPYING) found in their root directories. The recognized licenses are
+Apache-2.0, BSD-2-Clause, BSD-3-Clause, MIT, MPL-1.1, MPL-2.0, GPL-2.0, GPL-3.0,
+LGPL-2.0, LGPL-2.1, LGPL-3.0 and AGPL-3.0.
+
+The inventory is printed to standard output as JSON. The texts of the licenses
+can also be collected into a notice file, using the -notice flag.
+
+The command fails if a dependency doesn't have a license file, has a license
+that isn't recognized, or has a copyleft license (i.e. MPL, GPL, LGPL or AGPL),
+so that releases can be blocked until these dependencies are reviewed.
+
+Usage:
+   jiri copyright inventory [flags] <projects>
+
+<projects> is a list of projects to inventory.
+
+The jiri copyright inventory flags are:
+ -color=true
+   Use color to format output.
+ -manifest=
+   Name of the project manifest.
+ -notice=
+   If set, the path of the notice file to generate, which lists the third-party
+   dependencies along with the texts of their licenses.
+ -v=false
+   Print verbose output.
+
 Jiri copyright help - Display help for commands or topics
 
 Help with no args displays the usage of the parent command.
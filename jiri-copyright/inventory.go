@@ -0,0 +1,404 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/gitutil"
+	"v.io/jiri/project"
+	"v.io/x/devtools/tooldata"
+	"v.io/x/lib/cmdline"
+)
+
+const (
+	thirdParty     = "third_party"
+	unknownLicense = "unknown"
+)
+
+var (
+	// licenseFileRE matches the names of license files, e.g. LICENSE,
+	// LICENSE.txt or COPYING.
+	licenseFileRE = regexp.MustCompile(`^(?i:(LICEN[CS]E|COPYING|COPYRIGHT|UNLICENSE|MIT-LICENSE)([-._].*)?)$`)
+	// nonAlnumRE matches the text separating the words of a license.
+	nonAlnumRE = regexp.MustCompile(`[^[:alnum:]]+`)
+)
+
+// licenseFingerprint identifies a license by phrases of its text.
+type licenseFingerprint struct {
+	// id is the SPDX identifier of the license.
+	id string
+	// phrases lists the phrases that the license text contains, normalized
+	// by normalizeLicense.  The first phrase is expected near the
+	// beginning of the text.
+	phrases []string
+	// copyleft indicates that the license requires derived works to be
+	// distributed under the same license.
+	copyleft bool
+}
+
+// licenseFingerprints lists the recognized licenses.  Licenses whose texts
+// contain the phrases of other licenses, e.g. the GPL refers to the LGPL, are
+// told apart by the position of their first phrase, and the first of several
+// licenses whose first phrases are at the same position is chosen.
+var licenseFingerprints = []licenseFingerprint{
+	{
+		id:      "Apache-2.0",
+		phrases: []string{"apache license version 2 0"},
+	},
+	{
+		id:      "BSD-3-Clause",
+		phrases: []string{"redistribution and use in source and binary forms with or without modification are permitted", "neither the name of"},
+	},
+	{
+		id:      "BSD-2-Clause",
+		phrases: []string{"redistribution and use in source and binary forms with or without modification are permitted"},
+	},
+	{
+		id:      "MIT",
+		phrases: []string{"permission is hereby granted free of charge to any person obtaining a copy", "the above copyright notice and this permission notice shall be included"},
+	},
+	{
+		id:       "MPL-1.1",
+		phrases:  []string{"mozilla public license version 1 1"},
+		copyleft: true,
+	},
+	{
+		id:       "MPL-2.0",
+		phrases:  []string{"mozilla public license version 2 0"},
+		copyleft: true,
+	},
+	{
+		id:       "AGPL-3.0",
+		phrases:  []string{"gnu affero general public license version 3"},
+		copyleft: true,
+	},
+	{
+		id:       "GPL-2.0",
+		phrases:  []string{"gnu general public license version 2"},
+		copyleft: true,
+	},
+	{
+		id:       "GPL-3.0",
+		phrases:  []string{"gnu general public license version 3"},
+		copyleft: true,
+	},
+	{
+		id:       "LGPL-2.0",
+		phrases:  []string{"gnu library general public license version 2"},
+		copyleft: true,
+	},
+	{
+		id:       "LGPL-2.1",
+		phrases:  []string{"gnu lesser general public license version 2 1"},
+		copyleft: true,
+	},
+	{
+		id:       "LGPL-3.0",
+		phrases:  []string{"gnu lesser general public license version 3"},
+		copyleft: true,
+	},
+}
+
+// normalizeLicense returns the given license text in lower case, with its
+// words separated by single spaces, and surrounded by spaces, so that phrases
+// only match whole words.
+func normalizeLicense(text []byte) string {
+	return " " + strings.TrimSpace(nonAlnumRE.ReplaceAllString(strings.ToLower(string(text)), " ")) + " "
+}
+
+// identifyLicense returns the fingerprint of the license with the given text,
+// or nil if the license isn't recognized.
+func identifyLicense(text []byte) *licenseFingerprint {
+	normalized := normalizeLicense(text)
+	var result *licenseFingerprint
+	position := -1
+	for i := range licenseFingerprints {
+		fingerprint := &licenseFingerprints[i]
+		first := strings.Index(normalized, " "+fingerprint.phrases[0]+" ")
+		if first == -1 || (position != -1 && first >= position) {
+			continue
+		}
+		found := true
+		for _, phrase := range fingerprint.phrases[1:] {
+			if !strings.Contains(normalized, " "+phrase+" ") {
+				found = false
+				break
+			}
+		}
+		if found {
+			result, position = fingerprint, first
+		}
+	}
+	return result
+}
+
+// cmdCopyrightInventory represents the "jiri copyright inventory" command.
+var cmdCopyrightInventory = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runCopyrightInventory),
+	Name:   "inventory",
+	Short:  "List the licenses of third-party dependencies",
+	Long: `
+List the licenses of the third-party dependencies of projects. The dependencies
+are the subdirectories of "third_party" directories, and the files matched by
+the patterns of ".jiriignore" files, grouped by pattern. The licenses of
+dependencies are identified by the text of the license files (e.g. LICENSE or
+COPYING) found in their root directories. The recognized licenses are
+Apache-2.0, BSD-2-Clause, BSD-3-Clause, MIT, MPL-1.1, MPL-2.0, GPL-2.0,
+GPL-3.0, LGPL-2.0, LGPL-2.1, LGPL-3.0 and AGPL-3.0.
+
+The inventory is printed to standard output as JSON. The texts of the licenses
+can also be collected into a notice file, using the -notice flag.
+
+The command fails if a dependency doesn't have a license file, has a license
+that isn't recognized, or has a copyleft license (i.e. MPL, GPL, LGPL or AGPL),
+so that releases can be blocked until these dependencies are reviewed.
+`,
+	ArgsName: "<projects>",
+	ArgsLong: "<projects> is a list of projects to inventory.",
+}
+
+// dependency represents a third-party dependency of a project.
+type dependency struct {
+	Project string `json:"project"`
+	// Path is the path of the root directory of the dependency, relative
+	// to the root directory of the project, or "." if the dependency
+	// doesn't have a root directory of its own.
+	Path string `json:"path"`
+	// Pattern is the .jiriignore pattern that matches the files of the
+	// dependency, if the dependency isn't in a third_party directory.
+	Pattern  string        `json:"pattern,omitempty"`
+	Licenses []licenseFile `json:"licenses"`
+	// Problems lists the reasons for reviewing the dependency, e.g. a
+	// missing or copyleft license.
+	Problems     []string `json:"problems,omitempty"`
+	licenseFiles []string
+}
+
+// licenseFile represents a license file of a dependency.
+type licenseFile struct {
+	// File is the path of the license file, relative to the root
+	// directory of the project.
+	File     string `json:"file"`
+	License  string `json:"license"`
+	Copyleft bool   `json:"copyleft"`
+	text     []byte
+}
+
+func runCopyrightInventory(jirix *jiri.X, args []string) error {
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return err
+	}
+	projects, err := project.ParseNames(jirix, args, config.CopyrightCheckProjects())
+	if err != nil {
+		return err
+	}
+	var sorted []project.Project
+	for _, project := range projects {
+		sorted = append(sorted, project)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	deps := []*dependency{}
+	for _, project := range sorted {
+		projectDeps, err := inventoryProject(jirix, project)
+		if err != nil {
+			return err
+		}
+		deps = append(deps, projectDeps...)
+	}
+	data, err := json.MarshalIndent(deps, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+	if noticeFlag != "" {
+		var notice bytes.Buffer
+		writeNotice(&notice, deps)
+		if err := jirix.NewSeq().WriteFile(noticeFlag, notice.Bytes(), defaultFileMode).Done(); err != nil {
+			return err
+		}
+	}
+	flagged := false
+	for _, dep := range deps {
+		for _, problem := range dep.Problems {
+			fmt.Fprintf(jirix.Stderr(), "%v: %v: %v\n", dep.Project, dep.Path, problem)
+			flagged = true
+		}
+	}
+	if flagged {
+		return fmt.Errorf("third-party dependencies require review")
+	}
+	return nil
+}
+
+// inventoryProject returns the third-party dependencies of the given project,
+// along with their licenses.
+func inventoryProject(jirix *jiri.X, project project.Project) ([]*dependency, error) {
+	files, err := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(project.Path)).TrackedFiles()
+	if err != nil {
+		return nil, err
+	}
+	expressions, err := readV23Ignore(jirix, project)
+	if err != nil {
+		return nil, err
+	}
+	deps := findDependencies(files, expressions)
+	s := jirix.NewSeq()
+	for _, dep := range deps {
+		dep.Project = project.Name
+		for _, file := range dep.licenseFiles {
+			text, err := s.ReadFile(filepath.Join(project.Path, file))
+			if err != nil {
+				return nil, err
+			}
+			license := licenseFile{File: file, License: unknownLicense, text: text}
+			if fingerprint := identifyLicense(text); fingerprint != nil {
+				license.License, license.Copyleft = fingerprint.id, fingerprint.copyleft
+			}
+			dep.Licenses = append(dep.Licenses, license)
+		}
+		dep.Problems = licenseProblems(dep.Licenses)
+	}
+	return deps, nil
+}
+
+// findDependencies groups the given files of a project, which are relative to
+// the root directory of the project, into third-party dependencies.  Each
+// subdirectory of a third_party directory is a dependency, and so are the
+// files matched by each of the given .jiriignore expressions, outside of
+// these subdirectories.  The license files of a dependency are the files
+// with license file names in its root directory, or the files with license
+// file names matched by its expression if it doesn't have a root directory of
+// its own.  The dependencies are returned sorted by path and pattern.
+func findDependencies(files []string, expressions []*regexp.Regexp) []*dependency {
+	var deps []*dependency
+	thirdPartyDeps := map[string]*dependency{}
+	var other []string
+	for _, file := range files {
+		root := thirdPartyRoot(file)
+		if root == "" {
+			other = append(other, file)
+			continue
+		}
+		dep, ok := thirdPartyDeps[root]
+		if !ok {
+			dep = &dependency{Path: root, Licenses: []licenseFile{}}
+			thirdPartyDeps[root] = dep
+			deps = append(deps, dep)
+		}
+		if filepath.Dir(file) == root && licenseFileRE.MatchString(filepath.Base(file)) {
+			dep.licenseFiles = append(dep.licenseFiles, file)
+		}
+	}
+	for _, expression := range expressions {
+		var matched []string
+		for _, file := range other {
+			if expression.MatchString(file) {
+				matched = append(matched, file)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		dep := &dependency{Path: commonDir(matched), Pattern: expression.String(), Licenses: []licenseFile{}}
+		candidates := other
+		if dep.Path == "." {
+			candidates = matched
+		}
+		for _, file := range candidates {
+			if (dep.Path == "." || filepath.Dir(file) == dep.Path) && licenseFileRE.MatchString(filepath.Base(file)) {
+				dep.licenseFiles = append(dep.licenseFiles, file)
+			}
+		}
+		deps = append(deps, dep)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Path != deps[j].Path {
+			return deps[i].Path < deps[j].Path
+		}
+		return deps[i].Pattern < deps[j].Pattern
+	})
+	return deps
+}
+
+// thirdPartyRoot returns the root directory of the third-party dependency that
+// contains the given file, i.e. the subdirectory of the innermost third_party
+// directory that contains the file.  Files directly in a third_party directory,
+// e.g. a README, aren't part of any dependency it contains.  It returns "" if
+// the file isn't in a subdirectory of a third_party directory.
+func thirdPartyRoot(file string) string {
+	components := strings.Split(filepath.ToSlash(file), "/")
+	for i := len(components) - 3; i >= 0; i-- {
+		if components[i] == thirdParty {
+			return filepath.Join(components[:i+2]...)
+		}
+	}
+	return ""
+}
+
+// commonDir returns the innermost directory that contains all the given files,
+// or "." if there is none.
+func commonDir(files []string) string {
+	dir := filepath.Dir(files[0])
+	for _, file := range files[1:] {
+		for dir != "." && !strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			dir = filepath.Dir(dir)
+		}
+	}
+	return dir
+}
+
+// licenseProblems returns the reasons for reviewing a dependency with the
+// given license files.
+func licenseProblems(licenses []licenseFile) []string {
+	if len(licenses) == 0 {
+		return []string{"missing license"}
+	}
+	var problems []string
+	for _, license := range licenses {
+		switch {
+		case license.License == unknownLicense:
+			problems = append(problems, fmt.Sprintf("unrecognized license in %v", license.File))
+		case license.Copyleft:
+			problems = append(problems, fmt.Sprintf("copyleft license %v in %v", license.License, license.File))
+		}
+	}
+	return problems
+}
+
+// writeNotice writes a notice file, which lists the given dependencies along
+// with the texts of their licenses, to w.
+func writeNotice(w io.Writer, deps []*dependency) {
+	separator := strings.Repeat("=", 80)
+	fmt.Fprintf(w, "THIRD-PARTY SOFTWARE NOTICES\n\n")
+	fmt.Fprintf(w, "This file lists the third-party software included in this distribution,\n")
+	fmt.Fprintf(w, "along with the texts of their licenses.  It was generated by\n")
+	fmt.Fprintf(w, "\"jiri copyright inventory\".\n")
+	for _, dep := range deps {
+		var ids []string
+		for _, license := range dep.Licenses {
+			ids = append(ids, license.License)
+		}
+		if len(ids) == 0 {
+			ids = append(ids, unknownLicense)
+		}
+		fmt.Fprintf(w, "\n%v\n%v: %v (%v)\n%v\n", separator, dep.Project, dep.Path, strings.Join(ids, ", "), separator)
+		for _, license := range dep.Licenses {
+			fmt.Fprintf(w, "\n%s", license.text)
+			if !bytes.HasSuffix(license.text, []byte("\n")) {
+				fmt.Fprintln(w)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestIdentifyLicense(t *testing.T) {
+	vanadiumLicense, err := ioutil.ReadFile(filepath.Join("..", "tooldata", "data", "LICENSE"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	tests := []struct {
+		text string
+		id   string
+	}{
+		{string(vanadiumLicense), "BSD-3-Clause"},
+		{`Copyright (c) 2012 Someone
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions
+are met:`, "BSD-2-Clause"},
+		{`The MIT License (MIT)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), ...
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.`, "MIT"},
+		{`
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/`, "Apache-2.0"},
+		{`Mozilla Public License Version 2.0
+==================================`, "MPL-2.0"},
+		{`                    GNU GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+...
+into proprietary programs.  If your program is a subroutine library, you
+may consider it more useful to permit linking proprietary applications with
+the library.  If this is what you want to do, use the GNU Lesser General
+Public License instead of this License.`, "GPL-3.0"},
+		{`                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991`, "GPL-2.0"},
+		{`                  GNU LESSER GENERAL PUBLIC LICENSE
+                       Version 2.1, February 1999
+...
+  This license, the Lesser General Public License, applies to some
+specially designated software packages ... the GNU General Public
+License, version 2 ...`, "LGPL-2.1"},
+		{`                    GNU AFFERO GENERAL PUBLIC LICENSE
+                       Version 3, 19 November 2007`, "AGPL-3.0"},
+		{`Permission is hereby granted, free of charge, to any person obtaining a copy`, ""},
+		{"All rights reserved.", ""},
+	}
+	for _, test := range tests {
+		id := ""
+		if fingerprint := identifyLicense([]byte(test.text)); fingerprint != nil {
+			id = fingerprint.id
+		}
+		if got, want := id, test.id; got != want {
+			t.Errorf("%q: got license %q, want %q", test.text, got, want)
+		}
+	}
+}
+
+func TestFindDependencies(t *testing.T) {
+	files := []string{
+		"LICENSE",
+		"main.go",
+		"public/LICENSE",
+		"public/bundle.js",
+		"public/index.html",
+		"dist/min.js",
+		"vendor.js",
+		"LICENSE.vendor",
+		"third_party/README",
+		"third_party/foo/COPYING",
+		"third_party/foo/foo.c",
+		"third_party/foo/docs/LICENSE",
+		"third_party/foo/third_party/bar/bar.c",
+		"third_party/foo/third_party/NOTES",
+		"go/src/third_party/baz/LICENSE.txt",
+		"go/src/third_party/baz/baz.go",
+	}
+	var expressions []*regexp.Regexp
+	for _, expression := range []string{"public/bundle.*", "^dist/", "vendor", "build/"} {
+		expressions = append(expressions, regexp.MustCompile(expression))
+	}
+	type dep struct {
+		path, pattern string
+		licenseFiles  []string
+	}
+	want := []dep{
+		{".", "vendor", []string{"LICENSE.vendor"}},
+		{"dist", "^dist/", nil},
+		{"go/src/third_party/baz", "", []string{"go/src/third_party/baz/LICENSE.txt"}},
+		{"public", "public/bundle.*", []string{"public/LICENSE"}},
+		{"third_party/foo", "", []string{"third_party/foo/COPYING"}},
+		{"third_party/foo/third_party/bar", "", nil},
+	}
+	var got []dep
+	for _, d := range findDependencies(files, expressions) {
+		got = append(got, dep{d.Path, d.Pattern, d.licenseFiles})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got dependencies %v, want %v", got, want)
+	}
+}
+
+func TestLicenseProblems(t *testing.T) {
+	tests := []struct {
+		licenses []licenseFile
+		problems []string
+	}{
+		{nil, []string{"missing license"}},
+		{[]licenseFile{{File: "a/LICENSE", License: "MIT"}}, nil},
+		{[]licenseFile{{File: "a/LICENSE", License: "GPL-2.0", Copyleft: true}}, []string{"copyleft license GPL-2.0 in a/LICENSE"}},
+		{[]licenseFile{{File: "a/LICENSE", License: "MIT"}, {File: "a/COPYING", License: unknownLicense}}, []string{"unrecognized license in a/COPYING"}},
+	}
+	for _, test := range tests {
+		if got, want := licenseProblems(test.licenses), test.problems; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got problems %v, want %v", test.licenses, got, want)
+		}
+	}
+}
+
+func TestWriteNotice(t *testing.T) {
+	deps := []*dependency{
+		{
+			Project:  "p",
+			Path:     "third_party/foo",
+			Licenses: []licenseFile{{File: "third_party/foo/LICENSE", License: "MIT", text: []byte("MIT license text")}},
+		},
+		{
+			Project: "p",
+			Path:    "third_party/bar",
+		},
+	}
+	var buf bytes.Buffer
+	writeNotice(&buf, deps)
+	separator := strings.Repeat("=", 80)
+	for _, want := range []string{
+		separator + "\np: third_party/foo (MIT)\n" + separator + "\n\nMIT license text\n",
+		separator + "\np: third_party/bar (unknown)\n" + separator + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("notice %q doesn't contain %q", buf.String(), want)
+		}
+	}
+}
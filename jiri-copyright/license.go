@@ -0,0 +1,338 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/project"
+	"v.io/jiri/runutil"
+)
+
+const (
+	copyrightConfigFile = ".jiricopyright"
+	defaultAuthor       = "The Vanadium Authors"
+	defaultLicense      = "BSD-3-Clause"
+	spdxPrefix          = "SPDX-License-Identifier: "
+	xmlDeclaration      = "<?xml"
+)
+
+// licenseHeaders maps the SPDX identifiers of the supported license families
+// to the templates of their copyright headers.  The "[YEAR]" placeholder is
+// replaced by the current year, or by a range of years ending with the current
+// year, and the "[AUTHOR]" placeholder is replaced by the copyright holder.
+var licenseHeaders = map[string]string{
+	"Apache-2.0": `Copyright [YEAR] [AUTHOR]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.`,
+	"BSD-3-Clause": `Copyright [YEAR] [AUTHOR]. All rights reserved.
+Use of this source code is governed by a BSD-style
+license that can be found in the LICENSE file.`,
+	"MIT": `Copyright [YEAR] [AUTHOR]
+Use of this source code is governed by an MIT-style
+license that can be found in the LICENSE file.`,
+	"MPL-2.0": `This Source Code Form is subject to the terms of the Mozilla Public
+License, v. 2.0. If a copy of the MPL was not distributed with this
+file, You can obtain one at https://mozilla.org/MPL/2.0/.`,
+}
+
+var (
+	// licenseHeaderREs maps the SPDX identifiers of the supported license
+	// families to regular expressions that match their copyright headers,
+	// regardless of the copyright holder.
+	licenseHeaderREs = map[string]*regexp.Regexp{}
+	// buildConstraintRE matches the build constraints of Go files.
+	buildConstraintRE = regexp.MustCompile(`^//( \+build |go:build )`)
+	// encodingRE matches encoding pragmas, as specified by PEP 263.
+	encodingRE = regexp.MustCompile(`^[ \t\f]*#.*?coding[:=][ \t]*[-_.a-zA-Z0-9]+`)
+	// spdxRE matches SPDX headers, i.e. an SPDX-License-Identifier line,
+	// optionally preceded by a copyright line.
+	spdxRE = regexp.MustCompile(`^(?:Copyright ` + yearRE + ` .*\n)?` + spdxPrefix + `[[:space:]]*(.*?)[[:space:]]*\n`)
+)
+
+// yearRE matches a year or a range of years.
+const yearRE = `[[:digit:]]*(?:-[[:digit:]]+)?`
+
+func init() {
+	for id, header := range licenseHeaders {
+		licenseHeaderREs[id] = headerRE(header, "")
+	}
+}
+
+// headerRE returns a regular expression that matches the copyright header
+// described by the given template, with any year or range of years, and with
+// the given author, or any author if author is empty.
+func headerRE(template, author string) *regexp.Regexp {
+	authorRE := ".+"
+	if author != "" {
+		authorRE = regexp.QuoteMeta(author)
+	}
+	re := regexp.QuoteMeta(template)
+	re = strings.Replace(re, regexp.QuoteMeta("[YEAR]"), yearRE, -1)
+	re = strings.Replace(re, regexp.QuoteMeta("[AUTHOR]"), authorRE, -1)
+	return regexp.MustCompile("^" + re + "\n")
+}
+
+// copyrightConfig represents the copyright configuration of a project, which
+// is read from the ".jiricopyright" file of the project, e.g.
+//
+//	<copyright>
+//	  <license>Apache-2.0</license>
+//	  <author>The Example Authors</author>
+//	  <firstYear>2014</firstYear>
+//	</copyright>
+type copyrightConfig struct {
+	// License is the SPDX identifier of the license of the project.
+	License string `xml:"license"`
+	// Author is the copyright holder, which replaces the "[AUTHOR]"
+	// placeholder of the header template.
+	Author string `xml:"author"`
+	// FirstYear, if set, is the first year of the range of years that
+	// replaces the "[YEAR]" placeholder of the header template.
+	FirstYear int `xml:"firstYear"`
+	// Header, if set, is the header template to use instead of the template
+	// of the license family.
+	Header string `xml:"header"`
+	// SPDX indicates that "jiri copyright fix" should add SPDX headers, i.e.
+	// a copyright line followed by an SPDX-License-Identifier line, rather
+	// than the header template.
+	SPDX    bool     `xml:"spdx"`
+	XMLName xml.Name `xml:"copyright"`
+}
+
+// readCopyrightConfig reads the copyright configuration of the given
+// project.  It returns nil if the project doesn't have one.
+func readCopyrightConfig(jirix *jiri.X, project project.Project) (*copyrightConfig, error) {
+	path := filepath.Join(project.Path, copyrightConfigFile)
+	data, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var config copyrightConfig
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", path, err)
+	}
+	return &config, nil
+}
+
+// projectLicense describes the license of a project and the copyright
+// headers that its source files are expected to contain.
+type projectLicense struct {
+	// spdx is the SPDX identifier of the license.
+	spdx string
+	// header is the header added by "jiri copyright fix".
+	header string
+	// accepted matches the headers accepted by "jiri copyright check",
+	// besides the SPDX headers of the license.
+	accepted []*regexp.Regexp
+}
+
+// newProjectLicense returns the license described by the given copyright
+// configuration.  Projects without a configuration use the Vanadium license
+// and the header template found in the copyright assets.
+func newProjectLicense(assets *copyrightAssets, config *copyrightConfig) (*projectLicense, error) {
+	if config == nil {
+		config = &copyrightConfig{
+			License: defaultLicense,
+			Author:  defaultAuthor,
+			Header:  assets.Copyright,
+		}
+	}
+	if config.License == "" {
+		return nil, fmt.Errorf("%v: no license specified", copyrightConfigFile)
+	}
+	if config.Author == "" {
+		return nil, fmt.Errorf("%v: no author specified", copyrightConfigFile)
+	}
+	template := strings.TrimSpace(config.Header)
+	if template == "" {
+		var ok bool
+		if template, ok = licenseHeaders[config.License]; !ok {
+			return nil, fmt.Errorf("%v: no header template for license %q", copyrightConfigFile, config.License)
+		}
+	}
+	license := &projectLicense{
+		spdx:     config.License,
+		header:   expandHeader(template, config.Author, config.FirstYear),
+		accepted: []*regexp.Regexp{headerRE(template, config.Author)},
+	}
+	if config.SPDX {
+		spdxTemplate := "Copyright [YEAR] [AUTHOR]\n" + spdxPrefix + config.License
+		license.header = expandHeader(spdxTemplate, config.Author, config.FirstYear)
+	}
+	return license, nil
+}
+
+// expandHeader replaces the placeholders of the given header template.  The
+// year is the current year, or the range from firstYear to the current year if
+// firstYear is set and earlier than the current year.
+func expandHeader(template, author string, firstYear int) string {
+	year := strconv.Itoa(time.Now().Year())
+	if firstYear != 0 && firstYear < time.Now().Year() {
+		year = strconv.Itoa(firstYear) + "-" + year
+	}
+	header := strings.Replace(template, "[YEAR]", year, -1)
+	return strings.Replace(header, "[AUTHOR]", author, -1)
+}
+
+// matchHeader checks the given comment, i.e. the leading comment block of a
+// source file with the comment symbols removed, against the copyright headers
+// of the license.  It returns the number of lines of the copyright header the
+// comment starts with, or 0 if there is none, and the SPDX identifier of the
+// license of the header if the header must be replaced, i.e. if it conflicts
+// with the license, or if it is a header of the license family that isn't
+// accepted, e.g. because of its copyright holder.
+func (l *projectLicense) matchHeader(comment string) (int, string) {
+	for _, re := range l.accepted {
+		if m := re.FindString(comment); m != "" {
+			return strings.Count(m, "\n"), ""
+		}
+	}
+	// SPDX headers are equivalent to the header of the license they
+	// identify.
+	if m := spdxRE.FindStringSubmatch(comment); m != nil {
+		if m[1] == l.spdx {
+			return strings.Count(m[0], "\n"), ""
+		}
+		return strings.Count(m[0], "\n"), m[1]
+	}
+	var ids []string
+	for id := range licenseHeaderREs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if m := licenseHeaderREs[id].FindString(comment); m != "" {
+			return strings.Count(m, "\n"), id
+		}
+	}
+	return 0, ""
+}
+
+// fixHeader checks the copyright header of the given contents of a source file
+// written in the given language.  If the header is missing or must be
+// replaced, it returns the contents with the header added or replaced, along
+// with the SPDX identifier of the license of the replaced header, if any.
+// Otherwise, it returns nil.
+func (l *projectLicense) fixHeader(data []byte, lang languageSpec) ([]byte, string) {
+	preamble, rest := splitPreamble(data)
+	comment, ends := leadingComment(rest, lang.CommentPrefix, lang.CommentSuffix)
+	n, conflict := l.matchHeader(comment)
+	if n > 0 && conflict == "" {
+		return nil, ""
+	}
+	if n > 0 {
+		// Remove the replaced header, along with the empty line that
+		// separates it from the rest of the file.
+		rest = rest[ends[n-1]:]
+		if bytes.HasPrefix(rest, []byte("\n")) {
+			rest = rest[1:]
+		}
+	}
+	var result []byte
+	result = append(result, preamble...)
+	result = append(result, createComment(lang.CommentPrefix, lang.CommentSuffix, l.header)...)
+	return append(result, rest...), conflict
+}
+
+// splitPreamble splits the given contents of a source file into the lines that
+// must precede the copyright header and the rest of the file.  These lines are
+// the interpreter directive (e.g. #!/bin/bash) or the XML declaration, the
+// encoding pragma (e.g. # -*- coding: utf-8 -*-), which must be on the first
+// or second line, and the build constraints of Go files, along with the empty
+// lines that follow them.
+func splitPreamble(data []byte) ([]byte, []byte) {
+	// nextLine returns the line of data that starts at the given offset and
+	// the offset of its end.
+	nextLine := func(start int) (string, int) {
+		if i := bytes.IndexByte(data[start:], '\n'); i != -1 {
+			return string(data[start : start+i+1]), start + i + 1
+		}
+		return string(data[start:]), len(data)
+	}
+	end := 0
+	if line, next := nextLine(end); strings.HasPrefix(line, hashbang) || strings.HasPrefix(line, xmlDeclaration) {
+		end = next
+	}
+	if line, next := nextLine(end); encodingRE.MatchString(line) {
+		end = next
+	}
+	if line, _ := nextLine(end); buildConstraintRE.MatchString(line) {
+		for end < len(data) {
+			line, next := nextLine(end)
+			if !buildConstraintRE.MatchString(line) && strings.TrimSpace(line) != "" {
+				break
+			}
+			end = next
+		}
+	}
+	if end == 0 {
+		return nil, data
+	}
+	if data[end-1] != '\n' {
+		return append(append([]byte{}, data[:end]...), '\n'), nil
+	}
+	return data[:end], data[end:]
+}
+
+// leadingComment returns the lines of the comment block that the given data
+// starts with, with the comment symbols removed, along with the offsets of the
+// ends of these lines in data.
+func leadingComment(data []byte, prefix, suffix string) (string, []int) {
+	var comment bytes.Buffer
+	var ends []int
+	for start := 0; start < len(data); {
+		end := len(data)
+		if i := bytes.IndexByte(data[start:], '\n'); i != -1 {
+			end = start + i + 1
+		}
+		line, ok := uncomment(string(data[start:end]), prefix, suffix)
+		if !ok {
+			break
+		}
+		comment.WriteString(line + "\n")
+		ends = append(ends, end)
+		start = end
+	}
+	return comment.String(), ends
+}
+
+// uncomment removes the given comment symbols from the given line.  It returns
+// false if the line isn't a comment.
+func uncomment(line, prefix, suffix string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	// Empty comment lines don't have the white space of the comment symbols.
+	prefix, suffix = strings.TrimRight(prefix, " "), strings.TrimLeft(suffix, " ")
+	if len(line) < len(prefix)+len(suffix) || !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, suffix) {
+		return "", false
+	}
+	line = strings.TrimPrefix(line[len(prefix):len(line)-len(suffix)], " ")
+	if suffix != "" {
+		line = strings.TrimSuffix(line, " ")
+	}
+	return line, true
+}
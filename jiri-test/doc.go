@@ -16,6 +16,8 @@ The jiri test commands are:
    project     Run tests for a vanadium project
    run         Run vanadium tests
    list        List vanadium tests
+   exclusions  Manage Go test exclusions
+   history     Show the history of a test
    help        Display help for commands or topics
 
 The jiri test flags are:
@@ -87,6 +89,16 @@ Usage:
 <project> identifies the project for which to run tests.
 
 The jiri test project flags are:
+ -jobs=1
+   The maximum number of tests to run concurrently. Tests that run concurrently
+   start only after the tests they depend on pass, and tests that need the same
+   resources, as configured in the tools configuration file, don't run
+   concurrently.
+ -reruns=0
+   The maximum number of times to rerun the failed Go test functions. Tests that
+   fail and then pass when rerun are reported as flaky, and the test passes on
+   retry if all of its failed tests are flaky.
+
  -color=true
    Use color to format output.
  -env=
@@ -121,6 +133,11 @@ The jiri test run flags are:
    tests. Setting this flag to 'false' may lead to faster Go builds, but it may
    also result in some source code changes not being reflected in the tests
    (e.g., if the change was made in a different Go workspace).
+ -jobs=1
+   The maximum number of tests to run concurrently. Tests that run concurrently
+   start only after the tests they depend on pass, and tests that need the same
+   resources, as configured in the tools configuration file, don't run
+   concurrently.
  -mock-file-contents=
    Colon-separated file contents to check when testing presubmit test. This flag
    is only used when running presubmit end-to-end test.
@@ -137,6 +154,10 @@ The jiri test run flags are:
    Comma-separated list of Go package expressions that identify a subset of
    tests to run; only relevant for Go-based tests. Example usage: jiri test run
    -pkgs v.io/x/ref vanadium-go-test
+ -reruns=0
+   The maximum number of times to rerun the failed Go test functions. Tests that
+   fail and then pass when rerun are reported as flaky, and the test passes on
+   retry if all of its failed tests are flaky.
  -v23.namespace.root=/ns.dev.v.io:8101
    The namespace root.
 
@@ -182,6 +203,184 @@ The jiri test list flags are:
  -v=false
    Print verbose output.
 
+Jiri test exclusions - Manage Go test exclusions
+
+Manages the Go tests that are excluded from test runs. The exclusions are stored
+in the exclusions file of the tools data directory.
+
+Usage:
+   jiri test exclusions [flags] <command>
+
+The jiri test exclusions commands are:
+   list        List Go test exclusions
+   add         Add a Go test exclusion
+   expire      Remove Go test exclusions
+
+The jiri test exclusions flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test exclusions list - List Go test exclusions
+
+Lists the Go test exclusions and whether they expired.
+
+Usage:
+   jiri test exclusions list [flags]
+
+The jiri test exclusions list flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test exclusions add - Add a Go test exclusion
+
+Adds an exclusion of the Go tests that match the given test regular expression,
+in the packages that match the given package regular expression. Use the
+-expires flag to quarantine the tests for a limited time.
+
+Usage:
+   jiri test exclusions add [flags] <package> <test>
+
+<package> is a regular expression that matches the import paths of the packages
+of the excluded tests.
+
+<test> is a regular expression that matches the names of the excluded tests.
+
+The jiri test exclusions add flags are:
+ -arch=
+   The GOARCH the exclusion is restricted to.
+ -ci=false
+   Restrict the exclusion to continuous integration machines.
+ -comment=
+   Why the tests are excluded.
+ -expires=
+   The last day, in the YYYY-MM-DD format, the exclusion applies. Expired
+   exclusions no longer exclude tests and are reported as failures.
+ -issue=
+   The issue that tracks the exclusion.
+ -mode=
+   Restrict the exclusion to integration tests ("integration") or to tests run
+   with the race detector ("race"). By default, the exclusion applies to all
+   other tests, including the tests run with the race detector.
+ -os=
+   The GOOS the exclusion is restricted to.
+ -owner=
+   The person responsible for the exclusion.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test exclusions expire - Remove Go test exclusions
+
+Removes the exclusions with the given package and test regular expressions, so
+that the excluded tests run again. If no arguments are given, removes all
+expired exclusions.
+
+Usage:
+   jiri test exclusions expire [flags] [<package> <test>]
+
+<package> and <test> are the package and test regular expressions of the
+exclusions to remove, as printed by "jiri test exclusions list".
+
+The jiri test exclusions expire flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test history - Show the history of a test
+
+Shows the pass rate, the flakiness score and the duration trend of a test,
+followed by its most recent runs. The history is recorded on the local machine
+by "jiri test run".
+
+The flakiness score is the fraction of the runs that either passed on retry or
+had a different outcome than the previous run of the same jiri test at the same
+checked out revisions of the projects. Runs against projects with uncommitted
+changes are not compared. The duration trend compares the mean duration of the
+older half of the runs with the one of the more recent half.
+
+Usage:
+   jiri test history [flags] <test|package.TestFunc>
+
+<test|package.TestFunc> is the name of a jiri test, e.g. vanadium-go-test, or
+the import path of a Go package followed by the name of a test function, e.g.
+v.io/x/ref/lib/vdl/build.TestBuildPackage.
+
+The jiri test history flags are:
+ -runs=10
+   The number of most recent runs to list.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
 Jiri test help - Display help for commands or topics
 
 Help with no args displays the usage of the parent command.
@@ -0,0 +1,179 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/tooldata"
+	"v.io/x/lib/cmdline"
+)
+
+var (
+	exclusionArchFlag    string
+	exclusionCIFlag      bool
+	exclusionCommentFlag string
+	exclusionExpiresFlag string
+	exclusionIssueFlag   string
+	exclusionModeFlag    string
+	exclusionOSFlag      string
+	exclusionOwnerFlag   string
+)
+
+func init() {
+	cmdExclusionsAdd.Flags.StringVar(&exclusionArchFlag, "arch", "", "The GOARCH the exclusion is restricted to.")
+	cmdExclusionsAdd.Flags.BoolVar(&exclusionCIFlag, "ci", false, "Restrict the exclusion to continuous integration machines.")
+	cmdExclusionsAdd.Flags.StringVar(&exclusionCommentFlag, "comment", "", "Why the tests are excluded.")
+	cmdExclusionsAdd.Flags.StringVar(&exclusionExpiresFlag, "expires", "", "The last day, in the YYYY-MM-DD format, the exclusion applies. Expired exclusions no longer exclude tests and are reported as failures.")
+	cmdExclusionsAdd.Flags.StringVar(&exclusionIssueFlag, "issue", "", "The issue that tracks the exclusion.")
+	cmdExclusionsAdd.Flags.StringVar(&exclusionModeFlag, "mode", "", `Restrict the exclusion to integration tests ("integration") or to tests run with the race detector ("race"). By default, the exclusion applies to all other tests, including the tests run with the race detector.`)
+	cmdExclusionsAdd.Flags.StringVar(&exclusionOSFlag, "os", "", "The GOOS the exclusion is restricted to.")
+	cmdExclusionsAdd.Flags.StringVar(&exclusionOwnerFlag, "owner", "", "The person responsible for the exclusion.")
+}
+
+// cmdExclusions represents the "jiri test exclusions" command.
+var cmdExclusions = &cmdline.Command{
+	Name:  "exclusions",
+	Short: "Manage Go test exclusions",
+	Long: `
+Manages the Go tests that are excluded from test runs. The exclusions are
+stored in the exclusions file of the tools data directory.
+`,
+	Children: []*cmdline.Command{cmdExclusionsList, cmdExclusionsAdd, cmdExclusionsExpire},
+}
+
+// cmdExclusionsList represents the "jiri test exclusions list" command.
+var cmdExclusionsList = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runExclusionsList),
+	Name:   "list",
+	Short:  "List Go test exclusions",
+	Long:   "Lists the Go test exclusions and whether they expired.",
+}
+
+func runExclusionsList(jirix *jiri.X, _ []string) error {
+	exclusions, err := tooldata.LoadExclusions(jirix)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, e := range exclusions {
+		printExclusion(jirix.Stdout(), e, now)
+	}
+	return nil
+}
+
+// printExclusion prints the given exclusion, followed by its
+// conditions and details.
+func printExclusion(w io.Writer, e tooldata.Exclusion, now time.Time) {
+	fmt.Fprintf(w, "%v %v\n", e.Package, e.Test)
+	for _, field := range []struct{ name, value string }{
+		{"mode", e.Mode},
+		{"os", e.OS},
+		{"arch", e.Arch},
+		{"owner", e.Owner},
+		{"issue", e.Issue},
+		{"expires", e.Expires},
+		{"comment", e.Comment},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(w, "  %v: %v\n", field.name, field.value)
+		}
+	}
+	if e.CI {
+		fmt.Fprintf(w, "  ci: true\n")
+	}
+	if e.Expired(now) {
+		fmt.Fprintf(w, "  EXPIRED\n")
+	}
+}
+
+// cmdExclusionsAdd represents the "jiri test exclusions add" command.
+var cmdExclusionsAdd = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runExclusionsAdd),
+	Name:   "add",
+	Short:  "Add a Go test exclusion",
+	Long: `
+Adds an exclusion of the Go tests that match the given test regular
+expression, in the packages that match the given package regular
+expression. Use the -expires flag to quarantine the tests for a limited
+time.
+`,
+	ArgsName: "<package> <test>",
+	ArgsLong: `
+<package> is a regular expression that matches the import paths of the
+packages of the excluded tests.
+
+<test> is a regular expression that matches the names of the excluded tests.
+`,
+}
+
+func runExclusionsAdd(jirix *jiri.X, args []string) error {
+	if len(args) != 2 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	exclusions, err := tooldata.LoadExclusions(jirix)
+	if err != nil {
+		return err
+	}
+	e := tooldata.Exclusion{
+		Package: args[0],
+		Test:    args[1],
+		Mode:    exclusionModeFlag,
+		OS:      exclusionOSFlag,
+		Arch:    exclusionArchFlag,
+		CI:      exclusionCIFlag,
+		Owner:   exclusionOwnerFlag,
+		Issue:   exclusionIssueFlag,
+		Expires: exclusionExpiresFlag,
+		Comment: exclusionCommentFlag,
+	}
+	return tooldata.SaveExclusions(jirix, append(exclusions, e))
+}
+
+// cmdExclusionsExpire represents the "jiri test exclusions expire" command.
+var cmdExclusionsExpire = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runExclusionsExpire),
+	Name:   "expire",
+	Short:  "Remove Go test exclusions",
+	Long: `
+Removes the exclusions with the given package and test regular expressions,
+so that the excluded tests run again. If no arguments are given, removes all
+expired exclusions.
+`,
+	ArgsName: "[<package> <test>]",
+	ArgsLong: `
+<package> and <test> are the package and test regular expressions of the
+exclusions to remove, as printed by "jiri test exclusions list".
+`,
+}
+
+func runExclusionsExpire(jirix *jiri.X, args []string) error {
+	if len(args) != 0 && len(args) != 2 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	exclusions, err := tooldata.LoadExclusions(jirix)
+	if err != nil {
+		return err
+	}
+	now, remaining := time.Now(), []tooldata.Exclusion{}
+	for _, e := range exclusions {
+		if len(args) == 0 && e.Expired(now) || len(args) == 2 && e.Package == args[0] && e.Test == args[1] {
+			fmt.Fprintf(jirix.Stdout(), "Removing exclusion:\n")
+			printExclusion(jirix.Stdout(), e, now)
+			continue
+		}
+		remaining = append(remaining, e)
+	}
+	if len(remaining) == len(exclusions) {
+		if len(args) == 2 {
+			return fmt.Errorf("no exclusion of tests %q in packages %q", args[1], args[0])
+		}
+		return nil
+	}
+	return tooldata.SaveExclusions(jirix, remaining)
+}
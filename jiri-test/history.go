@@ -0,0 +1,88 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/history"
+	"v.io/x/devtools/tooldata"
+	"v.io/x/lib/cmdline"
+)
+
+var historyRunsFlag int
+
+func init() {
+	cmdHistory.Flags.IntVar(&historyRunsFlag, "runs", 10, "The number of most recent runs to list.")
+}
+
+// cmdHistory represents the "jiri test history" command.
+var cmdHistory = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runHistory),
+	Name:   "history",
+	Short:  "Show the history of a test",
+	Long: `
+Shows the pass rate, the flakiness score and the duration trend of a test,
+followed by its most recent runs. The history is recorded on the local
+machine by "jiri test run".
+
+The flakiness score is the fraction of the runs that either passed on retry
+or had a different outcome than the previous run of the same jiri test at
+the same checked out revisions of the projects. Runs against projects with
+uncommitted changes are not compared. The duration trend compares the mean
+duration of the older half of the runs with the one of the more recent half.
+`,
+	ArgsName: "<test|package.TestFunc>",
+	ArgsLong: `
+<test|package.TestFunc> is the name of a jiri test, e.g. vanadium-go-test,
+or the import path of a Go package followed by the name of a test function,
+e.g. v.io/x/ref/lib/vdl/build.TestBuildPackage.
+`,
+}
+
+func runHistory(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	records, err := history.Load(jirix, tooldata.TestHistoryFilePath(jirix))
+	if err != nil {
+		return err
+	}
+	records = history.Select(records, args[0])
+	if len(records) == 0 {
+		return fmt.Errorf("no history of test %v", args[0])
+	}
+	printHistory(jirix.Stdout(), records, historyRunsFlag)
+	return nil
+}
+
+// printHistory prints the summary of the given records of a test,
+// followed by the given number of most recent records.
+func printHistory(w io.Writer, records []history.Record, runs int) {
+	// Round the durations to make them readable.
+	round := func(d time.Duration) time.Duration { return d - d%(10*time.Millisecond) }
+	s := history.Summarize(records)
+	fmt.Fprintf(w, "Runs: %d\n", s.Runs)
+	fmt.Fprintf(w, "Pass rate: %.1f%%\n", 100*s.PassRate())
+	fmt.Fprintf(w, "Flakiness score: %.2f\n", s.FlakinessScore())
+	fmt.Fprintf(w, "Duration: %v (older runs) -> %v (recent runs)", round(s.OlderDuration), round(s.RecentDuration))
+	if s.OlderDuration > 0 {
+		fmt.Fprintf(w, " %+.1f%%", 100*(float64(s.RecentDuration)/float64(s.OlderDuration)-1))
+	}
+	fmt.Fprintf(w, "\n")
+	if runs > len(records) {
+		runs = len(records)
+	}
+	if runs <= 0 {
+		return
+	}
+	fmt.Fprintf(w, "Recent runs:\n")
+	for _, r := range records[len(records)-runs:] {
+		fmt.Fprintf(w, "  %s %-15s %-12v snapshot=%s host=%s\n", r.Time.Format("2006-01-02 15:04:05"), r.Status, round(r.Duration), r.Snapshot, r.Host)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"go/ast"
 	"go/build"
@@ -39,6 +38,7 @@ import (
 	"v.io/x/devtools/internal/goutil"
 	"v.io/x/devtools/internal/test"
 	"v.io/x/devtools/internal/xunit"
+	apiexitcode "v.io/x/devtools/jiri-api/exitcode"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/devtools/vbinary/exitcode"
 	"v.io/x/lib/host"
@@ -55,11 +55,6 @@ const (
 	testTimedout
 )
 
-const (
-	escNewline = "&#xA;"
-	escTab     = "&#x9;"
-)
-
 const timeoutDelay = 2 * time.Minute
 
 type buildResult struct {
@@ -90,6 +85,7 @@ type nonTestArgsOpt []string
 type numWorkersOpt int
 type suppressTestOutputOpt bool
 type pkgsOpt []string
+type rerunsOpt int
 type suffixOpt string
 type timeoutOpt string
 
@@ -107,6 +103,7 @@ func (numWorkersOpt) goTestOpt()         {}
 func (pkgsOpt) goBuildOpt()              {}
 func (pkgsOpt) goCoverageOpt()           {}
 func (pkgsOpt) goTestOpt()               {}
+func (rerunsOpt) goTestOpt()             {}
 func (suffixOpt) goTestOpt()             {}
 func (timeoutOpt) goCoverageOpt()        {}
 func (timeoutOpt) goTestOpt()            {}
@@ -271,7 +268,7 @@ func goCoverage(jirix *jiri.X, testName string, opts ...goCoverageOpt) (_ *test.
 	// Install required tools.
 	goInstall := []string{"go"}
 	goInstall = append(goInstall, goFlags...)
-	goInstall = append(goInstall, "install", "golang.org/x/tools/cmd/cover", "github.com/t-yuki/gocover-cobertura", "github.com/tebeka/go2xunit")
+	goInstall = append(goInstall, "install", "golang.org/x/tools/cmd/cover", "github.com/t-yuki/gocover-cobertura")
 	if err := s.Last("jiri", goInstall...); err != nil {
 		return nil, newInternalError(err, "install coverage tools")
 	}
@@ -322,7 +319,7 @@ func goCoverage(jirix *jiri.X, testName string, opts ...goCoverageOpt) (_ *test.
 		var s *xunit.TestSuite
 		switch result.status {
 		case buildFailed:
-			s = xunit.CreateTestSuiteWithFailure(result.pkg, "TestCoverage", "build failure", result.output, result.time)
+			s = xunit.CreateTestSuiteWithFailure(result.pkg, "TestCoverage", "build failure", xunit.TextFromGoTestOutput(result.output), result.time)
 		case testPassed:
 			data, err := ioutil.ReadAll(result.coverage)
 			if err != nil {
@@ -337,19 +334,14 @@ func goCoverage(jirix *jiri.X, testName string, opts ...goCoverageOpt) (_ *test.
 			fallthrough
 		case testFailed:
 			if strings.Index(result.output, "no test files") == -1 {
-				ss, err := xunit.TestSuitesFromGoTestOutput(jirix, bytes.NewBufferString(result.output))
+				ss, err := xunit.TestSuitesFromGoTestOutput(bytes.NewBufferString(result.output))
 				if err != nil {
-					// Token too long error.
-					if !strings.HasSuffix(err.Error(), "token too long") {
-						return nil, err
-					}
-					s = xunit.CreateTestSuiteWithFailure(result.pkg, "Test", "test output contains lines that are too long to parse", "", result.time)
-				} else {
-					if len(ss) > 1 {
-						return nil, fmt.Errorf("too many testsuites: %d", len(ss))
-					}
-					s = ss[0]
+					return nil, err
 				}
+				if len(ss) != 1 {
+					return nil, fmt.Errorf("unexpected number of testsuites: %d", len(ss))
+				}
+				s = ss[0]
 			}
 		}
 		if result.coverage != nil {
@@ -361,7 +353,7 @@ func goCoverage(jirix *jiri.X, testName string, opts ...goCoverageOpt) (_ *test.
 		if s != nil {
 			if s.Failures > 0 {
 				allPassed = false
-				test.Fail(jirix.Context, "%s\n%v\n", result.pkg, result.output)
+				test.Fail(jirix.Context, "%s\n%v\n", result.pkg, xunit.TextFromGoTestOutput(result.output))
 			} else {
 				test.Pass(jirix.Context, "%s\n", result.pkg)
 			}
@@ -398,7 +390,7 @@ func coverageWorker(jirix *jiri.X, timeout string, args []string, pkgs <-chan st
 			panic(fmt.Sprintf("TempFile() failed: %v", err))
 		}
 		args := append([]string{"go", "test", "-tags=leveldb", "-cover", "-coverprofile",
-			coverageFile.Name(), "-timeout", timeout, "-v",
+			coverageFile.Name(), "-timeout", timeout, "-json",
 		}, args...)
 		args = append(args, pkg)
 		start := time.Now()
@@ -612,6 +604,7 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 	numWorkers := runtime.GOMAXPROCS(0)
 	var nonTestArgs nonTestArgsOpt
 	suppressOutput := false
+	reruns := 0
 	for _, opt := range opts {
 		switch typedOpt := opt.(type) {
 		case timeoutOpt:
@@ -630,6 +623,8 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 			pkgs = []string(typedOpt)
 		case suppressTestOutputOpt:
 			suppressOutput = bool(typedOpt)
+		case rerunsOpt:
+			reruns = int(typedOpt)
 		case numWorkersOpt:
 			numWorkers = int(typedOpt)
 			if numWorkers < 1 {
@@ -640,16 +635,6 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 		}
 	}
 
-	// TODO(cnicolaou): this gets run for every test case, which is going
-	// to be pretty slow. We should refactor so that it only gets run once.
-	// Install required tools.
-	goInstall := []string{"go"}
-	goInstall = append(goInstall, goFlags...)
-	goInstall = append(goInstall, "install", "github.com/tebeka/go2xunit")
-	if err := jirix.NewSeq().Last("jiri", goInstall...); err != nil {
-		return nil, nil, newInternalError(err, "install-go2xunit")
-	}
-
 	// Build dependencies of test packages.
 	if err := buildTestDeps(jirix, pkgs, goFlags); err != nil {
 		originalTestName := testName
@@ -717,54 +702,36 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 	// skippedTests are a result of testing.Skip calls in the actual
 	// tests.
 	skippedTests := map[string][]string{}
+	// flakyTests are the failed tests that passed when rerun.
+	flakyTests := map[string][]string{}
 	allPassed, suites := true, []xunit.TestSuite{}
 	for i := 0; i < numPkgs; i++ {
 		result := <-taskResults
 		var ss []*xunit.TestSuite
 		switch result.status {
 		case buildFailed:
-			ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", "build failure", result.output, result.time))
+			ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", "build failure", xunit.TextFromGoTestOutput(result.output), result.time))
 		case testTimedout:
 			ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", fmt.Sprintf("test timed out after %s", timeout), "", result.time))
 		case testFailed, testPassed:
 			if strings.Index(result.output, "no test files") == -1 &&
 				strings.Index(result.output, "package excluded") == -1 {
 				if testName == "vanadium-go-bench" {
-					// TODO(jsimsa): The go2xunit tool used for parsing output
-					// of Go tests ignores output of Go benchmarks. We dump
-					// output of benchmarks to stdout to persist this
-					// information in the console logs of our CI. This is a
-					// temporary solution until someone finds the enthusiasm to
-					// implement benchmark output parsing, tracking and
-					// graphing.
-					fmt.Fprintf(jirix.Stdout(), result.output)
+					// TODO(jsimsa): The output of Go benchmarks is not
+					// parsed. We dump output of benchmarks to stdout to
+					// persist this information in the console logs of our
+					// CI. This is a temporary solution until someone finds
+					// the enthusiasm to implement benchmark output
+					// parsing, tracking and graphing.
+					fmt.Fprintf(jirix.Stdout(), "%s", xunit.TextFromGoTestOutput(result.output))
 				}
-				// Escape test output to make sure go2xunit can process it.
-				var escapedOutput bytes.Buffer
-				if err := xml.EscapeText(&escapedOutput, []byte(result.output)); err != nil {
-					msg := fmt.Sprintf("failed to escape test output:\n%s\n", result.output)
-					ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", msg, "", result.time))
-				} else {
-					// xml.EscapeTest also escapes newlines and tabs.
-					// We want to keep them unescaped so that go2xunit can correctly parse
-					// the output.
-					output := strings.Replace(escapedOutput.String(), escNewline, "\n", -1)
-					output = strings.Replace(output, escTab, "\t", -1)
-					var err error
-					if ss, err = xunit.TestSuitesFromGoTestOutput(jirix, bytes.NewBufferString(output)); err != nil {
-						errMsg := ""
-						if strings.Contains(err.Error(), "package build failed") {
-							// Package build failure.
-							errMsg = "failed to build package"
-						} else if strings.HasSuffix(err.Error(), "token too long") {
-							// Token too long error.
-							errMsg = "test output contains lines that are too long to parse"
-						}
-						if errMsg != "" {
-							ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", errMsg, output, result.time))
-						} else {
-							return nil, suites, fmt.Errorf("%s: got error %q running go2xunit on test output %q", result.pkg, err, result.output)
-						}
+				var err error
+				if ss, err = xunit.TestSuitesFromGoTestOutput(bytes.NewBufferString(result.output)); err != nil {
+					return nil, suites, fmt.Errorf("%s: failed to parse test output: %v", result.pkg, err)
+				}
+				if result.status == testFailed && reruns > 0 {
+					if flaky := rerunFailedTests(jirix, timeout, args, nonTestArgs, result.pkg, pkgAndFuncList[result.pkg], ss, reruns); len(flaky) > 0 {
+						flakyTests[result.pkg] = flaky
 					}
 				}
 				for _, ts := range ss {
@@ -787,14 +754,14 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 			}
 			// There are times, generally when running tests that fail from
 			// within tests that expect those failures, that we want to
-			// supress the output from the test to prevent other tools (e.g.
-			// go2xunit from seeing it).
+			// supress the output from the test to prevent other tools from
+			// seeing it.
 			if !suppressOutput {
 				if s.Failures > 0 {
 					if result.status == testTimedout {
 						test.Fail(jirix.Context, "[TIMED OUT after %s] %s\n", timeout, result.pkg)
 					} else {
-						test.Fail(jirix.Context, "%s\n%v\n", result.pkg, result.output)
+						test.Fail(jirix.Context, "%s\n%v\n", result.pkg, xunit.TextFromGoTestOutput(result.output))
 					}
 				} else {
 					test.Pass(jirix.Context, "%s\n", result.pkg)
@@ -802,6 +769,9 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 				if s.Skip > 0 {
 					test.Pass(jirix.Context, "%s (skipped tests: %v)\n", result.pkg, skippedTests[result.pkg])
 				}
+				if flaky := flakyTests[result.pkg]; flaky != nil {
+					test.Warn(jirix.Context, "%s (flaky tests: %v)\n", result.pkg, flaky)
+				}
 			}
 			newCases := []xunit.TestCase{}
 			for _, c := range s.Cases {
@@ -819,10 +789,26 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 	}
 	close(taskResults)
 
+	// Report the expired exclusions of the tested packages.
+	for _, s := range expiredExclusionSuites(exclusions, pkgList) {
+		allPassed = false
+		if !suppressOutput {
+			test.Fail(jirix.Context, "%s\n%v\n", s.Name, s.Cases[0].Failures[0].Data)
+		}
+		if len(suffix) != 0 {
+			s.Cases[0].Name += " " + suffix
+		}
+		suites = append(suites, *s)
+	}
+
 	testResult := &test.Result{
 		Status:        test.Passed,
 		ExcludedTests: excludedTests,
 		SkippedTests:  skippedTests,
+		FlakyTests:    flakyTests,
+	}
+	if len(flakyTests) > 0 {
+		testResult.Status = test.PassedOnRetry
 	}
 	if !allPassed {
 		// We don't set testResult.Status to TimedOut when any pkgs timed out so
@@ -836,72 +822,147 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 	return testResult, suites, nil
 }
 
-// testWorker tests packages.
-func testWorker(jirix *jiri.X, timeout string, args, nonTestArgs []string, tasks <-chan goTestTask, results chan<- testResult) {
-	s := jirix.NewSeq()
-	for task := range tasks {
-		// Run the test.
-		//
-		// The "leveldb" tag is needed to compile the levelDB-based
-		// storage engine for the groups service. See v.io/i/632 for more
-		// details.
-		taskArgs := append([]string{"go", "test", "-tags=leveldb", "-timeout", timeout, "-v"}, args...)
-
-		// Use the -run command-line flag to identify the specific tests to run.
-		// If this flag is already set, make sure to override it.
-		testsExpr := fmt.Sprintf("^(%s)$", strings.Join(task.specificTests, "|"))
-		found := false
-		for i, arg := range taskArgs {
-			switch {
-			case arg == "-run" || arg == "--run":
-				taskArgs[i+1] = testsExpr
-				found = true
-				break
-			case strings.HasPrefix(arg, "-run=") || strings.HasPrefix(arg, "--run="):
-				taskArgs[i] = fmt.Sprintf("-run=%s", testsExpr)
-				found = true
-				break
+// rerunFailedTests reruns the failed test functions of the given
+// package up to the given number of times, until they pass. The failures
+// of the test functions that pass when rerun are recorded as flaky in
+// the given test suites, whereas the failed reruns of the other test
+// functions are recorded next to their failures. The function returns
+// the names of the test functions that passed when rerun.
+func rerunFailedTests(jirix *jiri.X, timeout string, args, nonTestArgs []string, pkg string, funcs []string, suites []*xunit.TestSuite, reruns int) []string {
+	// failed maps the failed test functions to their failed test cases,
+	// which include the test cases of their subtests. Failures that
+	// can't be attributed to a test function, e.g. failures of TestMain,
+	// are not rerun.
+	failed, isFunc := map[string][]*xunit.TestCase{}, set.String.FromSlice(funcs)
+	for _, s := range suites {
+		for i := range s.Cases {
+			c := &s.Cases[i]
+			name := strings.SplitN(c.Name, "/", 2)[0]
+			if _, ok := isFunc[name]; ok && len(c.Failures) > 0 {
+				failed[name] = append(failed[name], c)
 			}
 		}
-		if !found {
-			taskArgs = append(taskArgs, "-run", testsExpr)
+	}
+	flaky := []string{}
+	for i := 0; i < reruns && len(failed) > 0; i++ {
+		names := []string{}
+		for name := range failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(jirix.Stdout(), "rerunning failed tests of %s (%d/%d): %v\n", pkg, i+1, reruns, names)
+		result := runGoTestTask(jirix, timeout, args, nonTestArgs, goTestTask{pkg: pkg, specificTests: names})
+		rerunCases := map[string]xunit.TestCase{}
+		if result.status == testFailed || result.status == testPassed {
+			if rerunSuites, err := xunit.TestSuitesFromGoTestOutput(bytes.NewBufferString(result.output)); err == nil {
+				for _, s := range rerunSuites {
+					for _, c := range s.Cases {
+						rerunCases[c.Name] = c
+					}
+				}
+			}
 		}
-
-		taskArgs = append(taskArgs, task.pkg)
-		taskArgs = append(taskArgs, nonTestArgs...)
-		var out bytes.Buffer
-		start := time.Now()
-		timeoutDuration, err := time.ParseDuration(timeout)
-		if err != nil {
-			results <- testResult{
-				status:   testFailed,
-				pkg:      task.pkg,
-				output:   fmt.Sprintf("time.ParseDuration(%s) failed: %v", timeout, err),
-				excluded: task.excludedTests,
+		for _, name := range names {
+			if rerunCase, ok := rerunCases[name]; ok && len(rerunCase.Failures) == 0 && len(rerunCase.Skipped) == 0 {
+				for _, c := range failed[name] {
+					c.FlakyFailures = append(c.Failures, c.RerunFailures...)
+					c.Failures, c.RerunFailures = nil, nil
+				}
+				flaky = append(flaky, name)
+				delete(failed, name)
+				continue
+			}
+			for _, c := range failed[name] {
+				failure := xunit.Failure{Message: "rerun failure", Data: xunit.TextFromGoTestOutput(result.output)}
+				if rerunCase, ok := rerunCases[c.Name]; ok && len(rerunCase.Failures) > 0 {
+					failure = rerunCase.Failures[0]
+				}
+				c.RerunFailures = append(c.RerunFailures, failure)
 			}
-			continue
 		}
-		err = s.Capture(&out, &out).Timeout(timeoutDuration+time.Minute).Verbose(false).Last("jiri", taskArgs...)
-		result := testResult{
+	}
+	for _, s := range suites {
+		s.Failures = 0
+		for _, c := range s.Cases {
+			if len(c.Failures) > 0 {
+				s.Failures++
+			}
+		}
+	}
+	sort.Strings(flaky)
+	return flaky
+}
+
+// testWorker tests packages.
+func testWorker(jirix *jiri.X, timeout string, args, nonTestArgs []string, tasks <-chan goTestTask, results chan<- testResult) {
+	for task := range tasks {
+		results <- runGoTestTask(jirix, timeout, args, nonTestArgs, task)
+	}
+}
+
+// runGoTestTask runs the tests of the given task.
+func runGoTestTask(jirix *jiri.X, timeout string, args, nonTestArgs []string, task goTestTask) testResult {
+	s := jirix.NewSeq()
+	// Run the test.
+	//
+	// The "leveldb" tag is needed to compile the levelDB-based
+	// storage engine for the groups service. See v.io/i/632 for more
+	// details.
+	taskArgs := append([]string{"go", "test", "-tags=leveldb", "-timeout", timeout, "-json"}, args...)
+
+	// Use the -run command-line flag to identify the specific tests to run.
+	// If this flag is already set, make sure to override it.
+	testsExpr := fmt.Sprintf("^(%s)$", strings.Join(task.specificTests, "|"))
+	found := false
+	for i, arg := range taskArgs {
+		switch {
+		case arg == "-run" || arg == "--run":
+			taskArgs[i+1] = testsExpr
+			found = true
+			break
+		case strings.HasPrefix(arg, "-run=") || strings.HasPrefix(arg, "--run="):
+			taskArgs[i] = fmt.Sprintf("-run=%s", testsExpr)
+			found = true
+			break
+		}
+	}
+	if !found {
+		taskArgs = append(taskArgs, "-run", testsExpr)
+	}
+
+	taskArgs = append(taskArgs, task.pkg)
+	taskArgs = append(taskArgs, nonTestArgs...)
+	var out bytes.Buffer
+	start := time.Now()
+	timeoutDuration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return testResult{
+			status:   testFailed,
 			pkg:      task.pkg,
-			time:     time.Now().Sub(start),
-			output:   out.String(),
+			output:   fmt.Sprintf("time.ParseDuration(%s) failed: %v", timeout, err),
 			excluded: task.excludedTests,
 		}
-		if err != nil {
-			oe := runutil.GetOriginalError(err)
-			if isBuildFailure(oe, out.String(), task.pkg) {
-				result.status = buildFailed
-			} else if runutil.IsTimeout(err) {
-				result.status = testTimedout
-			} else {
-				result.status = testFailed
-			}
+	}
+	err = s.Capture(&out, &out).Timeout(timeoutDuration+time.Minute).Verbose(false).Last("jiri", taskArgs...)
+	result := testResult{
+		pkg:      task.pkg,
+		time:     time.Now().Sub(start),
+		output:   out.String(),
+		excluded: task.excludedTests,
+	}
+	if err != nil {
+		oe := runutil.GetOriginalError(err)
+		if isBuildFailure(oe, out.String(), task.pkg) {
+			result.status = buildFailed
+		} else if runutil.IsTimeout(err) {
+			result.status = testTimedout
 		} else {
-			result.status = testPassed
+			result.status = testFailed
 		}
-		results <- result
+	} else {
+		result.status = testPassed
 	}
+	return result
 }
 
 // buildTestDeps builds dependencies for the given test packages
@@ -924,6 +985,11 @@ func buildTestDeps(jirix *jiri.X, pkgs []string, jiriGoFlags []string) error {
 
 // isBuildFailure checks whether the given error and output indicate a build failure for the given package.
 func isBuildFailure(err error, out, pkg string) bool {
+	// With the -json flag, build failures are reported as build-fail
+	// events.
+	if strings.Contains(out, `"Action":"build-fail"`) {
+		return true
+	}
 	if exitError, ok := err.(*exec.ExitError); ok {
 		// Try checking err's process state to determine the exit code.
 		// Exit code 2 means build failures.
@@ -981,6 +1047,11 @@ func getListenerPID(jirix *jiri.X, port string) (int, error) {
 
 type exclusion struct {
 	exclude bool
+	// expired records whether the exclusion would apply if it hadn't
+	// expired. Expired exclusions don't exclude tests and are reported
+	// as failures.
+	expired bool
+	desc    tooldata.Exclusion
 	nameRE  *regexp.Regexp
 	pkgRE   *regexp.Regexp
 }
@@ -989,131 +1060,72 @@ type exclusion struct {
 func newExclusion(pkg, name string, exclude bool) exclusion {
 	return exclusion{
 		exclude: exclude,
+		desc:    tooldata.Exclusion{Package: pkg, Test: name},
 		nameRE:  regexp.MustCompile(name),
 		pkgRE:   regexp.MustCompile(pkg),
 	}
 }
 
-var (
-	goExclusions            []exclusion
-	goRaceExclusions        []exclusion
-	goIntegrationExclusions []exclusion
-)
+// loadExclusions loads the exclusions for tests run in one of the
+// given modes (see tooldata.Exclusion) from the exclusions file.
+// Whether an exclusion applies depends on the target platform, on
+// whether the tests run on a continuous integration machine, and on
+// whether the exclusion expired.
+func loadExclusions(jirix *jiri.X, modes ...string) ([]exclusion, error) {
+	descs, err := tooldata.LoadExclusions(jirix)
+	if err != nil {
+		return nil, err
+	}
+	now, exclusions := time.Now(), []exclusion{}
+	for _, desc := range descs {
+		if !isExclusionMode(desc.Mode, modes) {
+			continue
+		}
+		applies := (desc.OS == "" || desc.OS == targetOS()) &&
+			(desc.Arch == "" || desc.Arch == targetArch()) &&
+			(!desc.CI || isCI())
+		e := newExclusion(desc.Package, desc.Test, applies)
+		e.desc = desc
+		if applies && desc.Expired(now) {
+			e.exclude, e.expired = false, true
+		}
+		exclusions = append(exclusions, e)
+	}
+	return exclusions, nil
+}
 
-func init() {
-	goExclusions = []exclusion{
-		// This test triggers a bug in go 1.4.1 garbage collector.
-		//
-		// https://github.com/veyron/release-issues/issues/1494
-		newExclusion("v.io/x/ref/runtime/internal/rpc/stream/vc", "TestConcurrentFlows", isDarwin() && is386()),
-		// TODO(jingjin): re-enable this test when the following issue is resolved.
-		// https://github.com/vanadium/issues/issues/639
-		newExclusion("v.io/x/ref/services/device", "TestV23DeviceManagerMultiUser", isDarwin()),
-		// The fsnotify package tests are flaky on darwin. This begs the
-		// question of whether we should be relying on this library at
-		// all.
-		newExclusion("github.com/howeyc/fsnotify", ".*", isDarwin()),
-		// This test relies on timing, which results in flakiness on GCE.
-		newExclusion("google.golang.org/appengine/internal", "TestDelayedLogFlushing", isCI()),
-		// This test relies on timing, which results in flakiness on GCE.
-		newExclusion("google.golang.org/cloud/bigtable", "TestClientIntegration", isCI()),
-		// This test relies on timing, which results in flakiness on GCE.
-		newExclusion("google.golang.org/cloud/pubsub", "TestKeepAliveStopsImmediatelyForNoAckIDs", isCI()),
-		// The crypto/ssh TestValidTerminalMode is flakey on Jenkins and
-		// sometimes fails when getting a pty.
-		newExclusion("golang.org/x/crypto/ssh/test", "TestValidTerminalMode", isCI()),
-		// The following tests require ICMP socket permissions which are not enabled
-		// by default on linux.
-		newExclusion("golang.org/x/net/icmp", "TestPingGoogle", isCI()),
-		newExclusion("golang.org/x/net/icmp", "TestNonPrivilegedPing", isCI()),
-		// This test has proven flaky under go1.5
-		newExclusion("golang.org/x/net/netutil", "TestLimitListener", isCI()),
-		// Don't run this test on mac systems prior to Yosemite since it
-		// can crash some machines.
-		newExclusion("golang.org/x/net/ipv6", ".*", !isYosemite()),
-		// This test fails, seemingly because of xml name space changes.
-		newExclusion("golang.org/x/net/webdav", "TestMultistatusWriter", isCI()),
-		// The following test is way out of date and doesn't work any more.
-		newExclusion("golang.org/x/tools", "TestCheck", true),
-		// The following two tests use too much memory.
-		newExclusion("golang.org/x/tools/go/loader", "TestStdlib", true),
-		newExclusion("golang.org/x/tools/go/ssa", "TestStdlib", true),
-		// The following test expects to see "FAIL: TestBar" which causes
-		// go2xunit to fail.
-		newExclusion("golang.org/x/tools/go/ssa/interp", "TestTestmainPackage", true),
-		// More broken tests.
-		//
-		// TODO(jsimsa): Provide more descriptive message.
-		newExclusion("golang.org/x/tools/go/types", "TestCheck", true),
-		newExclusion("golang.org/x/tools/refactor/lexical", "TestStdlib", true),
-		newExclusion("golang.org/x/tools/refactor/importgraph", "TestBuild", true),
-
-		// Starting an sshd server is flaky on jenkins nodes, we don't
-		// need this code, so it's fine to exclude this test. cnicolaou 12/8/15.
-		newExclusion("golang.org/x/crypto/ssh/test", "TestCertLogin", isDarwin()),
-
-		// The godoc test does some really stupid string matching where it doesn't want
-		// cmd/gc to appear, but we have v.io/x/ref/cmd/gclogs.
-		newExclusion("golang.org/x/tools/cmd/godoc", "TestWeb", true),
-		// The mysql tests require a connection to a MySQL database.
-		newExclusion("github.com/go-sql-driver/mysql", ".*", true),
-		// The gorp tests require a connection to a SQL database, configured
-		// through various environment variables.
-		newExclusion("github.com/go-gorp/gorp", ".*", true),
-		// Not working in kubernetes containers.
-		newExclusion("github.com/shirou/gopsutil/host", "TestUsers", isCI()),
-		// The features used in the following tests are not implemented on darwin.
-		newExclusion("github.com/shirou/gopsutil/disk", "TestDisk_io_counters", isDarwin()),
-		newExclusion("github.com/shirou/gopsutil/net", "TestNetProtoCountersStatsAll|TestNetProtoCountersStats|TestNetFilterCounters", isDarwin()),
-		newExclusion("github.com/shirou/gopsutil/process", "Test_Process_memory_maps|Test_Process_Terminal|Test_Process_IOCounters|Test_Process_NumCtx|Test_Process_Exe|Test_Process_CreateTime|Test_OpenFiles", isDarwin()),
-		// Not working well with go2xunit.
-		newExclusion("github.com/stretchr/testify", ".*", true),
-		// The check.v1 tests contain flakey benchmark tests which sometimes do
-		// not complete, and sometimes complete with unexpected times.
-		newExclusion("gopkg.in/check.v1", ".*", true),
-		// The tests depend on a c library.
-		newExclusion("code.google.com/p/rsc/...", ".*", true),
-	}
-
-	// Tests excluded only when running under --race flag.
-	goRaceExclusions = []exclusion{
-		// This test takes too long in --race mode.
-		newExclusion("v.io/x/devtools/v23", "TestV23Generate", true),
-		// These third_party tests are flaky on Go1.5 with -race
-		newExclusion("golang.org/x/crypto/ssh", ".*", true),
-		newExclusion("github.com/paypal/gatt", "TestServing", true),
-	}
-
-	// Tests excluded only when running integration tests (with --v23.tests flag).
-	goIntegrationExclusions = []exclusion{}
-}
-
-// ExcludedTests returns the set of tests to be excluded from the
-// tests executed when testing the Vanadium project.
-func ExcludedTests() []string {
-	return excludedTests(goExclusions)
-}
-
-// ExcludedRaceTests returns the set of race tests to be excluded from
-// the tests executed when testing the Vanadium project.
-func ExcludedRaceTests() []string {
-	return excludedTests(goRaceExclusions)
-}
-
-// ExcludedIntegrationTests returns the set of integration tests to be excluded
-// from the tests executed when testing the Vanadium project.
-func ExcludedIntegrationTests() []string {
-	return excludedTests(goIntegrationExclusions)
-}
-
-func excludedTests(exclusions []exclusion) []string {
-	excluded := make([]string, 0, len(exclusions))
+func isExclusionMode(mode string, modes []string) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// expiredExclusionSuites returns the failing test suites that report
+// the expired exclusions that match any of the given packages.
+func expiredExclusionSuites(exclusions []exclusion, pkgs []string) []*xunit.TestSuite {
+	var suites []*xunit.TestSuite
 	for _, e := range exclusions {
-		if e.exclude {
-			excluded = append(excluded, fmt.Sprintf("pkg: %v, name: %v", e.pkgRE.String(), e.nameRE.String()))
+		if !e.expired {
+			continue
+		}
+		for _, pkg := range pkgs {
+			if e.pkgRE.MatchString(pkg) {
+				output := fmt.Sprintf("The exclusion of tests %q in packages matching %q expired on %v.", e.desc.Test, e.desc.Package, e.desc.Expires)
+				if e.desc.Owner != "" {
+					output += fmt.Sprintf("\nOwner: %v", e.desc.Owner)
+				}
+				if e.desc.Issue != "" {
+					output += fmt.Sprintf("\nIssue: %v", e.desc.Issue)
+				}
+				suites = append(suites, xunit.CreateTestSuiteWithFailure(pkg, e.desc.Test, "exclusion expired", output, 0))
+				break
+			}
 		}
 	}
-	return excluded
+	return suites
 }
 
 // validateAgainstDefaultPackages makes sure that the packages requested
@@ -1174,6 +1186,17 @@ func getNumWorkersOpt(opts []Opt) numWorkersOpt {
 	return numWorkersOpt(runtime.NumCPU())
 }
 
+// getRerunsOpt gets the RerunsOpt from the given Opt slice
+func getRerunsOpt(opts []Opt) rerunsOpt {
+	for _, opt := range opts {
+		switch v := opt.(type) {
+		case RerunsOpt:
+			return rerunsOpt(v)
+		}
+	}
+	return 0
+}
+
 // getDefaultPkgsOpt gets the default packages from the given Opt slice
 func getDefaultPkgsOpt(opts []Opt) []string {
 	for _, opt := range opts {
@@ -1237,8 +1260,12 @@ func thirdPartyGoTest(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Resu
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, "")
+	if err != nil {
+		return nil, err
+	}
 	suffix := suffixOpt(genTestNameSuffix("GoTest"))
-	return goTestAndReport(jirix, testName, suffix, exclusionsOpt(goExclusions), validatedPkgs)
+	return goTestAndReport(jirix, testName, suffix, exclusionsOpt(exclusions), getRerunsOpt(opts), validatedPkgs)
 }
 
 // thirdPartyGoRace runs Go data-race tests for third-party projects.
@@ -1263,10 +1290,13 @@ func thirdPartyGoRace(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Resu
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, "", "race")
+	if err != nil {
+		return nil, err
+	}
 	args := argsOpt([]string{"-race"})
-	exclusions := append(goExclusions, goRaceExclusions...)
 	suffix := suffixOpt(genTestNameSuffix("GoRace"))
-	return goTestAndReport(jirix, testName, suffix, args, timeoutOpt("1h"), exclusionsOpt(exclusions), partPkgs)
+	return goTestAndReport(jirix, testName, suffix, args, timeoutOpt("1h"), exclusionsOpt(exclusions), getRerunsOpt(opts), partPkgs)
 }
 
 // thirdPartyPkgs returns a list of Go expressions that describe all
@@ -1322,18 +1352,15 @@ func vanadiumGoAPI(jirix *jiri.X, testName string, _ ...Opt) (_ *test.Result, e
 	}
 	defer collect.Error(func() error { return cleanup() }, &e)
 
-	// Run the jiri api check.
+	// Run the jiri api check, which fails if there are breaking changes to
+	// the public API.  Compatible changes are reported, but don't fail the
+	// check.
 	var out bytes.Buffer
 	if err := jirix.NewSeq().Capture(&out, &out).
 		Last("jiri", "api", "check"); err != nil {
-		report := fmt.Sprintf("error running 'jiri api check': %v", err)
-		if err := xunit.CreateFailureReport(jirix, testName, "RunV23API", "CheckGoAPI", "failed to run the api check tool", report); err != nil {
-			return &test.Result{Status: test.Failed}, nil
+		if !isAPICheckFailure(err) {
+			return nil, newInternalError(fmt.Errorf("failed to run the api check tool: %v\n%s", err, out.String()), "CheckGoAPI")
 		}
-	}
-
-	output := out.String()
-	if len(output) != 0 {
 		report := fmt.Sprintf(`%v
 
 If the above changes to public Go API are intentional, run "jiri api fix",
@@ -1348,6 +1375,17 @@ to update the corresponding .api files and commit the changes.
 	return &test.Result{Status: test.Passed}, nil
 }
 
+// isAPICheckFailure checks whether the given error of "jiri api check"
+// indicates that the check failed, as opposed to the check failing to run.
+func isAPICheckFailure(err error) bool {
+	if exitError, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus() == apiexitcode.APICheckFailedExitCode
+		}
+	}
+	return false
+}
+
 // vanadiumGoBench runs Go benchmarks for vanadium projects.
 func vanadiumGoBench(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result, e error) {
 	// Initialize the test.
@@ -1621,11 +1659,14 @@ func vanadiumGoRace(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result
 	if err != nil {
 		return nil, err
 	}
-	exclusions := append(goExclusions, goRaceExclusions...)
+	exclusions, err := loadExclusions(jirix, "", "race")
+	if err != nil {
+		return nil, err
+	}
 	args := argsOpt([]string{"-race"})
 	timeout := timeoutOpt("30m")
 	suffix := suffixOpt(genTestNameSuffix("GoRace"))
-	return goTestAndReport(jirix, testName, args, timeout, suffix, exclusionsOpt(exclusions), partPkgs)
+	return goTestAndReport(jirix, testName, args, timeout, suffix, exclusionsOpt(exclusions), getRerunsOpt(opts), partPkgs)
 }
 
 // identifyPackagesToTest returns a slice of packages to test using the
@@ -1752,9 +1793,13 @@ func vanadiumGoTest(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, "")
+	if err != nil {
+		return nil, err
+	}
 	args := argsOpt([]string{})
 	suffix := suffixOpt(genTestNameSuffix("GoTest"))
-	return goTestAndReport(jirix, testName, suffix, exclusionsOpt(goExclusions), getNumWorkersOpt(opts), pkgs, args)
+	return goTestAndReport(jirix, testName, suffix, exclusionsOpt(exclusions), getNumWorkersOpt(opts), getRerunsOpt(opts), pkgs, args)
 }
 
 // vanadiumIntegrationTest runs integration tests for Vanadium
@@ -1774,13 +1819,17 @@ func vanadiumIntegrationTest(jirix *jiri.X, testName string, opts ...Opt) (_ *te
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, "integration")
+	if err != nil {
+		return nil, err
+	}
 	suffix := suffixOpt(genTestNameSuffix("V23Test"))
 	nonTestArgs := nonTestArgsOpt([]string{"-v23.tests"})
 	matcher := funcMatcherOpt{&matchV23TestFunc{testNameRE: integrationTestNameRE}}
 	env := jirix.Env()
 	env["V23_BIN_DIR"] = binDirPath()
 	newCtx := jirix.Clone(tool.ContextOpts{Env: env})
-	return goTestAndReport(newCtx, testName, suffix, getNumWorkersOpt(opts), nonTestArgs, matcher, exclusionsOpt(goIntegrationExclusions), pkgs)
+	return goTestAndReport(newCtx, testName, suffix, getNumWorkersOpt(opts), nonTestArgs, matcher, exclusionsOpt(exclusions), getRerunsOpt(opts), pkgs)
 }
 
 // binOrder determines if the regression tests use
@@ -1901,6 +1950,7 @@ func vanadiumRegressionTest(jirix *jiri.X, testName string, opts ...Opt) (_ *tes
 	}
 	globalOpts := []goTestOpt{
 		getNumWorkersOpt(opts),
+		getRerunsOpt(opts),
 		nonTestArgsOpt([]string{"-v23.tests"}),
 		funcMatcherOpt{&matchV23TestFunc{testNameRE: regexp.MustCompile(config.Tests)}},
 		pkgs,
@@ -1932,7 +1982,12 @@ func vanadiumRegressionTest(jirix *jiri.X, testName string, opts ...Opt) (_ *tes
 	if err != nil {
 		return nil, err
 	}
-	out := &test.Result{Status: test.Passed}
+	out := &test.Result{
+		Status:        test.Passed,
+		ExcludedTests: map[string][]string{},
+		SkippedTests:  map[string][]string{},
+		FlakyTests:    map[string][]string{},
+	}
 	suites := []xunit.TestSuite{}
 	for _, againstDate := range config.AgainstDates {
 		againstTime := time.Time(againstDate)
@@ -1981,11 +2036,15 @@ func vanadiumRegressionTest(jirix *jiri.X, testName string, opts ...Opt) (_ *tes
 					return nil, err
 				}
 				suites = append(suites, cursuites...)
-				if result.Status != test.Passed {
+				switch {
+				case !result.Status.Succeeded():
 					out.Status = test.Failed
+				case result.Status == test.PassedOnRetry && out.Status == test.Passed:
+					out.Status = test.PassedOnRetry
 				}
 				mergeTestSet(out.ExcludedTests, result.ExcludedTests)
 				mergeTestSet(out.SkippedTests, result.SkippedTests)
+				mergeTestSet(out.FlakyTests, result.FlakyTests)
 			}
 		}
 	}
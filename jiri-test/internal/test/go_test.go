@@ -524,6 +524,75 @@ func TestGoTestExcludedPackage(t *testing.T) {
 	runGoTest(t, "", exclusions, wantExcludedPackage, test.Passed, "foo")
 }
 
+// TestGoTestWithExpiredExclusion checks that expired exclusions don't
+// exclude tests and are reported as failures.
+func TestGoTestWithExpiredExclusion(t *testing.T) {
+	e := newExclusion("v.io/x/devtools/jiri-test/internal/test/testdata/foo", ".*", false)
+	e.expired, e.desc.Expires = true, "2015-01-01"
+	jirix := newJiriXWithRealRoot(t)
+	cleanupTest, err := initTestImpl(jirix, false, false, false, "test-go-test", nil, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer cleanupTest()
+	result, suites, err := goTest(jirix, "test-go-test", pkgsOpt([]string{e.desc.Package}), exclusionsOpt([]exclusion{e}), suppressTestOutputOpt(true), skipProfiles)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := result.Status, test.Failed; got != want {
+		t.Fatalf("unexpected result: got %s, want %s", got, want)
+	}
+	if got := len(suites); got < 2 {
+		t.Fatalf("the tests of the package didn't run: got %d suites", got)
+	}
+	last := suites[len(suites)-1]
+	if got, want := last.Cases[0].Failures[0].Message, "exclusion expired"; got != want {
+		t.Fatalf("unexpected failure: got %q, want %q", got, want)
+	}
+}
+
+// TestGoTestWithReruns checks that failed tests are rerun, and that
+// the tests that pass when rerun are reported as flaky.
+func TestGoTestWithReruns(t *testing.T) {
+	jirix := newJiriXWithRealRoot(t)
+	cleanupTest, err := initTestImpl(jirix, false, false, false, "test-go-test", nil, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer cleanupTest()
+	pkgName := "v.io/x/devtools/jiri-test/internal/test/testdata/foo_flaky"
+	result, suites, err := goTest(jirix, "test-go-test", pkgsOpt([]string{pkgName}), rerunsOpt(2), suppressTestOutputOpt(true), skipProfiles)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// TestFail fails when rerun, so the test fails.
+	if got, want := result.Status, test.Failed; got != want {
+		t.Fatalf("unexpected result: got %s, want %s", got, want)
+	}
+	if got, want := result.FlakyTests, map[string][]string{pkgName: []string{"TestFlaky"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected flaky tests: got %v, want %v", got, want)
+	}
+	if got, want := len(suites), 1; got != want {
+		t.Fatalf("unexpected number of suites: got %v, want %v", got, want)
+	}
+	if got, want := suites[0].Failures, 1; got != want {
+		t.Fatalf("unexpected number of failures: got %v, want %v", got, want)
+	}
+	for _, c := range suites[0].Cases {
+		var got, want [3]int
+		got = [3]int{len(c.Failures), len(c.RerunFailures), len(c.FlakyFailures)}
+		switch c.Name {
+		case "TestFlaky":
+			want = [3]int{0, 0, 1}
+		case "TestFail":
+			want = [3]int{1, 2, 0}
+		}
+		if got != want {
+			t.Errorf("%v: got failures, rerun failures, flaky failures %v, want %v", c.Name, got, want)
+		}
+	}
+}
+
 func TestGoTestWithTimeout(t *testing.T) {
 	runGoTest(t, "", nil, wantTestWithTimeout, test.Failed, "foo_timeout", timeoutOpt("1s"))
 }
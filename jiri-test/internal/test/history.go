@@ -0,0 +1,80 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/gitutil"
+	"v.io/jiri/project"
+	"v.io/x/devtools/internal/history"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+	"v.io/x/devtools/tooldata"
+)
+
+// recordHistory records the result of the given test, which started
+// at the given time on the given snapshot, and of the test cases of its
+// xUnit report in the test history file. Failing to record the history
+// doesn't fail the test.
+func recordHistory(jirix *jiri.X, testName, snapshot string, result *test.Result, start time.Time) {
+	run := history.Record{
+		Time:     start,
+		Test:     testName,
+		Status:   result.Status,
+		Duration: time.Since(start),
+		Snapshot: snapshot,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		run.Host = hostname
+	}
+	var suites xunit.TestSuites
+	if data, err := ioutil.ReadFile(xunit.ReportPath(testName)); err == nil {
+		if err := xml.Unmarshal(data, &suites); err != nil {
+			test.Warn(jirix.Context, "Unmarshal() of the xUnit report of %v failed: %v\n", testName, err)
+		}
+	}
+	if err := history.Append(jirix, tooldata.TestHistoryFilePath(jirix), history.NewRecords(run, suites.Suites)); err != nil {
+		test.Warn(jirix.Context, "failed to record the test history: %v\n", err)
+	}
+}
+
+// snapshotID returns an identifier of the revisions checked out in
+// the local projects, or an empty string if they can't be determined.
+// The identifier ends with history.DirtySuffix if any of the projects
+// has uncommitted or untracked changes. As this inspects every project,
+// it is computed once per invocation, before running any tests.
+func snapshotID(jirix *jiri.X) string {
+	states, err := project.GetProjectStates(jirix, true)
+	if err != nil {
+		return ""
+	}
+	revisions, dirty := []string{}, false
+	for _, state := range states {
+		revision, err := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(state.Project.Path)).CurrentRevision()
+		if err != nil {
+			return ""
+		}
+		revisions = append(revisions, state.Project.Name+" "+revision+"\n")
+		dirty = dirty || state.HasUncommitted || state.HasUntracked
+	}
+	sort.Strings(revisions)
+	h := sha1.New()
+	for _, r := range revisions {
+		h.Write([]byte(r))
+	}
+	id := fmt.Sprintf("%x", h.Sum(nil))[:12]
+	if dirty {
+		id += history.DirtySuffix
+	}
+	return id
+}
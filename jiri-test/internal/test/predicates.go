@@ -6,30 +6,25 @@ package test
 
 import (
 	"os"
-	"os/exec"
 	"runtime"
-	"strings"
 )
 
-func is386() bool {
-	return runtime.GOARCH == "386" || os.Getenv("GOARCH") == "386"
-}
-
 func isCI() bool {
 	return os.Getenv("USER") == "veyron" || os.Getenv("V23_FORCE_CI") == "yes"
 }
 
-func isDarwin() bool {
-	return runtime.GOOS == "darwin"
+// targetArch returns the architecture the tests are built for.
+func targetArch() string {
+	if arch := os.Getenv("GOARCH"); arch != "" {
+		return arch
+	}
+	return runtime.GOARCH
 }
 
-func isYosemite() bool {
-	if runtime.GOOS != "darwin" {
-		return false
-	}
-	out, err := exec.Command("uname", "-a").Output()
-	if err != nil {
-		return true
+// targetOS returns the operating system the tests are built for.
+func targetOS() string {
+	if goos := os.Getenv("GOOS"); goos != "" {
+		return goos
 	}
-	return strings.Contains(string(out), "Version 14.")
+	return runtime.GOOS
 }
@@ -79,13 +79,16 @@ func vanadiumPresubmitTest(jirix *jiri.X, testName string, opts ...Opt) (_ *test
 
 	s := jirix.NewSeq()
 
-	// Get number of test workers from opts.
-	numWorkers := 1
+	// Get number of test workers and reruns from opts.
+	numWorkers, reruns := 1, 0
 	for _, opt := range opts {
 		switch typedOpt := opt.(type) {
 		case NumWorkersOpt:
 			numWorkers = int(typedOpt)
 			break
+		case RerunsOpt:
+			reruns = int(typedOpt)
+			break
 		}
 	}
 
@@ -103,6 +106,7 @@ func vanadiumPresubmitTest(jirix *jiri.X, testName string, opts ...Opt) (_ *test
 		"-num-test-workers", fmt.Sprintf("%d", numWorkers),
 		"-projects", os.Getenv("PROJECTS"),
 		"-refs", os.Getenv("REFS"),
+		"-reruns", fmt.Sprintf("%d", reruns),
 		"-test", name,
 	)
 	if err := s.Capture(jirix.Stdout(), jirix.Stderr()).Last("presubmit", args...); err != nil {
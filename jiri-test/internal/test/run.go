@@ -16,6 +16,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"v.io/jiri"
 	"v.io/jiri/collect"
@@ -194,6 +196,12 @@ type CleanGoOpt bool
 
 func (CleanGoOpt) Opt() {}
 
+// JobsOpt is an option that specifies the maximum number of tests to
+// run concurrently.
+type JobsOpt int
+
+func (JobsOpt) Opt() {}
+
 // NamespaceRootOpt is an option that specifies the namespace root of the
 // services to check in VanadiumProdServicesTest.
 type NamespaceRootOpt string
@@ -211,6 +219,18 @@ type OutputDirOpt string
 
 func (OutputDirOpt) Opt() {}
 
+// RerunsOpt is an option that specifies how many times to rerun the
+// failed Go test functions to tell flaky tests from failing ones.
+type RerunsOpt int
+
+func (RerunsOpt) Opt() {}
+
+// RunArgsOpt is an option that specifies the flags of "jiri test run"
+// used to run tests in separate processes when they run concurrently.
+type RunArgsOpt []string
+
+func (RunArgsOpt) Opt() {}
+
 // PartOpt is an option that specifies which part of the test to run.
 type PartOpt int
 
@@ -260,50 +280,19 @@ func RunProjectTests(jirix *jiri.X, env map[string]string, projects []string, op
 		return nil, nil
 	}
 	sort.Strings(tests)
-	graph, err := createTestDepGraph(config, tests)
+	schedule, err := createTestSchedule(config, tests, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	// Run tests.
-	//
-	// TODO(jingjin) parallelize the top-level scheduling loop so
-	// that tests do not need to run serially.
 	results := make(map[string]*test.Result, len(tests))
 	for _, t := range tests {
 		results[t] = &test.Result{}
 	}
-run:
-	for i := 0; i < len(graph); i++ {
-		// Find a test that can execute.
-		for _, t := range tests {
-			result, node := results[t], graph[t]
-			if result.Status != test.Pending {
-				continue
-			}
-			ready := true
-			for _, dep := range node.deps {
-				switch results[dep].Status {
-				case test.Skipped, test.Failed, test.TimedOut:
-					results[t].Status = test.Skipped
-					continue run
-				case test.Pending:
-					ready = false
-					break
-				}
-			}
-			if !ready {
-				continue
-			}
-			if err := runTests(testCtx, []string{t}, results, opts...); err != nil {
-				return nil, err
-			}
-			continue run
-		}
-		// The following line should be never reached.
-		return nil, fmt.Errorf("erroneous test running logic")
+	if err := runTests(testCtx, tests, schedule, results, opts...); err != nil {
+		return nil, err
 	}
-
 	return results, nil
 }
 
@@ -313,13 +302,59 @@ func RunTests(jirix *jiri.X, env map[string]string, tests []string, opts ...Opt)
 	for _, t := range tests {
 		results[t] = &test.Result{}
 	}
+	// Tests run one after another, in the given order, unless they run
+	// concurrently, in which case the configured dependencies and
+	// resources of the tests determine when they run.
+	config := tooldata.NewConfig()
+	if getJobs(opts) > 1 {
+		var err error
+		if config, err = tooldata.LoadConfig(jirix); err != nil {
+			return nil, err
+		}
+	}
+	schedule, err := createTestSchedule(config, tests, opts)
+	if err != nil {
+		return nil, err
+	}
 	testCtx := newTestContext(jirix, env)
-	if err := runTests(testCtx, tests, results, opts...); err != nil {
+	if err := runTests(testCtx, tests, schedule, results, opts...); err != nil {
 		return nil, err
 	}
 	return results, nil
 }
 
+// getJobs returns the maximum number of tests to run concurrently
+// specified by the given options.
+func getJobs(opts []Opt) int {
+	jobs := 1
+	for _, opt := range opts {
+		if typedOpt, ok := opt.(JobsOpt); ok && typedOpt > 1 {
+			jobs = int(typedOpt)
+		}
+	}
+	return jobs
+}
+
+// createTestSchedule creates the schedule for running the given tests
+// given their configured dependencies and resources.
+func createTestSchedule(config *tooldata.Config, tests []string, opts []Opt) (testSchedule, error) {
+	graph, err := createTestDepGraph(config, tests)
+	if err != nil {
+		return testSchedule{}, err
+	}
+	resources := map[string][]string{}
+	for _, t := range tests {
+		if r := config.TestResources(t); len(r) > 0 {
+			resources[t] = r
+		}
+	}
+	return testSchedule{
+		graph:     graph,
+		resources: resources,
+		jobs:      getJobs(opts),
+	}, nil
+}
+
 type nopWriteCloser struct{}
 
 func (nopWriteCloser) Close() error {
@@ -330,15 +365,19 @@ func (nopWriteCloser) Write([]byte) (int, error) {
 	return 0, nil
 }
 
-// runTests runs the given tests, populating the results map.
-func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, opts ...Opt) (e error) {
+// runTests runs the given tests, following the given schedule, and
+// populates the results map.
+func runTests(jirix *jiri.X, tests []string, schedule testSchedule, results map[string]*test.Result, opts ...Opt) (e error) {
 	outputDir := ""
+	var runArgs []string
 	for _, opt := range opts {
 		switch typedOpt := opt.(type) {
 		case OutputDirOpt:
 			outputDir = string(typedOpt)
 		case CleanGoOpt:
 			cleanGo = bool(typedOpt)
+		case RunArgsOpt:
+			runArgs = []string(typedOpt)
 		}
 	}
 
@@ -361,40 +400,64 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 		}
 	}
 
-	for _, t := range tests {
-		testFn := testFunctions[t]
+	var snapshot string
+	if schedule.jobs <= 1 {
+		// Tests that run in separate processes record their own
+		// history.
+		snapshot = snapshotID(jirix)
+	}
+	runTest := func(t string) (*test.Result, error) {
 		fmt.Fprintf(jirix.Stdout(), "##### Running test %q #####\n", t)
-
-		// Create a 1MB buffer to capture the test function output.
-		var out bytes.Buffer
-		const largeBufferSize = 1 << 20
-		out.Grow(largeBufferSize)
-		newX := jirix.Clone(tool.ContextOpts{
-			Stdout: io.MultiWriter(&out, jirix.Stdout()),
-			Stderr: io.MultiWriter(&out, jirix.Stderr()),
-		})
-
-		// Run the test and collect the test results.
-		result, err := testFn(newX, t, opts...)
-		if result != nil && result.Status == test.TimedOut {
-			writeTimedOutTestReport(newX, t, *result)
+		result, out, err := runTestFunction(jirix, t, snapshot, opts...)
+		if err != nil {
+			return nil, err
 		}
-		if err == nil {
-			err = checkTestReportFile(newX, t)
+		if _, err := outputFile.Write(out); err != nil {
+			return nil, err
 		}
-		if err != nil {
-			fmt.Fprintf(newX.Stderr(), "%v\n", err)
-			r, err := generateXUnitReportForError(newX, t, err, out.String())
-			if err != nil {
+		fmt.Fprintf(jirix.Stdout(), "##### %s #####\n", result.Status)
+		return result, nil
+	}
+	if schedule.jobs > 1 {
+		// Tests that run concurrently run in separate processes, as
+		// tests change process-wide state such as the working directory.
+		testsDir := filepath.Join(outputDir, "tests")
+		if outputDir == "" {
+			var err error
+			if testsDir, err = jirix.NewSeq().TempDir("", "jiri-test"); err != nil {
 				return err
 			}
-			result = r
+			defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(testsDir).Done() }, &e)
 		}
-		results[t] = result
-		if _, err := outputFile.Write(out.Bytes()); err != nil {
-			return err
+		if cleanGo {
+			// Remove all stale Go object files and binaries once,
+			// rather than while other tests are building.
+			if err := jirix.NewSeq().Last("jiri", "goext", "distclean"); err != nil {
+				return fmt.Errorf("jiri goext distclean: %v", err)
+			}
+			runArgs = append(runArgs, "-clean-go=false")
 		}
-		fmt.Fprintf(jirix.Stdout(), "##### %s #####\n", results[t].Status)
+		// Serialize the output of the tests, so that the output of a
+		// test is reported in one piece once it finishes.
+		var mu sync.Mutex
+		runTest = func(t string) (*test.Result, error) {
+			result, out, err := runTestProcess(jirix, t, filepath.Join(testsDir, t), runArgs)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := jirix.Stdout().Write(out); err != nil {
+				return nil, err
+			}
+			if _, err := outputFile.Write(out); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+	}
+	if err := schedule.run(jirix.Stdout(), tests, results, runTest); err != nil {
+		return err
 	}
 
 	if outputDir != "" {
@@ -412,6 +475,73 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 	return nil
 }
 
+// runTestFunction runs the given test in this process, returning its
+// result and output. The result is recorded in the test history along
+// with the given snapshot identifier.
+func runTestFunction(jirix *jiri.X, testName, snapshot string, opts ...Opt) (*test.Result, []byte, error) {
+	// Create a 1MB buffer to capture the test function output.
+	var out bytes.Buffer
+	const largeBufferSize = 1 << 20
+	out.Grow(largeBufferSize)
+	newX := jirix.Clone(tool.ContextOpts{
+		Stdout: io.MultiWriter(&out, jirix.Stdout()),
+		Stderr: io.MultiWriter(&out, jirix.Stderr()),
+	})
+
+	// Run the test and collect the test results.
+	start := time.Now()
+	result, err := testFunctions[testName](newX, testName, opts...)
+	if result != nil && result.Status == test.TimedOut {
+		writeTimedOutTestReport(newX, testName, *result)
+	}
+	if err == nil {
+		err = checkTestReportFile(newX, testName)
+	}
+	if err != nil {
+		fmt.Fprintf(newX.Stderr(), "%v\n", err)
+		r, err := generateXUnitReportForError(newX, testName, err, out.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		result = r
+	}
+	recordHistory(jirix, testName, snapshot, result, start)
+	return result, out.Bytes(), nil
+}
+
+// runTestProcess runs the given test using "jiri test run" with the
+// given flags, returning its result and output. The results and output
+// of the test are also written to the given directory.
+func runTestProcess(jirix *jiri.X, testName, outputDir string, runArgs []string) (*test.Result, []byte, error) {
+	var out bytes.Buffer
+	s := jirix.NewSeq()
+	if err := s.MkdirAll(outputDir, os.FileMode(0755)).Done(); err != nil {
+		return nil, nil, err
+	}
+	args := append([]string{"test", "run"}, runArgs...)
+	args = append(args, "-output-dir="+outputDir, testName)
+	// A failing test makes "jiri test run" exit with a non-zero exit
+	// code, so the outcome of the test is determined by its results.
+	runErr := s.Capture(&out, &out).Last("jiri", args...)
+	var results map[string]*test.Result
+	data, err := jirix.NewSeq().ReadFile(filepath.Join(outputDir, "results"))
+	if err == nil {
+		err = json.Unmarshal(data, &results)
+	}
+	if result := results[testName]; err == nil && result != nil {
+		return result, out.Bytes(), nil
+	}
+	if runErr == nil {
+		runErr = fmt.Errorf("no results for test %v", testName)
+	}
+	fmt.Fprintf(&out, "%v\n", runErr)
+	result, err := generateXUnitReportForError(jirix, testName, runErr, out.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, out.Bytes(), nil
+}
+
 // writeTimedOutTestReport writes a xUnit test report for the given timed-out test.
 func writeTimedOutTestReport(jirix *jiri.X, testName string, result test.Result) {
 	timeoutValue := test.DefaultTimeout
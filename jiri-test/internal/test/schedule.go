@@ -0,0 +1,144 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"io"
+
+	"v.io/x/devtools/internal/test"
+)
+
+// exclusiveResource is the resource needed by tests that can't run
+// concurrently with any other test.
+const exclusiveResource = "exclusive"
+
+// testSchedule captures the constraints on running tests concurrently.
+type testSchedule struct {
+	// graph is the test dependency graph. A test starts only after all
+	// of its dependencies have passed.
+	graph testDepGraph
+	// resources maps tests to the resources they need exclusive
+	// access to.
+	resources map[string][]string
+	// jobs is the maximum number of tests that run concurrently.
+	jobs int
+}
+
+// testRun records the outcome of running a test.
+type testRun struct {
+	name   string
+	result *test.Result
+	err    error
+}
+
+// needs checks whether the given test needs the given resource.
+func (s testSchedule) needs(name, resource string) bool {
+	for _, r := range s.resources[name] {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// conflicts checks whether the given test needs a resource held by
+// any of the running tests.
+func (s testSchedule) conflicts(name string, running map[string]bool) bool {
+	for other := range running {
+		if s.needs(other, exclusiveResource) {
+			return true
+		}
+		for _, resource := range s.resources[name] {
+			if s.needs(other, resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// failedDep returns the first dependency of the given test that didn't
+// pass, and whether all of its dependencies have finished.
+func (s testSchedule) failedDep(name string, results map[string]*test.Result, running map[string]bool) (string, bool) {
+	finished := true
+	if node, ok := s.graph[name]; ok {
+		for _, dep := range node.deps {
+			switch {
+			case running[dep] || results[dep].Status == test.Pending:
+				finished = false
+			case !results[dep].Status.Succeeded():
+				return dep, true
+			}
+		}
+	}
+	return "", finished
+}
+
+// run runs the given tests using the given function, starting them in
+// the given order as soon as the schedule allows, and populates the
+// results map. Tests whose dependencies don't pass are skipped. If
+// running a test returns an error, no more tests are started and the
+// error is returned once the running tests finish.
+func (s testSchedule) run(stdout io.Writer, tests []string, results map[string]*test.Result, runTest func(string) (*test.Result, error)) error {
+	jobs := s.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	pending := append([]string(nil), tests...)
+	running := map[string]bool{}
+	done := make(chan testRun)
+	var runErr error
+	for {
+		// Start as many pending tests as possible.
+	start:
+		for i := 0; runErr == nil && i < len(pending) && len(running) < jobs; {
+			name := pending[i]
+			dep, finished := s.failedDep(name, results, running)
+			switch {
+			case dep != "":
+				results[name] = &test.Result{Status: test.Skipped, FailedDependency: dep}
+				fmt.Fprintf(stdout, "##### Skipping test %q: dependency %q did not pass #####\n", name, dep)
+				pending = append(pending[:i], pending[i+1:]...)
+				// Skipping a test can affect the tests that depend on
+				// it, so start over.
+				i = 0
+				continue
+			case !finished:
+				i++
+				continue
+			case s.needs(name, exclusiveResource) && len(running) > 0:
+				// Wait for the running tests to finish, rather than
+				// starting other tests that would delay this one.
+				break start
+			case s.conflicts(name, running):
+				i++
+				continue
+			}
+			pending = append(pending[:i], pending[i+1:]...)
+			running[name] = true
+			go func(name string) {
+				result, err := runTest(name)
+				done <- testRun{name, result, err}
+			}(name)
+		}
+		if len(running) == 0 {
+			if runErr == nil && len(pending) > 0 {
+				// The following line should be never reached.
+				return fmt.Errorf("erroneous test running logic")
+			}
+			return runErr
+		}
+		r := <-done
+		delete(running, r.name)
+		if r.err != nil {
+			if runErr == nil {
+				runErr = r.err
+			}
+			continue
+		}
+		results[r.name] = r.result
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"v.io/x/devtools/internal/test"
+)
+
+// fakeTests records how fake tests run.
+type fakeTests struct {
+	mu sync.Mutex
+	// failed is the set of tests that fail.
+	failed map[string]bool
+	// started lists the tests in the order they started.
+	started []string
+	// running is the set of running tests.
+	running map[string]bool
+	// overlaps lists the pairs of tests that ran concurrently.
+	overlaps map[[2]string]bool
+	// maxRunning is the maximum number of tests that ran concurrently.
+	maxRunning int
+	// together is the set of tests that don't finish until all of them
+	// have started, which forces them to run concurrently if the
+	// schedule allows it.
+	together map[string]bool
+	// pending is the number of tests in together that haven't started.
+	pending int
+	// allStarted is closed once all tests in together have started.
+	allStarted chan struct{}
+}
+
+func (f *fakeTests) run(name string) (*test.Result, error) {
+	f.mu.Lock()
+	f.started = append(f.started, name)
+	for other := range f.running {
+		pair := [2]string{other, name}
+		if name < other {
+			pair = [2]string{name, other}
+		}
+		f.overlaps[pair] = true
+	}
+	f.running[name] = true
+	if len(f.running) > f.maxRunning {
+		f.maxRunning = len(f.running)
+	}
+	if f.together[name] {
+		if f.pending--; f.pending == 0 {
+			close(f.allStarted)
+		}
+	}
+	f.mu.Unlock()
+
+	if f.together[name] {
+		// Don't wait forever if the schedule doesn't run the tests
+		// concurrently; the tests then fail to overlap.
+		select {
+		case <-f.allStarted:
+		case <-time.After(10 * time.Second):
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.running, name)
+	if f.failed[name] {
+		return &test.Result{Status: test.Failed}, nil
+	}
+	return &test.Result{Status: test.Passed}, nil
+}
+
+// runSchedule runs the given tests following s, where the given tests
+// fail, and the tests in together are made to run concurrently.
+func runSchedule(t *testing.T, s testSchedule, tests []string, failed map[string]bool, together ...string) (*fakeTests, map[string]*test.Result) {
+	f := &fakeTests{
+		failed:     failed,
+		running:    map[string]bool{},
+		overlaps:   map[[2]string]bool{},
+		together:   map[string]bool{},
+		pending:    len(together),
+		allStarted: make(chan struct{}),
+	}
+	for _, name := range together {
+		f.together[name] = true
+	}
+	results := map[string]*test.Result{}
+	for _, name := range tests {
+		results[name] = &test.Result{}
+	}
+	if err := s.run(ioutil.Discard, tests, results, f.run); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return f, results
+}
+
+func TestScheduleDependencies(t *testing.T) {
+	// A -> {B, C}, C -> D
+	graph := testDepGraph{
+		"A": &testNode{deps: []string{"B", "C"}},
+		"B": &testNode{},
+		"C": &testNode{deps: []string{"D"}},
+		"D": &testNode{},
+		"E": &testNode{},
+	}
+	tests := []string{"A", "B", "C", "D", "E"}
+	for _, jobs := range []int{1, 2, 5} {
+		// With several jobs, the independent tests D and E run
+		// concurrently.
+		var together []string
+		if jobs > 1 {
+			together = []string{"D", "E"}
+		}
+		f, results := runSchedule(t, testSchedule{graph: graph, jobs: jobs}, tests, nil, together...)
+		position := map[string]int{}
+		for i, name := range f.started {
+			position[name] = i
+		}
+		for name, node := range graph {
+			if results[name].Status != test.Passed {
+				t.Errorf("jobs=%d: test %v: got status %v, want %v", jobs, name, results[name].Status, test.Passed)
+			}
+			for _, dep := range node.deps {
+				if position[dep] > position[name] || f.overlaps[[2]string{dep, name}] || f.overlaps[[2]string{name, dep}] {
+					t.Errorf("jobs=%d: test %v started before its dependency %v finished: %v", jobs, name, dep, f.started)
+				}
+			}
+		}
+		if f.maxRunning > jobs {
+			t.Errorf("jobs=%d: got %d concurrent tests", jobs, f.maxRunning)
+		}
+		if jobs > 1 && f.maxRunning < 2 {
+			t.Errorf("jobs=%d: tests didn't run concurrently", jobs)
+		}
+	}
+}
+
+func TestScheduleSkipsDependents(t *testing.T) {
+	// A -> B -> C, D -> E
+	graph := testDepGraph{
+		"A": &testNode{deps: []string{"B"}},
+		"B": &testNode{deps: []string{"C"}},
+		"C": &testNode{},
+		"D": &testNode{deps: []string{"E"}},
+		"E": &testNode{},
+	}
+	_, results := runSchedule(t, testSchedule{graph: graph, jobs: 3}, []string{"A", "B", "C", "D", "E"}, map[string]bool{"C": true})
+	want := map[string]*test.Result{
+		"A": &test.Result{Status: test.Skipped, FailedDependency: "B"},
+		"B": &test.Result{Status: test.Skipped, FailedDependency: "C"},
+		"C": &test.Result{Status: test.Failed},
+		"D": &test.Result{Status: test.Passed},
+		"E": &test.Result{Status: test.Passed},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got results %v, want %v", results, want)
+	}
+}
+
+func TestScheduleResources(t *testing.T) {
+	graph := testDepGraph{}
+	tests := []string{"A", "B", "C", "D", "E"}
+	for _, name := range tests {
+		graph[name] = &testNode{}
+	}
+	resources := map[string][]string{
+		"A": []string{"needs-port-8101"},
+		"B": []string{"needs-port-8101"},
+		"C": []string{exclusiveResource},
+	}
+	f, _ := runSchedule(t, testSchedule{graph: graph, resources: resources, jobs: 5}, tests, nil, "D", "E")
+	for pair := range f.overlaps {
+		if pair == [2]string{"A", "B"} || pair[0] == "C" || pair[1] == "C" {
+			t.Errorf("tests %v ran concurrently", pair)
+		}
+	}
+	if !f.overlaps[[2]string{"D", "E"}] {
+		t.Errorf("tests D and E didn't run concurrently")
+	}
+}
+
+func TestScheduleError(t *testing.T) {
+	graph := testDepGraph{"A": &testNode{}, "B": &testNode{deps: []string{"A"}}}
+	results := map[string]*test.Result{"A": &test.Result{}, "B": &test.Result{}}
+	runTest := func(name string) (*test.Result, error) {
+		return nil, fmt.Errorf("%v failed", name)
+	}
+	if err := (testSchedule{graph: graph, jobs: 2}).run(ioutil.Discard, []string{"A", "B"}, results, runTest); err == nil || err.Error() != "A failed" {
+		t.Errorf("got error %v, want %q", err, "A failed")
+	}
+	if got, want := results["B"].Status, test.Pending; got != want {
+		t.Errorf("got status %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package foo_flaky_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFlaky fails the first time it runs and passes afterwards.
+func TestFlaky(t *testing.T) {
+	marker := filepath.Join(os.TempDir(), "foo_flaky")
+	if _, err := os.Stat(marker); err == nil {
+		return
+	}
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Fatalf("first run")
+}
+
+func TestPass(t *testing.T) {
+}
+
+func TestFail(t *testing.T) {
+	t.Fatalf("always fails")
+}
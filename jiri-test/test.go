@@ -9,6 +9,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"runtime"
 	"strings"
@@ -27,6 +28,7 @@ import (
 var (
 	blessingsRootFlag    string
 	cleanGoFlag          bool
+	jobsFlag             int
 	mockTestFilePaths    string
 	mockTestFileContents string
 	namespaceRootFlag    string
@@ -34,13 +36,21 @@ var (
 	outputDirFlag        string
 	partFlag             int
 	pkgsFlag             string
+	rerunsFlag           int
 	oauthBlesserFlag     string
 	adminRoleFlag        string
 	publisherRoleFlag    string
 	readerFlags          profilescmdline.ReaderFlagValues
+	// testProjectFlags and testRunFlags hold the flags of the "jiri test
+	// project" and "jiri test run" commands, including inherited flags.
+	testProjectFlags, testRunFlags []*flag.FlagSet
 )
 
 func init() {
+	for _, cmd := range []*cmdline.Command{cmdTestProject, cmdTestRun} {
+		cmd.Flags.IntVar(&jobsFlag, "jobs", 1, "The maximum number of tests to run concurrently. Tests that run concurrently start only after the tests they depend on pass, and tests that need the same resources, as configured in the tools configuration file, don't run concurrently.")
+		cmd.Flags.IntVar(&rerunsFlag, "reruns", 0, "The maximum number of times to rerun the failed Go test functions. Tests that fail and then pass when rerun are reported as flaky, and the test passes on retry if all of its failed tests are flaky.")
+	}
 	cmdTestRun.Flags.StringVar(&blessingsRootFlag, "blessings-root", "dev.v.io", "The blessings root.")
 	cmdTestRun.Flags.StringVar(&namespaceRootFlag, "v23.namespace.root", "/ns.dev.v.io:8101", "The namespace root.")
 	cmdTestRun.Flags.IntVar(&numWorkersFlag, "num-test-workers", runtime.NumCPU(), "Set the number of test workers to use; use 1 to serialize all tests.")
@@ -54,6 +64,8 @@ func init() {
 	tool.InitializeRunFlags(&cmdTest.Flags)
 	tool.InitializeProjectFlags(&cmdProjectPoll.Flags)
 	profilescmdline.RegisterReaderFlags(&cmdTest.Flags, &readerFlags, "v23:base", jiri.ProfilesDBDir)
+	testProjectFlags = []*flag.FlagSet{&cmdTest.Flags, &cmdTestProject.Flags}
+	testRunFlags = []*flag.FlagSet{&cmdTest.Flags, &cmdTestRun.Flags}
 }
 
 // cmdTest represents the "jiri test" command.
@@ -61,7 +73,7 @@ var cmdTest = &cmdline.Command{
 	Name:     "test",
 	Short:    "Manage vanadium tests",
 	Long:     "Manage vanadium tests.",
-	Children: []*cmdline.Command{cmdProjectPoll, cmdTestProject, cmdTestRun, cmdTestList},
+	Children: []*cmdline.Command{cmdProjectPoll, cmdTestProject, cmdTestRun, cmdTestList, cmdExclusions, cmdHistory},
 }
 
 // cmdTestProject represents the "jiri test project" command.
@@ -85,13 +97,14 @@ func runTestProject(jirix *jiri.X, args []string) error {
 		return jirix.UsageErrorf("unexpected number of arguments")
 	}
 	project := args[0]
-	results, err := jiriTest.RunProjectTests(jirix, nil, []string{project}, optsFromFlags()...)
+	opts := append(optsFromFlags(), jiriTest.RunArgsOpt(runArgs(testProjectFlags)))
+	results, err := jiriTest.RunProjectTests(jirix, nil, []string{project}, opts...)
 	if err != nil {
 		return err
 	}
 	printSummary(jirix, results)
 	for _, result := range results {
-		if result.Status != test.Passed {
+		if !result.Status.Succeeded() {
 			return cmdline.ErrExitCode(test.FailedExitCode)
 		}
 	}
@@ -113,13 +126,14 @@ func runTestRun(jirix *jiri.X, args []string) error {
 	if len(args) == 0 {
 		return jirix.UsageErrorf("unexpected number of arguments")
 	}
-	results, err := jiriTest.RunTests(jirix, nil, args, optsFromFlags()...)
+	opts := append(optsFromFlags(), jiriTest.RunArgsOpt(runArgs(testRunFlags)))
+	results, err := jiriTest.RunTests(jirix, nil, args, opts...)
 	if err != nil {
 		return err
 	}
 	printSummary(jirix, results)
 	for _, result := range results {
-		if result.Status != test.Passed {
+		if !result.Status.Succeeded() {
 			return cmdline.ErrExitCode(test.FailedExitCode)
 		}
 	}
@@ -206,6 +220,8 @@ func optsFromFlags() (opts []jiriTest.Opt) {
 		jiriTest.NumWorkersOpt(numWorkersFlag),
 		jiriTest.OutputDirOpt(outputDirFlag),
 		jiriTest.CleanGoOpt(cleanGoFlag),
+		jiriTest.JobsOpt(jobsFlag),
+		jiriTest.RerunsOpt(rerunsFlag),
 		jiriTest.MergePoliciesOpt(readerFlags.MergePolicies),
 	)
 	if mockTestFilePaths != "" && mockTestFileContents != "" {
@@ -217,10 +233,30 @@ func optsFromFlags() (opts []jiriTest.Opt) {
 	return
 }
 
+// runArgs returns the flags set on the command line, other than the
+// flags that control how tests are scheduled, for running tests in
+// separate processes using "jiri test run".
+func runArgs(flagSets []*flag.FlagSet) []string {
+	seen := map[string]bool{"clean-go": true, "jobs": true, "output-dir": true}
+	args := []string{}
+	for _, flags := range flagSets {
+		flags.Visit(func(f *flag.Flag) {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+			}
+		})
+	}
+	return args
+}
+
 func printSummary(jirix *jiri.X, results map[string]*test.Result) {
 	fmt.Fprintf(jirix.Stdout(), "SUMMARY:\n")
 	for name, result := range results {
 		fmt.Fprintf(jirix.Stdout(), "%v %s\n", name, result.Status)
+		if result.FailedDependency != "" {
+			fmt.Fprintf(jirix.Stdout(), "  dependency %v did not pass\n", result.FailedDependency)
+		}
 		if len(result.ExcludedTests) > 0 {
 			for pkg, tests := range result.ExcludedTests {
 				fmt.Fprintf(jirix.Stdout(), "  excluded %d tests from package %v: %v\n", len(tests), pkg, tests)
@@ -231,6 +267,11 @@ func printSummary(jirix *jiri.X, results map[string]*test.Result) {
 				fmt.Fprintf(jirix.Stdout(), "  skipped %d tests from package %v: %v\n", len(tests), pkg, tests)
 			}
 		}
+		if len(result.FlakyTests) > 0 {
+			for pkg, tests := range result.FlakyTests {
+				fmt.Fprintf(jirix.Stdout(), "  %d tests from package %v passed on retry: %v\n", len(tests), pkg, tests)
+			}
+		}
 	}
 }
 
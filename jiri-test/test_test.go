@@ -9,10 +9,13 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"v.io/jiri/jiritest"
 	"v.io/jiri/tool"
-	"v.io/x/devtools/jiri-test/internal/test"
+	"v.io/x/devtools/internal/history"
+	"v.io/x/devtools/internal/test"
+	jiriTest "v.io/x/devtools/jiri-test/internal/test"
 	"v.io/x/devtools/tooldata"
 )
 
@@ -94,7 +97,7 @@ func TestTestList(t *testing.T) {
 	if err := runTestList(fake.X, []string{}); err != nil {
 		t.Fatalf("%v", err)
 	}
-	testList, err := test.ListTests()
+	testList, err := jiriTest.ListTests()
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
@@ -102,3 +105,112 @@ func TestTestList(t *testing.T) {
 		t.Fatalf("unexpected output:\ngot\n%v\nwant\n%v", got, want)
 	}
 }
+
+func TestExclusions(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	exclusions := []tooldata.Exclusion{
+		tooldata.Exclusion{Package: "v.io/x/foo", Test: "TestFoo", Expires: "2015-01-01"},
+	}
+	if err := tooldata.SaveExclusions(fake.X, exclusions); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Check that adding an exclusion saves it.
+	exclusionOwnerFlag, exclusionModeFlag = "jsimsa", "race"
+	defer func() { exclusionOwnerFlag, exclusionModeFlag = "", "" }()
+	if err := runExclusionsAdd(fake.X, []string{"v.io/x/bar", "TestBar"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var out bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &out, Stderr: &out})
+	if err := runExclusionsList(fake.X, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	got, want := out.String(), `v.io/x/foo TestFoo
+  expires: 2015-01-01
+  EXPIRED
+v.io/x/bar TestBar
+  mode: race
+  owner: jsimsa
+`
+	if got != want {
+		t.Fatalf("unexpected output:\ngot\n%v\nwant\n%v", got, want)
+	}
+
+	// Check that expiring exclusions removes the expired ones.
+	if err := runExclusionsExpire(fake.X, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	remaining, err := tooldata.LoadExclusions(fake.X)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(remaining) != 1 || remaining[0].Package != "v.io/x/bar" {
+		t.Fatalf("unexpected exclusions: %v", remaining)
+	}
+
+	// Check that expiring a given exclusion removes it.
+	if err := runExclusionsExpire(fake.X, []string{"v.io/x/bar", "TestBar"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if remaining, err = tooldata.LoadExclusions(fake.X); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("unexpected exclusions: %v", remaining)
+	}
+	if err := runExclusionsExpire(fake.X, []string{"v.io/x/bar", "TestBar"}); err == nil {
+		t.Fatalf("expiring a missing exclusion didn't fail")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	path := tooldata.TestHistoryFilePath(fake.X)
+	start := time.Date(2015, time.December, 31, 12, 0, 0, 0, time.UTC)
+	records := []history.Record{}
+	for i, status := range []test.Status{test.Passed, test.Failed, test.Passed} {
+		records = append(records, history.Record{
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Test:     "vanadium-go-test",
+			Package:  "v.io/x/foo",
+			Case:     "TestFoo",
+			Status:   status,
+			Duration: time.Duration(i+1) * time.Second,
+			Snapshot: "abc",
+			Host:     "host",
+		})
+	}
+	if err := history.Append(fake.X, path, records); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Check that the history of a test case is summarized.
+	historyRunsFlag = 2
+	defer func() { historyRunsFlag = 10 }()
+	var out bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &out, Stderr: &out})
+	if err := runHistory(fake.X, []string{"v.io/x/foo.TestFoo"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	got, want := out.String(), `Runs: 3
+Pass rate: 66.7%
+Flakiness score: 0.67
+Duration: 1s (older runs) -> 2.5s (recent runs) +150.0%
+Recent runs:
+  2015-12-31 13:00:00 FAILED          2s           snapshot=abc host=host
+  2015-12-31 14:00:00 PASSED          3s           snapshot=abc host=host
+`
+	if got != want {
+		t.Fatalf("unexpected output:\ngot\n%v\nwant\n%v", got, want)
+	}
+
+	// Check that tests without history are reported.
+	if err := runHistory(fake.X, []string{"v.io/x/foo.TestBar"}); err == nil {
+		t.Fatalf("showing the history of a test without history didn't fail")
+	}
+}
@@ -887,15 +887,13 @@ Usage:
 The jiri api commands are:
    check       Check if any changes have been made to the public API
    fix         Update api files to reflect changes to the public API
+   log         Show the history of the public API of a package
 
 The jiri api flags are:
  -color=true
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
- -gotools-bin=
-   The path to the gotools binary to use. If empty, gotools will be built if
-   necessary.
  -manifest=
    Name of the project manifest.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -915,6 +913,14 @@ Jiri api check - Check if any changes have been made to the public API
 
 Check if any changes have been made to the public API.
 
+The public API of each package is compared with its .api file entry by entry,
+ignoring the formatting of types and the names of parameters and results.  Each
+change is classified as compatible, e.g. an added function, method, type,
+interface or struct field, or breaking, e.g. a removed or changed entry or a
+method added to an existing interface.  The changes are listed by package and
+kind, and the check fails with exit status 3 if there are any breaking changes,
+or any changes at all if -strict is set.
+
 Usage:
    jiri api check [flags] <projects>
 
@@ -925,14 +931,17 @@ The jiri api check flags are:
  -detailed=true
    If true, shows each API change in an expanded form. Otherwise, only a summary
    is shown.
+ -format=text
+   The output format, text or json. The json format reports the project,
+   package, .api file and changes of each changed package.
+ -strict=false
+   If true, the check fails on any API change. Otherwise, it only fails on
+   breaking changes.
 
  -color=true
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
- -gotools-bin=
-   The path to the gotools binary to use. If empty, gotools will be built if
-   necessary.
  -manifest=
    Name of the project manifest.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -963,9 +972,41 @@ The jiri api fix flags are:
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
- -gotools-bin=
-   The path to the gotools binary to use. If empty, gotools will be built if
-   necessary.
+ -manifest=
+   Name of the project manifest.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri api log - Show the history of the public API of a package
+
+Show the history of the public API of a package, i.e. the commits that changed
+the package's .api file, oldest first, along with the entries that each commit
+added, removed or changed.  Commits that only reformat the .api file are
+omitted.
+
+Usage:
+   jiri api log [flags] <package>
+
+<package> is the import path of the package.
+
+The jiri api log flags are:
+ -format=text
+   The output format, text or json.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
  -manifest=
    Name of the project manifest.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -1013,7 +1054,30 @@ Jiri copyright - Manage vanadium copyright
 This command can be used to check if all source code files of Vanadium projects
 contain the appropriate copyright header and also if all projects contains the
 appropriate licensing files. Optionally, the command can be used to fix the
-appropriate copyright headers and licensing files.
+appropriate copyright headers and licensing files. The command can also be used
+to list the licenses of the third-party dependencies of projects.
+
+Projects released under other licenses can add a ".jiricopyright" file that
+declares the license of the project, using its SPDX identifier, and the
+copyright holder, e.g.:
+
+  <copyright>
+    <license>Apache-2.0</license>
+    <author>The Example Authors</author>
+    <firstYear>2014</firstYear>
+  </copyright>
+
+The supported licenses are Apache-2.0, BSD-3-Clause, MIT and MPL-2.0; other
+licenses can be used by specifying the template of their copyright header using
+a <header> element. The "[YEAR]" and "[AUTHOR]" placeholders of the template are
+replaced by the current year, or the range of years starting with <firstYear>,
+and by the copyright holder. Setting <spdx> to true makes the fix command add
+SPDX headers, i.e. a copyright line followed by an "SPDX-License-Identifier"
+line, instead. SPDX headers that identify the license of the project are always
+accepted, while headers of other licenses are reported as conflicting, and other
+headers of the license of the project, e.g. with another copyright holder, are
+replaced. The licensing files of projects with a ".jiricopyright" file are not
+checked, except for the presence of a LICENSE file.
 
 In order to ignore checked in third-party assets which have their own copyright
 and licensing headers a ".jiriignore" file can be added to a project. The
@@ -1026,6 +1090,7 @@ Usage:
 The jiri copyright commands are:
    check       Check copyright headers and licensing files
    fix         Fix copyright headers and licensing files
+   inventory   List the licenses of third-party dependencies
 
 The jiri copyright flags are:
  -color=true
@@ -1069,6 +1134,39 @@ The jiri copyright fix flags are:
  -v=false
    Print verbose output.
 
+Jiri copyright inventory - List the licenses of third-party dependencies
+
+List the licenses of the third-party dependencies of projects. The dependencies
+are the subdirectories of "third_party" directories, and the files matched by
+the patterns of ".jiriignore" files, grouped by pattern. The licenses of
+dependencies are identified by the text of the license files (e.g. LICENSE or
+COPYING) found in their root directories. The recognized licenses are
+Apache-2.0, BSD-2-Clause, BSD-3-Clause, MIT, MPL-1.1, MPL-2.0, GPL-2.0, GPL-3.0,
+LGPL-2.0, LGPL-2.1, LGPL-3.0 and AGPL-3.0.
+
+The inventory is printed to standard output as JSON. The texts of the licenses
+can also be collected into a notice file, using the -notice flag.
+
+The command fails if a dependency doesn't have a license file, has a license
+that isn't recognized, or has a copyleft license (i.e. MPL, GPL, LGPL or AGPL),
+so that releases can be blocked until these dependencies are reviewed.
+
+Usage:
+   jiri copyright inventory [flags] <projects>
+
+<projects> is a list of projects to inventory.
+
+The jiri copyright inventory flags are:
+ -color=true
+   Use color to format output.
+ -manifest=
+   Name of the project manifest.
+ -notice=
+   If set, the path of the notice file to generate, which lists the third-party
+   dependencies along with the texts of their licenses.
+ -v=false
+   Print verbose output.
+
 Jiri dockergo - Execute the go command in a docker container
 
 Executes a Go command in a docker container. This is primarily aimed at the
@@ -1608,6 +1706,8 @@ The jiri test commands are:
    project     Run tests for a vanadium project
    run         Run vanadium tests
    list        List vanadium tests
+   exclusions  Manage Go test exclusions
+   history     Show the history of a test
 
 The jiri test flags are:
  -color=true
@@ -1672,6 +1772,16 @@ Usage:
 <project> identifies the project for which to run tests.
 
 The jiri test project flags are:
+ -jobs=1
+   The maximum number of tests to run concurrently. Tests that run concurrently
+   start only after the tests they depend on pass, and tests that need the same
+   resources, as configured in the tools configuration file, don't run
+   concurrently.
+ -reruns=0
+   The maximum number of times to rerun the failed Go test functions. Tests that
+   fail and then pass when rerun are reported as flaky, and the test passes on
+   retry if all of its failed tests are flaky.
+
  -color=true
    Use color to format output.
  -env=
@@ -1706,6 +1816,11 @@ The jiri test run flags are:
    tests. Setting this flag to 'false' may lead to faster Go builds, but it may
    also result in some source code changes not being reflected in the tests
    (e.g., if the change was made in a different Go workspace).
+ -jobs=1
+   The maximum number of tests to run concurrently. Tests that run concurrently
+   start only after the tests they depend on pass, and tests that need the same
+   resources, as configured in the tools configuration file, don't run
+   concurrently.
  -mock-file-contents=
    Colon-separated file contents to check when testing presubmit test. This flag
    is only used when running presubmit end-to-end test.
@@ -1722,6 +1837,10 @@ The jiri test run flags are:
    Comma-separated list of Go package expressions that identify a subset of
    tests to run; only relevant for Go-based tests. Example usage: jiri test run
    -pkgs v.io/x/ref vanadium-go-test
+ -reruns=0
+   The maximum number of times to rerun the failed Go test functions. Tests that
+   fail and then pass when rerun are reported as flaky, and the test passes on
+   retry if all of its failed tests are flaky.
  -v23.namespace.root=/ns.dev.v.io:8101
    The namespace root.
 
@@ -1767,6 +1886,184 @@ The jiri test list flags are:
  -v=false
    Print verbose output.
 
+Jiri test exclusions - Manage Go test exclusions
+
+Manages the Go tests that are excluded from test runs. The exclusions are stored
+in the exclusions file of the tools data directory.
+
+Usage:
+   jiri test exclusions [flags] <command>
+
+The jiri test exclusions commands are:
+   list        List Go test exclusions
+   add         Add a Go test exclusion
+   expire      Remove Go test exclusions
+
+The jiri test exclusions flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test exclusions list - List Go test exclusions
+
+Lists the Go test exclusions and whether they expired.
+
+Usage:
+   jiri test exclusions list [flags]
+
+The jiri test exclusions list flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test exclusions add - Add a Go test exclusion
+
+Adds an exclusion of the Go tests that match the given test regular expression,
+in the packages that match the given package regular expression. Use the
+-expires flag to quarantine the tests for a limited time.
+
+Usage:
+   jiri test exclusions add [flags] <package> <test>
+
+<package> is a regular expression that matches the import paths of the packages
+of the excluded tests.
+
+<test> is a regular expression that matches the names of the excluded tests.
+
+The jiri test exclusions add flags are:
+ -arch=
+   The GOARCH the exclusion is restricted to.
+ -ci=false
+   Restrict the exclusion to continuous integration machines.
+ -comment=
+   Why the tests are excluded.
+ -expires=
+   The last day, in the YYYY-MM-DD format, the exclusion applies. Expired
+   exclusions no longer exclude tests and are reported as failures.
+ -issue=
+   The issue that tracks the exclusion.
+ -mode=
+   Restrict the exclusion to integration tests ("integration") or to tests run
+   with the race detector ("race"). By default, the exclusion applies to all
+   other tests, including the tests run with the race detector.
+ -os=
+   The GOOS the exclusion is restricted to.
+ -owner=
+   The person responsible for the exclusion.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test exclusions expire - Remove Go test exclusions
+
+Removes the exclusions with the given package and test regular expressions, so
+that the excluded tests run again. If no arguments are given, removes all
+expired exclusions.
+
+Usage:
+   jiri test exclusions expire [flags] [<package> <test>]
+
+<package> and <test> are the package and test regular expressions of the
+exclusions to remove, as printed by "jiri test exclusions list".
+
+The jiri test exclusions expire flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test history - Show the history of a test
+
+Shows the pass rate, the flakiness score and the duration trend of a test,
+followed by its most recent runs. The history is recorded on the local machine
+by "jiri test run".
+
+The flakiness score is the fraction of the runs that either passed on retry or
+had a different outcome than the previous run of the same jiri test at the same
+checked out revisions of the projects. Runs against projects with uncommitted
+changes are not compared. The duration trend compares the mean duration of the
+older half of the runs with the one of the more recent half.
+
+Usage:
+   jiri test history [flags] <test|package.TestFunc>
+
+<test|package.TestFunc> is the name of a jiri test, e.g. vanadium-go-test, or
+the import path of a Go package followed by the name of a test function, e.g.
+v.io/x/ref/lib/vdl/build.TestBuildPackage.
+
+The jiri test history flags are:
+ -runs=10
+   The number of most recent runs to list.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
 Jiri filesystem - Description of jiri file system layout
 
 All data managed by the jiri tool is located in the file system under a root
@@ -117,6 +117,9 @@ The presubmit test flags are:
    separated by ':'.
  -refs=
    The review references separated by ':'.
+ -reruns=0
+   The maximum number of times to rerun the failed Go test functions. Test cases
+   that pass when rerun are reported as flaky and don't block submission.
  -test=
    The name of a single test to run.
 
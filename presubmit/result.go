@@ -66,6 +66,9 @@ type testResultSummary struct {
 	lastStatus         testStatus
 	curStatus          testStatus
 	timeoutValue       time.Duration
+	// passedOnRetry records whether the test passed only after its
+	// flaky test functions were rerun.
+	passedOnRetry bool
 }
 
 var (
@@ -337,8 +340,10 @@ func (r *testReporter) postReport(jirix *jiri.X) (bool, error) {
 
 	failedTestNames := map[string]struct{}{}
 	numNewFailures := 0
-	if failedTestNames = r.reportTestResultsSummary(jirix); len(failedTestNames) != 0 {
-		// Report failed test cases grouped by failure types.
+	if failedTestNames = r.reportTestResultsSummary(jirix); len(failedTestNames) != 0 || r.passedOnRetry() {
+		// Report failed test cases grouped by failure types. Flaky test
+		// cases don't count as new failures, so they don't block
+		// submission.
 		var err error
 		if numNewFailures, err = r.reportFailedTestCases(jirix); err != nil {
 			return false, err
@@ -355,6 +360,17 @@ func (r *testReporter) postReport(jirix *jiri.X) (bool, error) {
 	return success, nil
 }
 
+// passedOnRetry checks whether any of the tests passed only after its
+// flaky test functions were rerun.
+func (r *testReporter) passedOnRetry() bool {
+	for _, resultInfo := range r.testResults {
+		if resultInfo.Result.Status == test.PassedOnRetry {
+			return true
+		}
+	}
+	return false
+}
+
 // reportFailedPresubmitBuild reports a failed presubmit build.
 // It returns whether the presubmit build failed or not.
 //
@@ -465,6 +481,8 @@ func (r *testReporter) reportTestResultsSummary(jirix *jiri.X) map[string]struct
 		fmt.Fprintf(&lineBuf, "%s ➔ %s: %s", summary.lastStatus.String(), summary.curStatus.String(), nameString)
 		if summary.timeoutValue > 0 {
 			fmt.Fprintf(&lineBuf, " [TIMED OUT after %s]\n", summary.timeoutValue)
+		} else if summary.passedOnRetry {
+			fmt.Fprintf(&lineBuf, " [PASSED ON RETRY]\n")
 		} else {
 			fmt.Fprintf(&lineBuf, "\n")
 		}
@@ -502,8 +520,11 @@ func (r *testReporter) mergeTestResults(resultInfo testResultInfo, summary *test
 
 	// Get the status of the current presubmit test.
 	curStatus := statusUnknown
-	if result.Status == test.Passed {
+	if result.Status.Succeeded() {
 		curStatus = statusSuccess
+		if result.Status == test.PassedOnRetry {
+			summary.passedOnRetry = true
+		}
 	} else {
 		testFailed = true
 		curStatus = statusFail
@@ -532,6 +553,7 @@ const (
 	fixedFailure failureType = iota
 	newFailure
 	knownFailure
+	flakyFailure
 )
 
 func (t failureType) String() string {
@@ -542,6 +564,8 @@ func (t failureType) String() string {
 		return "NEW FAILURE"
 	case knownFailure:
 		return "KNOWN FAILURE"
+	case flakyFailure:
+		return "FLAKY FAILURE"
 	default:
 		return "UNKNOWN FAILURE TYPE"
 	}
@@ -551,7 +575,8 @@ func (t failureType) String() string {
 type failedTestLinksMap map[failureType][]string
 
 // reportFailedTestCasesByFailureTypes reports failed test cases grouped by
-// failure types: new failures, known failures, and fixed failures.
+// failure types: new failures, known failures, flaky failures, and fixed
+// failures. It returns the number of new failures.
 func (r *testReporter) reportFailedTestCases(jirix *jiri.X) (int, error) {
 	// Get groups.
 	groups, err := r.genFailedTestCasesGroupsForAllTests(jirix)
@@ -560,7 +585,7 @@ func (r *testReporter) reportFailedTestCases(jirix *jiri.X) (int, error) {
 	}
 
 	// Generate links for all groups.
-	for _, failureType := range []failureType{newFailure, knownFailure, fixedFailure} {
+	for _, failureType := range []failureType{newFailure, knownFailure, flakyFailure, fixedFailure} {
 		failedTestCaseInfos, ok := groups[failureType]
 		if !ok || len(failedTestCaseInfos) == 0 {
 			continue
@@ -593,7 +618,8 @@ type failedTestCasesGroups map[failureType][]failedTestCaseInfo
 // genFailedTestCasesGroupsForAllTests iterate all tests from the given
 // testResults, compares the presubmit failed test cases (read from the given
 // xUnit report) with the postsubmit failed test cases, and groups the failed
-// tests into three groups: new failures, known failures, and fixed failures.
+// tests into four groups: new failures, known failures, flaky failures (test
+// cases that passed when rerun), and fixed failures.
 // Each group has a slice of failedTestLinkInfo which is used to generate
 // dashboard links.
 func (r *testReporter) genFailedTestCasesGroupsForAllTests(jirix *jiri.X) (failedTestCasesGroups, error) {
@@ -657,6 +683,16 @@ func (r *testReporter) genFailedTestCasesGroupsForOneTest(jirix *jiri.X, testRes
 			// Unescape test name and class name.
 			curTestCase.Classname = html.UnescapeString(curTestCase.Classname)
 			curTestCase.Name = html.UnescapeString(curTestCase.Name)
+			// A flaky test.
+			if len(curTestCase.Failures) == 0 && len(curTestCase.FlakyFailures) > 0 {
+				groups[flakyFailure] = append(groups[flakyFailure], failedTestCaseInfo{
+					suiteName:    curTestSuite.Name,
+					className:    curTestCase.Classname,
+					testCaseName: curTestCase.Name,
+					testName:     testName,
+					axisValues:   testResult.AxisValues,
+				})
+			}
 			// A failed test.
 			if len(curTestCase.Failures) > 0 {
 				linkInfo := failedTestCaseInfo{
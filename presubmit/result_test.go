@@ -44,6 +44,11 @@ release/go/src/v.io/x/devtools/v23/main.go:1: you should feel bad
 release/go/src/v.io/x/devtools/v23/main.go:1: you should feel bad
 		  </failure>
     </testcase>
+    <testcase classname="c6.n" name="n6" time="0">
+		  <flakyFailure message="error">
+main_test.go:1: you should feel bad only sometimes
+		  </flakyFailure>
+    </testcase>
   </testsuite>
 </testsuites>
 	`
@@ -103,6 +108,19 @@ release/go/src/v.io/x/devtools/v23/main.go:1: you should feel bad
 						},
 					},
 				},
+				flakyFailure: []failedTestCaseInfo{
+					failedTestCaseInfo{
+						suiteName:    "ts1",
+						className:    "c6.n",
+						testCaseName: "n6",
+						testName:     "vanadium-go-test",
+						axisValues: axisValuesInfo{
+							Arch:      "amd64",
+							OS:        "linux",
+							PartIndex: 0,
+						},
+					},
+				},
 			},
 		},
 	}
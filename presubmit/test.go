@@ -47,6 +47,7 @@ const (
 
 var (
 	numWorkersFlag       int
+	rerunsFlag           int
 	reviewTargetRefsFlag string
 	testFlag             string
 	testPartRE           = regexp.MustCompile(`(.*)-part(\d)$`)
@@ -63,6 +64,7 @@ func init() {
 	cmdTest.Flags.Lookup("num-test-workers").DefValue = "<runtime.NumCPU()>"
 	cmdTest.Flags.StringVar(&projectsFlag, "projects", "", "The base names of the remote projects containing the CLs pointed by the refs, separated by ':'.")
 	cmdTest.Flags.StringVar(&reviewTargetRefsFlag, "refs", "", "The review references separated by ':'.")
+	cmdTest.Flags.IntVar(&rerunsFlag, "reruns", 0, "The maximum number of times to rerun the failed Go test functions. Test cases that pass when rerun are reported as flaky and don't block submission.")
 	cmdTest.Flags.StringVar(&testFlag, "test", "", "The name of a single test to run.")
 
 	tool.InitializeProjectFlags(&cmdTest.Flags)
@@ -235,6 +237,7 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 		"run",
 		"-output-dir", outputDir,
 		"-num-test-workers", fmt.Sprintf("%d", numWorkersFlag),
+		"-reruns", fmt.Sprintf("%d", rerunsFlag),
 	}
 	if testMode {
 		jiriArgs = append(jiriArgs,
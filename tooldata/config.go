@@ -46,6 +46,11 @@ type Config struct {
 	// corresponding test has n+1 parts: the first n parts are identified
 	// by L[0] to L[n-1]. The last part is whatever is left.
 	testParts map[string][]string
+	// testResources maps tests to sets of resources that the given
+	// test needs exclusive access to, such as "needs-port-8101". Tests
+	// that need the "exclusive" resource don't run concurrently with
+	// any other test.
+	testResources map[string][]string
 	// vdlWorkspaces identifies JIRI_ROOT subdirectories that contain
 	// a VDL workspace.
 	vdlWorkspaces []string
@@ -104,6 +109,12 @@ type TestPartsOpt map[string][]string
 
 func (TestPartsOpt) configOpt() {}
 
+// TestResourcesOpt is the type that can be used to pass the Config
+// factory a test resources option.
+type TestResourcesOpt map[string][]string
+
+func (TestResourcesOpt) configOpt() {}
+
 // VDLWorkspacesOpt is the type that can be used to pass the Config
 // factory a VDL workspace option.
 type VDLWorkspacesOpt []string
@@ -131,6 +142,8 @@ func NewConfig(opts ...ConfigOpt) *Config {
 			c.testGroups = map[string][]string(typedOpt)
 		case TestPartsOpt:
 			c.testParts = map[string][]string(typedOpt)
+		case TestResourcesOpt:
+			c.testResources = map[string][]string(typedOpt)
 		case VDLWorkspacesOpt:
 			c.vdlWorkspaces = []string(typedOpt)
 		}
@@ -214,6 +227,12 @@ func (c Config) TestParts(test string) []string {
 	return c.testParts[test]
 }
 
+// TestResources returns a list of resources that the given test needs
+// exclusive access to.
+func (c Config) TestResources(test string) []string {
+	return c.testResources[test]
+}
+
 // VDLWorkspaces returns the VDL workspaces included in the config.
 func (c Config) VDLWorkspaces() []string {
 	return c.vdlWorkspaces
@@ -276,6 +295,7 @@ type configSchema struct {
 	TestDependencies       dependencyGroupSchemas  `xml:"testDependencies>test"`
 	TestGroups             testGroupSchemas        `xml:"testGroups>group"`
 	TestParts              partGroupSchemas        `xml:"testParts>test"`
+	TestResources          resourceGroupSchemas    `xml:"testResources>test"`
 	VDLWorkspaces          []string                `xml:"vdlWorkspaces>workspace"`
 	XMLName                xml.Name                `xml:"config"`
 }
@@ -320,6 +340,17 @@ func (p partGroupSchemas) Len() int           { return len(p) }
 func (p partGroupSchemas) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 func (p partGroupSchemas) Less(i, j int) bool { return p[i].Name < p[j].Name }
 
+type resourceGroupSchema struct {
+	Name      string   `xml:"name,attr"`
+	Resources []string `xml:"resource"`
+}
+
+type resourceGroupSchemas []resourceGroupSchema
+
+func (r resourceGroupSchemas) Len() int           { return len(r) }
+func (r resourceGroupSchemas) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r resourceGroupSchemas) Less(i, j int) bool { return r[i].Name < r[j].Name }
+
 type testGroupSchema struct {
 	Name  string   `xml:"name,attr"`
 	Tests []string `xml:"test"`
@@ -359,6 +390,7 @@ func loadConfig(jirix *jiri.X, path string) (*Config, error) {
 		testDependencies:       map[string][]string{},
 		testGroups:             map[string][]string{},
 		testParts:              map[string][]string{},
+		testResources:          map[string][]string{},
 		vdlWorkspaces:          []string{},
 	}
 	config.apiCheckProjects = set.String.FromSlice(data.APICheckProjects)
@@ -382,6 +414,9 @@ func loadConfig(jirix *jiri.X, path string) (*Config, error) {
 	for _, test := range data.TestParts {
 		config.testParts[test.Name] = test.Parts
 	}
+	for _, test := range data.TestResources {
+		config.testResources[test.Name] = test.Resources
+	}
 	for _, workspace := range data.VDLWorkspaces {
 		config.vdlWorkspaces = append(config.vdlWorkspaces, workspace)
 	}
@@ -441,6 +476,13 @@ func saveConfig(jirix *jiri.X, config *Config, path string) error {
 		})
 	}
 	sort.Sort(data.TestParts)
+	for name, resources := range config.testResources {
+		data.TestResources = append(data.TestResources, resourceGroupSchema{
+			Name:      name,
+			Resources: resources,
+		})
+	}
+	sort.Sort(data.TestResources)
 	for _, workspace := range config.vdlWorkspaces {
 		data.VDLWorkspaces = append(data.VDLWorkspaces, workspace)
 	}
@@ -54,6 +54,10 @@ var (
 	testParts = map[string][]string{
 		"test-test-A": []string{"p1", "p2"},
 	}
+	testResources = map[string][]string{
+		"test-test-B": []string{"needs-port-8101"},
+		"test-test-C": []string{"exclusive"},
+	}
 	vdlWorkspaces = []string{"test-vdl-workspace"}
 )
 
@@ -91,6 +95,12 @@ func testConfigAPI(t *testing.T, c *tooldata.Config) {
 	if got, want := c.TestParts("test-test-A"), []string{"p1", "p2"}; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
+	if got, want := c.TestResources("test-test-B"), []string{"needs-port-8101"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
+	if got, want := c.TestResources("test-test-C"), []string{"exclusive"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
 	if got, want := c.VDLWorkspaces(), vdlWorkspaces; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
@@ -106,6 +116,7 @@ func TestConfigAPI(t *testing.T) {
 		tooldata.TestDependenciesOpt(testDependencies),
 		tooldata.TestGroupsOpt(testGroups),
 		tooldata.TestPartsOpt(testParts),
+		tooldata.TestResourcesOpt(testResources),
 		tooldata.VDLWorkspacesOpt(vdlWorkspaces),
 	)
 
@@ -125,6 +136,7 @@ func TestConfigSerialization(t *testing.T) {
 		tooldata.TestDependenciesOpt(testDependencies),
 		tooldata.TestGroupsOpt(testGroups),
 		tooldata.TestPartsOpt(testParts),
+		tooldata.TestResourcesOpt(testResources),
 		tooldata.VDLWorkspacesOpt(vdlWorkspaces),
 	)
 
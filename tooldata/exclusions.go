@@ -0,0 +1,144 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tooldata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"v.io/jiri"
+)
+
+// ExpiresLayout is the layout of the expiry dates of exclusions.
+const ExpiresLayout = "2006-01-02"
+
+// Exclusion identifies Go tests that are excluded from test runs,
+// e.g. because they are flaky or need resources that aren't available
+// to the tests.
+type Exclusion struct {
+	// Package is a regular expression that matches the import paths
+	// of the packages of the excluded tests.
+	Package string `xml:"package"`
+	// Test is a regular expression that matches the names of the
+	// excluded tests.
+	Test string `xml:"test"`
+	// Mode restricts the exclusion to integration tests
+	// ("integration") or to tests run with the race detector ("race").
+	// By default, the exclusion applies to all other tests, including
+	// the tests run with the race detector.
+	Mode string `xml:"mode,omitempty"`
+	// OS and Arch restrict the exclusion to the given GOOS and GOARCH.
+	OS   string `xml:"os,omitempty"`
+	Arch string `xml:"arch,omitempty"`
+	// CI restricts the exclusion to continuous integration machines.
+	CI bool `xml:"ci,omitempty"`
+	// Owner identifies the person responsible for the exclusion.
+	Owner string `xml:"owner,omitempty"`
+	// Issue links to the issue that tracks the exclusion.
+	Issue string `xml:"issue,omitempty"`
+	// Expires is the last day, in the ExpiresLayout format, the
+	// exclusion applies. Expired exclusions no longer exclude tests and
+	// are reported as failures. By default, exclusions don't expire.
+	Expires string `xml:"expires,omitempty"`
+	// Comment describes why the tests are excluded.
+	Comment string `xml:"comment,omitempty"`
+}
+
+// Expired checks whether the exclusion is expired at the given time.
+func (e Exclusion) Expired(t time.Time) bool {
+	if e.Expires == "" {
+		return false
+	}
+	expires, err := time.ParseInLocation(ExpiresLayout, e.Expires, t.Location())
+	if err != nil {
+		return false
+	}
+	return !t.Before(expires.AddDate(0, 0, 1))
+}
+
+// validate checks that the exclusion is well-formed.
+func (e Exclusion) validate() error {
+	if _, err := regexp.Compile(e.Package); err != nil {
+		return fmt.Errorf("invalid package %q: %v", e.Package, err)
+	}
+	if _, err := regexp.Compile(e.Test); err != nil {
+		return fmt.Errorf("invalid test %q: %v", e.Test, err)
+	}
+	switch e.Mode {
+	case "", "integration", "race":
+	default:
+		return fmt.Errorf("invalid mode %q", e.Mode)
+	}
+	if e.Expires != "" {
+		if _, err := time.Parse(ExpiresLayout, e.Expires); err != nil {
+			return fmt.Errorf("invalid expiry date %q: %v", e.Expires, err)
+		}
+	}
+	return nil
+}
+
+type exclusionsSchema struct {
+	Exclusions []Exclusion `xml:"exclusion"`
+	XMLName    xml.Name    `xml:"exclusions"`
+}
+
+// LoadExclusions returns the exclusions stored in the exclusions file.
+func LoadExclusions(jirix *jiri.X) ([]Exclusion, error) {
+	path, err := ExclusionsFilePath(jirix)
+	if err != nil {
+		return nil, err
+	}
+	return loadExclusions(jirix, path)
+}
+
+func loadExclusions(jirix *jiri.X, path string) ([]Exclusion, error) {
+	bytes, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data exclusionsSchema
+	if err := xml.Unmarshal(bytes, &data); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	for _, e := range data.Exclusions {
+		if err := e.validate(); err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+	return data.Exclusions, nil
+}
+
+// SaveExclusions writes the given exclusions to the exclusions file.
+func SaveExclusions(jirix *jiri.X, exclusions []Exclusion) error {
+	path, err := ExclusionsFilePath(jirix)
+	if err != nil {
+		return err
+	}
+	return saveExclusions(jirix, exclusions, path)
+}
+
+func saveExclusions(jirix *jiri.X, exclusions []Exclusion, path string) error {
+	for _, e := range exclusions {
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+	data := exclusionsSchema{Exclusions: exclusions}
+	bytes, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", data, err)
+	}
+	bytes = append(append([]byte(xml.Header), bytes...), '\n')
+	s := jirix.NewSeq()
+	if err := s.MkdirAll(filepath.Dir(path), os.FileMode(0755)).
+		WriteFile(path, bytes, os.FileMode(0644)).Done(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tooldata_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/jiri/jiritest"
+	"v.io/x/devtools/tooldata"
+)
+
+func TestExclusions(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	exclusions := []tooldata.Exclusion{
+		tooldata.Exclusion{
+			Package: "v.io/x/ref/services/device",
+			Test:    "TestV23DeviceManagerMultiUser",
+			OS:      "darwin",
+			Owner:   "jingjin",
+			Issue:   "https://github.com/vanadium/issues/issues/639",
+		},
+		tooldata.Exclusion{
+			Package: "golang.org/x/crypto/ssh",
+			Test:    ".*",
+			Mode:    "race",
+			CI:      true,
+			Expires: "2015-12-31",
+			Comment: "These tests are flaky with -race.",
+		},
+	}
+	if err := tooldata.SaveExclusions(fake.X, exclusions); err != nil {
+		t.Fatalf("%v", err)
+	}
+	got, err := tooldata.LoadExclusions(fake.X)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, exclusions) {
+		t.Fatalf("got %#v, want %#v", got, exclusions)
+	}
+
+	invalid := []tooldata.Exclusion{
+		tooldata.Exclusion{Package: "(", Test: ".*"},
+		tooldata.Exclusion{Package: ".*", Test: ".*", Mode: "fast"},
+		tooldata.Exclusion{Package: ".*", Test: ".*", Expires: "Dec 31, 2015"},
+	}
+	for _, e := range invalid {
+		if err := tooldata.SaveExclusions(fake.X, []tooldata.Exclusion{e}); err == nil {
+			t.Errorf("SaveExclusions(%#v) didn't fail", e)
+		}
+	}
+}
+
+func TestExclusionExpired(t *testing.T) {
+	testCases := []struct {
+		expires string
+		time    time.Time
+		expired bool
+	}{
+		{"", time.Date(2015, time.December, 31, 12, 0, 0, 0, time.Local), false},
+		{"2015-12-31", time.Date(2015, time.December, 30, 12, 0, 0, 0, time.Local), false},
+		{"2015-12-31", time.Date(2015, time.December, 31, 23, 59, 0, 0, time.Local), false},
+		{"2015-12-31", time.Date(2016, time.January, 1, 0, 0, 0, 0, time.Local), true},
+	}
+	for _, test := range testCases {
+		e := tooldata.Exclusion{Package: ".*", Test: ".*", Expires: test.expires}
+		if got, want := e.Expired(test.time), test.expired; got != want {
+			t.Errorf("Expired(%v) with expiry date %q: got %v, want %v", test.time, test.expires, got, want)
+		}
+	}
+}
@@ -24,6 +24,15 @@ func ConfigFilePath(jirix *jiri.X) (string, error) {
 	return filepath.Join(dataDir, "config.v1.xml"), nil
 }
 
+// ExclusionsFilePath returns the path to the test exclusions file.
+func ExclusionsFilePath(jirix *jiri.X) (string, error) {
+	dataDir, err := DataDirPath(jirix, tool.Name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "exclusions.v1.xml"), nil
+}
+
 // OncallRotationPath returns the path to the oncall rotation file.
 func OncallRotationPath(jirix *jiri.X) (string, error) {
 	dataDir, err := DataDirPath(jirix, tool.Name)
@@ -33,6 +42,13 @@ func OncallRotationPath(jirix *jiri.X) (string, error) {
 	return filepath.Join(dataDir, "oncall.v1.xml"), nil
 }
 
+// TestHistoryFilePath returns the path to the test history file. Unlike
+// the other tools data, the test history is specific to the local
+// machine, so it is stored in the jiri root metadata directory.
+func TestHistoryFilePath(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), "test_history.v1.json")
+}
+
 // ThirdPartyBinPath returns the path to the given third-party tool
 // taking into account the host and the target Go architecture.
 func ThirdPartyBinPath(jirix *jiri.X, name string) (string, error) {
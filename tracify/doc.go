@@ -9,15 +9,52 @@
 tracify adds vtrace annotations to all functions in the given packages that have
 a context as the first argument.
 
-TODO(mattr): We will eventually support various options like excluding certain
-functions or including specific information in the span name.
+Functions are identified by their qualified names, which are of the form
+<package path>.<function> or <package path>.<receiver type>.<method>, e.g.
+v.io/x/ref/runtime/internal/rpc.server.Stop.  The -include and -exclude flags
+select the functions to be processed by matching regular expressions against
+these names, so a package can be excluded via -exclude=^<package path>\.
+
+The -span-name flag specifies a text/template for span names, which is executed
+with the following fields:
+  .Package      the import path of the package
+  .PackageName  the name of the package
+  .Receiver     the receiver type of the method, without any *, or "" for
+                functions
+  .Func         the name of the function or method
+and the method .Arg <name>, which includes the value of the named argument, if
+any, in the span name, formatted via fmt's %v verb.  For example:
+  -span-name='{{.Receiver}}.{{.Func}}({{.Arg "name"}})'
+
+The annotations added by tracify are marked with a "tracify: DO NOT EDIT"
+comment.  Re-running tracify updates marked annotations whose span names have
+changed, and leaves other existing annotations alone, so it never annotates a
+function twice.
+
+The -remove flag removes the vtrace annotations added by tracify from the
+selected functions, along with the vtrace and fmt imports if they are no longer
+used. Hand-written annotations are left alone.
+
+The -diff flag prints the changes as unified diffs instead of modifying any
+files, and exits with status 1 if there are any changes, so that it can be
+used to check that all annotations are present and up to date.
 
 Usage:
    tracify [flags] [-t] [packages]
 
 The global flags are:
+ -diff=false
+   print unified diffs of the changes instead of modifying files.
+ -exclude=
+   skip functions whose qualified names match this regular expression.
+ -include=
+   only process functions whose qualified names match this regular expression.
  -metadata=<just specify -metadata to activate>
    Displays metadata for the program and exits.
+ -remove=false
+   remove vtrace annotations instead of adding them.
+ -span-name={{.Func}}
+   template for span names, see the description of tracify.
  -t=false
    include transitive dependencies of named packages.
  -time=false
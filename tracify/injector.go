@@ -6,11 +6,13 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"go/format"
 	"go/token"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"text/template"
 )
 
@@ -18,7 +20,7 @@ import (
 // address space.
 type injector struct {
 	read  int
-	r     io.Reader
+	r     *os.File
 	w     bytes.Buffer
 	fname string
 }
@@ -37,6 +39,20 @@ func (i *injector) copyTo(p token.Position) error {
 	return err
 }
 
+// remove copies the source up to, but not including, from, and skips the
+// source up to, but not including, to.
+func (i *injector) remove(from, to token.Position) error {
+	toread := from.Offset - i.read
+	i.read += toread
+	if _, err := io.CopyN(&i.w, i.r, int64(toread)); err != nil {
+		return err
+	}
+	toskip := to.Offset - i.read
+	i.read += toskip
+	_, err := io.CopyN(ioutil.Discard, i.r, int64(toskip))
+	return err
+}
+
 func (i *injector) inject(p token.Position, content string) error {
 	if err := i.copyTo(p); err != nil {
 		return err
@@ -45,23 +61,60 @@ func (i *injector) inject(p token.Position, content string) error {
 	return err
 }
 
-func (i *injector) format() error {
+// format formats the rewritten file and writes it back, unless it is
+// unchanged.  If diff is non-nil, the unified diff of the changes is written to
+// diff instead, and the file is left untouched.  It returns true if the file
+// changed.
+func (i *injector) format(diff io.Writer) (bool, error) {
+	defer i.r.Close()
 	if _, err := io.Copy(&i.w, i.r); err != nil {
-		return err
+		return false, err
 	}
 	out, err := format.Source(i.w.Bytes())
 	if err != nil {
-		return err
+		return false, err
 	}
-	f, err := os.Create(i.fname)
+	orig, err := ioutil.ReadFile(i.fname)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if bytes.Equal(orig, out) {
+		return false, nil
 	}
-	stat, err := f.Stat()
+	if diff != nil {
+		return true, diffFile(diff, i.fname, out)
+	}
+	stat, err := os.Stat(i.fname)
+	if err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(i.fname, out, stat.Mode())
+}
+
+// diffFile writes the unified diff from the file at fname to data to w.
+func diffFile(w io.Writer, fname string, data []byte) error {
+	tmp, err := ioutil.TempFile("", "tracify")
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(i.fname, out, stat.Mode())
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("diff", "-u", "-L", fname, "-L", fname, fname, tmp.Name())
+	cmd.Stdout, cmd.Stderr = w, &stderr
+	if err := cmd.Run(); err != nil {
+		// Diff exits with status 1 if the files differ.
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return fmt.Errorf("diff failed: %v\n%s", err, stderr.String())
+		}
+	}
+	return nil
 }
 
 func (i *injector) execute(p token.Position, t *template.Template, data interface{}) error {
@@ -19,6 +19,9 @@ import (
 	"go/token"
 	"io"
 	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"text/template"
@@ -33,6 +36,7 @@ var skipPackages = map[string]bool{
 }
 
 const vtracePackage = "\"v.io/v23/vtrace\""
+const fmtPackage = "\"fmt\""
 const contextPackage = "v.io/v23/context"
 
 func main() {
@@ -41,8 +45,52 @@ func main() {
 
 var (
 	transitive = flag.Bool("t", false, "include transitive dependencies of named packages.")
+	include    = flag.String("include", "", "only process functions whose qualified names match this regular expression.")
+	exclude    = flag.String("exclude", "", "skip functions whose qualified names match this regular expression.")
+	spanName   = flag.String("span-name", "{{.Func}}", "template for span names, see the description of tracify.")
+	remove     = flag.Bool("remove", false, "remove vtrace annotations instead of adding them.")
+	diffOnly   = flag.Bool("diff", false, "print unified diffs of the changes instead of modifying files.")
 )
 
+// config holds the options that control which functions are annotated and
+// how.
+type config struct {
+	include, exclude *regexp.Regexp
+	spanName         *template.Template
+	remove           bool
+	diff             io.Writer // If non-nil, diffs are written to diff instead of modifying files.
+	changed          int       // The number of files that were changed, or diffed.
+}
+
+// newConfig returns the config specified by the command line flags.
+func newConfig() (*config, error) {
+	c := &config{remove: *remove}
+	var err error
+	if *include != "" {
+		if c.include, err = regexp.Compile(*include); err != nil {
+			return nil, fmt.Errorf("invalid -include: %v", err)
+		}
+	}
+	if *exclude != "" {
+		if c.exclude, err = regexp.Compile(*exclude); err != nil {
+			return nil, fmt.Errorf("invalid -exclude: %v", err)
+		}
+	}
+	if c.spanName, err = template.New("span-name").Parse(*spanName); err != nil {
+		return nil, fmt.Errorf("invalid -span-name: %v", err)
+	}
+	return c, nil
+}
+
+// selected returns true if the function with the given qualified name should
+// be processed.
+func (c *config) selected(name string) bool {
+	if c.include != nil && !c.include.MatchString(name) {
+		return false
+	}
+	return c.exclude == nil || !c.exclude.MatchString(name)
+}
+
 var cmdTracify = &cmdline.Command{
 	Name:  "tracify",
 	Short: "Add vtrace annotations to functions in the specified packages.",
@@ -50,8 +98,35 @@ var cmdTracify = &cmdline.Command{
 tracify adds vtrace annotations to all functions in the given packages that
 have a context as the first argument.
 
-TODO(mattr): We will eventually support various options like excluding certain functions
-or including specific information in the span name.
+Functions are identified by their qualified names, which are of the form
+<package path>.<function> or <package path>.<receiver type>.<method>, e.g.
+v.io/x/ref/runtime/internal/rpc.server.Stop.  The -include and -exclude flags
+select the functions to be processed by matching regular expressions against
+these names, so a package can be excluded via -exclude=^<package path>\.
+
+The -span-name flag specifies a text/template for span names, which is executed
+with the following fields:
+  .Package      the import path of the package
+  .PackageName  the name of the package
+  .Receiver     the receiver type of the method, without any *, or "" for
+                functions
+  .Func         the name of the function or method
+and the method .Arg <name>, which includes the value of the named argument, if
+any, in the span name, formatted via fmt's %v verb.  For example:
+  -span-name='{{.Receiver}}.{{.Func}}({{.Arg "name"}})'
+
+The annotations added by tracify are marked with a "tracify: DO NOT EDIT"
+comment.  Re-running tracify updates marked annotations whose span names have
+changed, and leaves other existing annotations alone, so it never annotates a
+function twice.
+
+The -remove flag removes the vtrace annotations added by tracify from the
+selected functions, along with the vtrace and fmt imports if they are no longer
+used. Hand-written annotations are left alone.
+
+The -diff flag prints the changes as unified diffs instead of modifying any
+files, and exits with status 1 if there are any changes, so that it can be
+used to check that all annotations are present and up to date.
 `,
 	ArgsName: "[-t] [packages]",
 	Runner:   cmdline.RunnerFunc(tracify),
@@ -59,6 +134,13 @@ or including specific information in the span name.
 
 // tracify adds vtrace spans to functions in the packages defined by args.
 func tracify(env *cmdline.Env, args []string) error {
+	c, err := newConfig()
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	if *diffOnly {
+		c.diff = env.Stdout
+	}
 	pkgs, err := readPackages(env, args)
 	if err != nil {
 		return err
@@ -74,17 +156,21 @@ func tracify(env *cmdline.Env, args []string) error {
 	}
 	for _, pkg := range pkgs {
 		if pkg != nil {
-			if err := processPackage(pkg); err != nil {
+			if err := processPackage(pkg, c); err != nil {
 				return err
 			}
 		}
 	}
+	if c.diff != nil && c.changed > 0 {
+		// Like diff(1), exit with status 1 if there are differences.
+		return cmdline.ErrExitCode(1)
+	}
 	return nil
 }
 
 // processPackage processes a build package, rewriting any file in the package
 // to include vtrace annotations.
-func processPackage(pkg *build.Package) error {
+func processPackage(pkg *build.Package, c *config) error {
 	fset := token.NewFileSet()
 	pkgs, err := parser.ParseDir(fset, pkg.Dir, nil, parser.ParseComments)
 	if err != nil {
@@ -92,51 +178,269 @@ func processPackage(pkg *build.Package) error {
 	}
 	for _, p := range pkgs {
 		for fname, f := range p.Files {
-			processFile(fset, fname, f)
+			if err := processFile(fset, pkg, fname, f, c); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// annotationComment marks the annotations added by tracify, so that they can
+// be updated when re-running tracify.
+const annotationComment = "// tracify: DO NOT EDIT"
+
 var vtraceTpl = template.Must(template.New("vtrace").Parse(`
-	{{.CtxName}}, vspan := {{.VtraceName}}.WithNewSpan({{.CtxName}}, "{{.FuncName}}")
+	{{.CtxName}}, vspan := {{.VtraceName}}.WithNewSpan({{.CtxName}}, {{.SpanName}}) ` + annotationComment + `
 	defer vspan.Finish()
 `))
 
 type decl struct {
 	pos        token.Position
+	replace    *span // The existing annotation to be replaced, if any.
 	CtxName    string
-	FuncName   string
+	SpanName   string
 	VtraceName string
 }
 
-// processFile Processes a single source file, rewriting it to include vtrace
-// spans where necessary.
-func processFile(fset *token.FileSet, fname string, f *ast.File) error {
-	vtraceName := ""
+// spanData is the data that span name templates are executed with.
+type spanData struct {
+	Package     string
+	PackageName string
+	Receiver    string
+	Func        string
+
+	params map[string]bool
+	args   []string
+}
+
+// argPlaceholder marks the position of arguments in executed span name
+// templates.
+const argPlaceholder = "\x00"
+
+// Arg includes the value of the named argument in the span name, if the
+// function has such an argument.
+func (d *spanData) Arg(name string) string {
+	if !d.params[name] {
+		return ""
+	}
+	d.args = append(d.args, name)
+	return argPlaceholder
+}
+
+// receiverType returns the name of the receiver type of fd, or "" if fd is
+// not a method.
+func receiverType(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	typ := fd.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// qualifiedName returns the name of fd, qualified by its package path and
+// receiver type, if any.
+func qualifiedName(pkg *build.Package, fd *ast.FuncDecl) string {
+	if recv := receiverType(fd); recv != "" {
+		return pkg.ImportPath + "." + recv + "." + fd.Name.Name
+	}
+	return pkg.ImportPath + "." + fd.Name.Name
+}
+
+// genSpanName executes the span name template for fd, returning a Go
+// expression for the span name, and whether it uses the fmt package.
+func genSpanName(c *config, pkg *build.Package, f *ast.File, fd *ast.FuncDecl, fmtName string) (string, bool, error) {
+	data := &spanData{
+		Package:     pkg.ImportPath,
+		PackageName: f.Name.Name,
+		Receiver:    receiverType(fd),
+		Func:        fd.Name.Name,
+		params:      map[string]bool{},
+	}
+	for _, param := range fd.Type.Params.List {
+		for _, name := range param.Names {
+			if name.Name != "_" {
+				data.params[name.Name] = true
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := c.spanName.Execute(buf, data); err != nil {
+		return "", false, err
+	}
+	if len(data.args) == 0 {
+		return strconv.Quote(buf.String()), false, nil
+	}
+	format := strings.Replace(buf.String(), "%", "%%", -1)
+	format = strings.Replace(format, argPlaceholder, "%v", -1)
+	return fmt.Sprintf("%s.Sprintf(%s, %s)", fmtName, strconv.Quote(format), strings.Join(data.args, ", ")), true, nil
+}
+
+// importName returns the name that the file refers to the package with the
+// given quoted path by, or "" if the file doesn't import it.
+func importName(f *ast.File, path string) string {
 	for _, i := range f.Imports {
-		if i.Path.Value == vtracePackage {
+		if i.Path.Value == path {
 			if i.Name == nil {
-				vtraceName = "vtrace"
-			} else {
-				vtraceName = i.Name.Name
+				p, _ := strconv.Unquote(path)
+				return p[strings.LastIndex(p, "/")+1:]
+			}
+			return i.Name.Name
+		}
+	}
+	return ""
+}
+
+// findAnnotation returns the range of the vtrace annotation at the beginning
+// of fd, if there is one.  The annotation consists of the statements:
+//
+//	<ctx>, vspan := <vtrace>.WithNewSpan(<ctx>, <span name>)
+//	defer vspan.Finish()
+func findAnnotation(fd *ast.FuncDecl, vtraceName string) (start, end token.Pos, ok bool) {
+	if fd.Body == nil || len(fd.Body.List) < 2 || vtraceName == "" {
+		return 0, 0, false
+	}
+	assign, ok := fd.Body.List[0].(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return 0, 0, false
+	}
+	if span, ok := assign.Lhs[1].(*ast.Ident); !ok || span.Name != "vspan" {
+		return 0, 0, false
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok || !isSelector(call.Fun, vtraceName, "WithNewSpan") {
+		return 0, 0, false
+	}
+	finish, ok := fd.Body.List[1].(*ast.DeferStmt)
+	if !ok || !isSelector(finish.Call.Fun, "vspan", "Finish") {
+		return 0, 0, false
+	}
+	return assign.Pos(), finish.End(), true
+}
+
+// annotationSpan returns the range of source code to be removed in order to
+// remove the annotation of fd ending at end, i.e. everything from the opening
+// brace of fd up to the next line, including the newline preceding the
+// annotation and any comment following it.
+func annotationSpan(fset *token.FileSet, fd *ast.FuncDecl, end token.Pos) span {
+	tf := fset.File(end)
+	if line := tf.Line(end); line < tf.LineCount() {
+		if next := tf.LineStart(line + 1); next <= fd.Body.Rbrace {
+			end = next
+		}
+	}
+	return span{fd.Body.Lbrace + 1, end}
+}
+
+// isMarked returns true if the annotation of fd starting at start was added
+// by tracify, i.e. is followed by annotationComment on the same line.
+func isMarked(fset *token.FileSet, f *ast.File, start token.Pos) bool {
+	line := fset.Position(start).Line
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if c.Pos() > start && fset.Position(c.Pos()).Line == line && c.Text == annotationComment {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// isUpToDate returns true if the annotation of fd uses the given context
+// name and span name expression.
+func isUpToDate(fset *token.FileSet, fd *ast.FuncDecl, ctxName, spanName string) bool {
+	assign := fd.Body.List[0].(*ast.AssignStmt)
+	if ctx, ok := assign.Lhs[0].(*ast.Ident); !ok || ctx.Name != ctxName {
+		return false
+	}
+	args := assign.Rhs[0].(*ast.CallExpr).Args
+	if len(args) != 2 {
+		return false
+	}
+	want, err := parser.ParseExpr(spanName)
+	if err != nil {
+		return false
+	}
+	got, wantBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := format.Node(got, fset, args[1]); err != nil {
+		return false
+	}
+	if err := format.Node(wantBuf, token.NewFileSet(), want); err != nil {
+		return false
+	}
+	return got.String() == wantBuf.String()
+}
+
+// isSelector returns true if expr is of the form <x>.<sel>.
+func isSelector(expr ast.Expr, x, sel string) bool {
+	s, ok := expr.(*ast.SelectorExpr)
+	if !ok || s.Sel.Name != sel {
+		return false
+	}
+	id, ok := s.X.(*ast.Ident)
+	return ok && id.Name == x
+}
+
+// processFile Processes a single source file, rewriting it to include vtrace
+// spans where necessary, or to remove them if c.remove is true.
+func processFile(fset *token.FileSet, pkg *build.Package, fname string, f *ast.File, c *config) error {
+	if c.remove {
+		return removeFromFile(fset, pkg, fname, f, c)
+	}
+	vtraceName := importName(f, vtracePackage)
+	fmtName := importName(f, fmtPackage)
+	needsFmt := false
 
 	decls := []decl{}
+	replaced := []span{}
 	args := translateTypes(fset, f.Imports, []string{"*context.T"})
 	for _, d := range f.Decls {
 		if fd, ok := d.(*ast.FuncDecl); ok {
+			if fd.Body == nil || !c.selected(qualifiedName(pkg, fd)) {
+				continue
+			}
 			matches, names := checkParams(fset, fd.Type, args)
 			if !matches || len(names) == 0 || names[0] == "_" {
 				continue
 			}
-			decls = append(decls, decl{
+			name := fmtName
+			if name == "" {
+				name = "fmt"
+			}
+			spanName, usesFmt, err := genSpanName(c, pkg, f, fd, name)
+			if err != nil {
+				pos := fset.Position(fd.Pos())
+				return fmt.Errorf("%s:%d: %v", pos.Filename, pos.Line, err)
+			}
+			d := decl{
 				pos:      fset.Position(fd.Body.Lbrace),
 				CtxName:  names[0],
-				FuncName: fd.Name.Name,
-			})
+				SpanName: spanName,
+			}
+			// Existing annotations are updated if they were added by
+			// tracify, and left alone otherwise.
+			if start, end, ok := findAnnotation(fd, vtraceName); ok {
+				if !isMarked(fset, f, start) || isUpToDate(fset, fd, d.CtxName, d.SpanName) {
+					continue
+				}
+				replace := annotationSpan(fset, fd, end)
+				d.replace = &replace
+				replaced = append(replaced, replace)
+			}
+			needsFmt = needsFmt || usesFmt
+			decls = append(decls, d)
 		}
 	}
 
@@ -151,19 +455,131 @@ func processFile(fset *token.FileSet, fname string, f *ast.File) error {
 			}
 			vtraceName = "vtrace"
 		}
+		if needsFmt && fmtName == "" {
+			if err := inj.inject(fset.Position(f.Name.End()), fmt.Sprintf("\nimport %s\n", fmtPackage)); err != nil {
+				return err
+			}
+		}
+		// Updated annotations may no longer need the fmt import.
+		if !needsFmt && fmtName != "" && len(replaced) > 0 && !usedOutside(f, fmtName, replaced) {
+			if s, ok := importSpan(f, fmtPackage); ok {
+				if err := inj.remove(fset.Position(s.start), fset.Position(s.end)); err != nil {
+					return err
+				}
+			}
+		}
 		for _, d := range decls {
 			d.VtraceName = vtraceName
 			if err := inj.execute(d.pos, vtraceTpl, d); err != nil {
 				return err
 			}
+			if d.replace != nil {
+				if err := inj.remove(fset.Position(d.replace.start), fset.Position(d.replace.end)); err != nil {
+					return err
+				}
+			}
 		}
-		if err := inj.format(); err != nil {
+		if err := c.format(inj); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// removeFromFile removes the vtrace annotations added by tracify from the
+// selected functions in a single source file, along with the vtrace and fmt
+// imports if they are no longer used.  Hand-written annotations are kept.
+func removeFromFile(fset *token.FileSet, pkg *build.Package, fname string, f *ast.File, c *config) error {
+	vtraceName := importName(f, vtracePackage)
+	spans := []span{}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && c.selected(qualifiedName(pkg, fd)) {
+			if start, end, ok := findAnnotation(fd, vtraceName); ok && isMarked(fset, f, start) {
+				spans = append(spans, annotationSpan(fset, fd, end))
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	for _, path := range []string{vtracePackage, fmtPackage} {
+		if name := importName(f, path); name != "" && !usedOutside(f, name, spans) {
+			if s, ok := importSpan(f, path); ok {
+				spans = append(spans, s)
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	inj, err := newInjector(fname)
+	if err != nil {
+		return err
+	}
+	for _, s := range spans {
+		if err := inj.remove(fset.Position(s.start), fset.Position(s.end)); err != nil {
+			return err
+		}
+	}
+	return c.format(inj)
+}
+
+// format formats the file rewritten by inj and writes it back, or its diff to
+// c.diff, counting it in c.changed if it changed.
+func (c *config) format(inj *injector) error {
+	changed, err := inj.format(c.diff)
+	if changed {
+		c.changed++
+	}
+	return err
+}
+
+// span is a range of source code.
+type span struct {
+	start, end token.Pos
+}
+
+// usedOutside returns true if the package with the given name is referred to
+// outside of spans in f.
+func usedOutside(f *ast.File, name string, spans []span) bool {
+	used := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || used {
+			return false
+		}
+		for _, s := range spans {
+			if s.start <= n.Pos() && n.End() <= s.end {
+				return false
+			}
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok && isSelector(sel, name, sel.Sel.Name) {
+			used = true
+		}
+		return true
+	})
+	return used
+}
+
+// importSpan returns the range of the import of the package with the given
+// quoted path, if f imports it.
+func importSpan(f *ast.File, path string) (span, bool) {
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			if spec.(*ast.ImportSpec).Path.Value != path {
+				continue
+			}
+			if !gd.Lparen.IsValid() {
+				return span{gd.Pos(), gd.End()}, true
+			}
+			return span{spec.Pos(), spec.End()}, true
+		}
+	}
+	return span{}, false
+}
+
 // readPackages resolves the user-supplied package patterns to a list of actual packages.
 // We just call out to 'go list' for this since there is actually a lot of subtlety
 // in resolving the patterns.
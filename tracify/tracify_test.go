@@ -0,0 +1,440 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+const original = `package p
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	_ = name
+}
+
+func noContext(name string) {}
+`
+
+const injected = `package p
+
+import "v.io/v23/vtrace"
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	ctx, vspan := vtrace.WithNewSpan(ctx, "F") // tracify: DO NOT EDIT
+	defer vspan.Finish()
+
+	_ = name
+}
+
+func noContext(name string) {}
+`
+
+const updated = `package p
+
+import "fmt"
+
+import "v.io/v23/vtrace"
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	ctx, vspan := vtrace.WithNewSpan(ctx, fmt.Sprintf("F(%v)", name)) // tracify: DO NOT EDIT
+	defer vspan.Finish()
+
+	_ = name
+}
+
+func noContext(name string) {}
+`
+
+// testConfig returns the config for the given span name template.
+func testConfig(t *testing.T, spanName string) *config {
+	tmpl, err := template.New("span-name").Parse(spanName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config{spanName: tmpl}
+}
+
+// process runs processFile on the file at fname, and returns its contents
+// afterwards.
+func process(t *testing.T, fname string, c *config) string {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &build.Package{ImportPath: "v.io/x/p", Name: "p", Dir: filepath.Dir(fname)}
+	if err := processFile(fset, pkg, fname, f, c); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestProcessFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(fname, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		spanName string
+		remove   bool
+		want     string
+		changed  int
+	}{
+		{"inject", "{{.Func}}", false, injected, 1},
+		{"re-inject", "{{.Func}}", false, injected, 0},
+		{"update span name", `{{.Func}}({{.Arg "name"}})`, false, updated, 1},
+		{"re-inject updated", `{{.Func}}({{.Arg "name"}})`, false, updated, 0},
+		{"remove", "{{.Func}}", true, original, 1},
+		{"remove again", "{{.Func}}", true, original, 0},
+	}
+	for _, test := range tests {
+		c := testConfig(t, test.spanName)
+		c.remove = test.remove
+		if got := process(t, fname, c); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		if got, want := c.changed, test.changed; got != want {
+			t.Errorf("%s: got %d changed files, want %d", test.name, got, want)
+		}
+	}
+}
+
+func TestProcessFileRemoveHandWritten(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "p.go")
+	const handWritten = `package p
+
+import "v.io/v23/vtrace"
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	ctx, vspan := vtrace.WithNewSpan(ctx, "F") // tracify: DO NOT EDIT
+	defer vspan.Finish()
+
+	_ = name
+}
+
+func G(ctx *context.T) {
+	ctx, span := vtrace.WithNewSpan(ctx, "custom")
+	defer span.Finish()
+}
+`
+	const want = `package p
+
+import "v.io/v23/vtrace"
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	_ = name
+}
+
+func G(ctx *context.T) {
+	ctx, span := vtrace.WithNewSpan(ctx, "custom")
+	defer span.Finish()
+}
+`
+	if err := ioutil.WriteFile(fname, []byte(handWritten), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Only the annotation added by tracify is removed, and the vtrace import
+	// is kept since the hand-written annotation still uses it.
+	c := testConfig(t, "{{.Func}}")
+	c.remove = true
+	if got := process(t, fname, c); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got, want := c.changed, 1; got != want {
+		t.Errorf("got %d changed files, want %d", got, want)
+	}
+}
+
+func TestProcessFileDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(fname, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var diff bytes.Buffer
+	c := testConfig(t, "{{.Func}}")
+	c.diff = &diff
+	if got := process(t, fname, c); got != original {
+		t.Errorf("got\n%s\nwant the file to be unchanged", got)
+	}
+	if got, want := c.changed, 1; got != want {
+		t.Errorf("got %d changed files, want %d", got, want)
+	}
+	for _, want := range []string{
+		"--- " + fname + "\n",
+		"+++ " + fname + "\n",
+		"+import \"v.io/v23/vtrace\"\n",
+		"+\tctx, vspan := vtrace.WithNewSpan(ctx, \"F\") // tracify: DO NOT EDIT\n",
+	} {
+		if !strings.Contains(diff.String(), want) {
+			t.Errorf("got diff\n%s\nwant it to contain %q", diff.String(), want)
+		}
+	}
+
+	// The diff is empty once the annotations are up to date.
+	if err := ioutil.WriteFile(fname, []byte(injected), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diff.Reset()
+	c = testConfig(t, "{{.Func}}")
+	c.diff = &diff
+	process(t, fname, c)
+	if got, want := c.changed, 0; got != want {
+		t.Errorf("got %d changed files, want %d", got, want)
+	}
+	if diff.Len() != 0 {
+		t.Errorf("got diff\n%s\nwant none", diff.String())
+	}
+}
+
+const methods = `package p
+
+import "v.io/v23/context"
+
+type server struct{}
+
+func (s *server) Stop(ctx *context.T, name string, _ int, count int) {}
+
+func (server) Start(ctx *context.T) {}
+
+type list[T any] struct{}
+
+func (l *list[T]) Add(ctx *context.T, v T) {}
+
+func F(ctx *context.T) {}
+`
+
+// parseMethods parses methods, returning its function declarations by name.
+func parseMethods(t *testing.T) (*ast.File, map[string]*ast.FuncDecl) {
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", methods, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decls := map[string]*ast.FuncDecl{}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok {
+			decls[fd.Name.Name] = fd
+		}
+	}
+	return f, decls
+}
+
+func TestQualifiedName(t *testing.T) {
+	_, decls := parseMethods(t)
+	pkg := &build.Package{ImportPath: "v.io/x/p", Name: "p"}
+	tests := []struct {
+		fn, want string
+	}{
+		{"Stop", "v.io/x/p.server.Stop"},
+		{"Start", "v.io/x/p.server.Start"},
+		{"Add", "v.io/x/p.list.Add"},
+		{"F", "v.io/x/p.F"},
+	}
+	for _, test := range tests {
+		if got := qualifiedName(pkg, decls[test.fn]); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.fn, got, test.want)
+		}
+	}
+}
+
+func TestSelected(t *testing.T) {
+	tests := []struct {
+		include, exclude string
+		name             string
+		want             bool
+	}{
+		{"", "", "v.io/x/p.F", true},
+		{`^v\.io/x/p\.`, "", "v.io/x/p.F", true},
+		{`^v\.io/x/p\.`, "", "v.io/x/q.F", false},
+		{"", `^v\.io/x/p\.`, "v.io/x/p.server.Stop", false},
+		{"", `^v\.io/x/p\.`, "v.io/x/q.F", true},
+		{`\.server\.`, `\.Stop$`, "v.io/x/p.server.Start", true},
+		{`\.server\.`, `\.Stop$`, "v.io/x/p.server.Stop", false},
+		{`\.server\.`, `\.Stop$`, "v.io/x/p.F", false},
+	}
+	for _, test := range tests {
+		c := &config{}
+		if test.include != "" {
+			c.include = regexp.MustCompile(test.include)
+		}
+		if test.exclude != "" {
+			c.exclude = regexp.MustCompile(test.exclude)
+		}
+		if got := c.selected(test.name); got != test.want {
+			t.Errorf("include %q, exclude %q: selected(%q) = %v, want %v", test.include, test.exclude, test.name, got, test.want)
+		}
+	}
+}
+
+func TestGenSpanName(t *testing.T) {
+	f, decls := parseMethods(t)
+	pkg := &build.Package{ImportPath: "v.io/x/p", Name: "p"}
+	tests := []struct {
+		spanName string
+		fn       string
+		fmtName  string
+		want     string
+		usesFmt  bool
+	}{
+		{"{{.Package}}", "F", "fmt", `"v.io/x/p"`, false},
+		{"{{.PackageName}}", "F", "fmt", `"p"`, false},
+		{"{{.Receiver}}", "Stop", "fmt", `"server"`, false},
+		{"{{.Receiver}}", "Add", "fmt", `"list"`, false},
+		{"{{.Receiver}}", "F", "fmt", `""`, false},
+		{"{{.Func}}", "Stop", "fmt", `"Stop"`, false},
+		{`{{.PackageName}}.{{if .Receiver}}{{.Receiver}}.{{end}}{{.Func}}`, "Stop", "fmt", `"p.server.Stop"`, false},
+		{`{{.PackageName}}.{{if .Receiver}}{{.Receiver}}.{{end}}{{.Func}}`, "F", "fmt", `"p.F"`, false},
+		{`{{.Func}}({{.Arg "name"}})`, "Stop", "fmt", `fmt.Sprintf("Stop(%v)", name)`, true},
+		{`{{.Func}}({{.Arg "name"}}, {{.Arg "count"}})`, "Stop", "fmt", `fmt.Sprintf("Stop(%v, %v)", name, count)`, true},
+		{`{{.Func}}({{.Arg "name"}})`, "Stop", "gofmt", `gofmt.Sprintf("Stop(%v)", name)`, true},
+		{`100% {{.Arg "name"}}`, "Stop", "fmt", `fmt.Sprintf("100%% %v", name)`, true},
+		// Missing and blank arguments are left out.
+		{`{{.Func}}({{.Arg "missing"}})`, "Stop", "fmt", `"Stop()"`, false},
+		{`{{.Func}}({{.Arg "_"}})`, "Stop", "fmt", `"Stop()"`, false},
+		{`100% {{.Func}}`, "Stop", "fmt", `"100% Stop"`, false},
+	}
+	for _, test := range tests {
+		got, usesFmt, err := genSpanName(testConfig(t, test.spanName), pkg, f, decls[test.fn], test.fmtName)
+		if err != nil {
+			t.Errorf("%s: %v", test.spanName, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.spanName, got, test.want)
+		}
+		if usesFmt != test.usesFmt {
+			t.Errorf("%s: got uses fmt %v, want %v", test.spanName, usesFmt, test.usesFmt)
+		}
+	}
+	if _, _, err := genSpanName(testConfig(t, "{{.Unknown}}"), pkg, f, decls["F"], "fmt"); err == nil {
+		t.Errorf("got no error for an unknown field")
+	}
+}
+
+func TestProcessFileRemoveSelected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "p.go")
+	const annotated = `package p
+
+import "fmt"
+
+import "v.io/v23/vtrace"
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	ctx, vspan := vtrace.WithNewSpan(ctx, fmt.Sprintf("F(%v)", name)) // tracify: DO NOT EDIT
+	defer vspan.Finish()
+
+	_ = name
+}
+
+func G(ctx *context.T) {
+	ctx, vspan := vtrace.WithNewSpan(ctx, "G") // tracify: DO NOT EDIT
+	defer vspan.Finish()
+}
+`
+	const removedF = `package p
+
+import "v.io/v23/vtrace"
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	_ = name
+}
+
+func G(ctx *context.T) {
+	ctx, vspan := vtrace.WithNewSpan(ctx, "G") // tracify: DO NOT EDIT
+	defer vspan.Finish()
+}
+`
+	const removedAll = `package p
+
+import "v.io/v23/context"
+
+func F(ctx *context.T, name string) {
+	_ = name
+}
+
+func G(ctx *context.T) {}
+`
+	if err := ioutil.WriteFile(fname, []byte(annotated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name             string
+		include, exclude string
+		want             string
+		changed          int
+	}{
+		// The fmt import is removed along with the only annotation using it.
+		{"remove F", `\.F$`, "", removedF, 1},
+		{"remove excluded", "", `\.G$`, removedF, 0},
+		{"remove G", "", "", removedAll, 1},
+	}
+	for _, test := range tests {
+		c := testConfig(t, "{{.Func}}")
+		c.remove = true
+		if test.include != "" {
+			c.include = regexp.MustCompile(test.include)
+		}
+		if test.exclude != "" {
+			c.exclude = regexp.MustCompile(test.exclude)
+		}
+		if got := process(t, fname, c); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		if got, want := c.changed, test.changed; got != want {
+			t.Errorf("%s: got %d changed files, want %d", test.name, got, want)
+		}
+	}
+}